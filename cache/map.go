@@ -54,3 +54,14 @@ func (m *Map) Delete(key any) {
 	delete(m.m, key)
 	m.lock.Unlock()
 }
+
+// Keys : return all keys in map
+func (m *Map) Keys() []any {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	var keys = make([]any, 0, len(m.m))
+	for k := range m.m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,317 @@
+package errorx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CodeError is an error with a machine-readable code.
+// code -- grpc code, it decides grpc status and http status
+// reason -- business reason, e.g. "USER_NOT_FOUND", it's carried by grpc ErrorInfo detail
+// metadata -- extra key/value information, it's carried by grpc ErrorInfo detail
+//
+// A CodeError is usually defined as a package level sentinel, then matched by errors.Is:
+//
+//	var ErrUserNotFound = errorx.NewCode(codes.NotFound, "USER_NOT_FOUND", "user.not.found")
+//
+//	return errorx.WrapCode(err, ErrUserNotFound)
+//	...
+//	if errorx.Is(err, ErrUserNotFound) {...}
+type CodeError struct {
+	code     codes.Code
+	reason   string
+	msg      string
+	metadata map[string]string
+	cause    error
+}
+
+// NewCode returns a coded error without stack, it's suitable for sentinel errors.
+func NewCode(code codes.Code, reason string, message string) *CodeError {
+	return &CodeError{
+		code:   code,
+		reason: reason,
+		msg:    message,
+	}
+}
+
+// NewCodef returns a coded error with format message.
+func NewCodef(code codes.Code, reason string, format string, args ...any) *CodeError {
+	return NewCode(code, reason, fmt.Sprintf(format, args...))
+}
+
+// WrapCode annotates err with the code, reason and message of ce, also records the stack trace
+// if err has not been with stack.
+// If err is nil, WrapCode returns nil.
+func WrapCode(err error, ce *CodeError) error {
+	if err == nil {
+		return nil
+	}
+	var c = ce.WithCause(err)
+	if hasBeenWithStack(err) {
+		return c
+	}
+	return &withStack{
+		c,
+		callers(),
+	}
+}
+
+// Code returns the grpc code.
+func (e *CodeError) Code() codes.Code { return e.code }
+
+// Reason returns the business reason.
+func (e *CodeError) Reason() string { return e.reason }
+
+// Message returns the message without cause.
+func (e *CodeError) Message() string { return e.msg }
+
+// Metadata returns the metadata, it should not be modified.
+func (e *CodeError) Metadata() map[string]string { return e.metadata }
+
+// HTTPStatus returns the http status of the code.
+func (e *CodeError) HTTPStatus() int { return HTTPStatusFromCode(e.code) }
+
+// WithMessage returns a copy with new message.
+func (e *CodeError) WithMessage(message string) *CodeError {
+	var c = e.clone()
+	c.msg = message
+	return c
+}
+
+// WithMeta returns a copy with an additional metadata key/value.
+func (e *CodeError) WithMeta(key string, value string) *CodeError {
+	var c = e.clone()
+	c.metadata = make(map[string]string, len(e.metadata)+1)
+	maps.Copy(c.metadata, e.metadata)
+	c.metadata[key] = value
+	return c
+}
+
+// WithCause returns a copy with the cause.
+func (e *CodeError) WithCause(err error) *CodeError {
+	var c = e.clone()
+	c.cause = err
+	return c
+}
+
+func (e *CodeError) Error() string {
+	if e.cause == nil {
+		return e.msg
+	}
+	return e.msg + ": " + e.cause.Error()
+}
+
+func (e *CodeError) Cause() error { return e.cause }
+
+// Unwrap provides compatibility for Go 1.13 error chains.
+func (e *CodeError) Unwrap() error { return e.cause }
+
+// Is reports whether target is a CodeError with the same code and reason.
+// If the reason of target is empty, only the code is compared.
+func (e *CodeError) Is(target error) bool {
+	var t, ok = target.(*CodeError)
+	if !ok {
+		return false
+	}
+	return t.code == e.code && (t.reason == "" || t.reason == e.reason)
+}
+
+// GRPCStatus converts to grpc status, reason and metadata are carried by ErrorInfo detail.
+// The cause is not included in the status message.
+func (e *CodeError) GRPCStatus() *status.Status {
+	var st = status.New(e.code, e.msg)
+	if e.reason == "" && len(e.metadata) == 0 {
+		return st
+	}
+	var ds, err = st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   e.reason,
+		Metadata: e.metadata,
+	})
+	if err != nil {
+		return st
+	}
+	return ds
+}
+
+func (e *CodeError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			_, _ = fmt.Fprintf(s, "code: %s, reason: %s, msg: %s", e.code, e.reason, e.msg)
+			if len(e.metadata) > 0 {
+				_, _ = fmt.Fprintf(s, ", metadata: %v", e.metadata)
+			}
+			if e.cause != nil {
+				_, _ = fmt.Fprintf(s, "\n%+v", e.cause)
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		_, _ = io.WriteString(s, e.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+func (e *CodeError) clone() *CodeError {
+	var c = *e
+	return &c
+}
+
+// Code returns the grpc code of err.
+// nil -- codes.OK
+// CodeError in chain -- its code
+// grpc status error in chain -- its code
+// context error -- codes.Canceled / codes.DeadlineExceeded
+// others -- codes.Unknown
+func Code(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	var ce *CodeError
+	if As(err, &ce) {
+		return ce.code
+	}
+	var st, ok = status.FromError(err)
+	if ok {
+		return st.Code()
+	}
+	switch {
+	case Is(err, context.Canceled):
+		return codes.Canceled
+	case Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	}
+	return codes.Unknown
+}
+
+// Reason returns the business reason of the first CodeError in err's chain.
+func Reason(err error) string {
+	var ce *CodeError
+	if As(err, &ce) {
+		return ce.reason
+	}
+	return ""
+}
+
+// Metadata returns the metadata of the first CodeError in err's chain.
+func Metadata(err error) map[string]string {
+	var ce *CodeError
+	if As(err, &ce) {
+		return ce.metadata
+	}
+	return nil
+}
+
+// HTTPStatus returns the http status of err.
+func HTTPStatus(err error) int {
+	return HTTPStatusFromCode(Code(err))
+}
+
+// ToGRPCStatus converts err to grpc status.
+// The first CodeError in err's chain is used if exists.
+func ToGRPCStatus(err error) *status.Status {
+	if err == nil {
+		return status.New(codes.OK, "")
+	}
+	var ce *CodeError
+	if As(err, &ce) {
+		return ce.GRPCStatus()
+	}
+	var st, ok = status.FromError(err)
+	if ok {
+		return st
+	}
+	return status.New(Code(err), err.Error())
+}
+
+// FromGRPCStatus converts grpc status to CodeError, reason and metadata are read from ErrorInfo detail.
+// If st is nil or its code is OK, FromGRPCStatus returns nil.
+func FromGRPCStatus(st *status.Status) error {
+	if st == nil || st.Code() == codes.OK {
+		return nil
+	}
+	var ce = NewCode(st.Code(), "", st.Message())
+	for _, d := range st.Details() {
+		var info, ok = d.(*errdetails.ErrorInfo)
+		if ok {
+			ce.reason = info.GetReason()
+			ce.metadata = info.GetMetadata()
+			break
+		}
+	}
+	return ce
+}
+
+// HTTPStatusFromCode maps grpc code to http status, the same as grpc-gateway.
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CodeFromHTTPStatus maps http status to grpc code.
+func CodeFromHTTPStatus(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusOK:
+		return codes.OK
+	case 499:
+		return codes.Canceled
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	switch {
+	case httpStatus >= 200 && httpStatus < 300:
+		return codes.OK
+	case httpStatus >= 400 && httpStatus < 500:
+		return codes.FailedPrecondition
+	}
+	return codes.Unknown
+}
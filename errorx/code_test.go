@@ -0,0 +1,78 @@
+package errorx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errUserNotFound = NewCode(codes.NotFound, "USER_NOT_FOUND", "user.not.found")
+
+func TestCodeErrorIs(t *testing.T) {
+	var err = WrapCode(io.EOF, errUserNotFound.WithMeta("uid", "1"))
+	if !Is(err, errUserNotFound) {
+		t.Error("should match sentinel")
+	}
+	if !Is(err, io.EOF) {
+		t.Error("should match cause")
+	}
+	if !Is(err, NewCode(codes.NotFound, "", "")) {
+		t.Error("should match code only")
+	}
+	if Is(err, NewCode(codes.NotFound, "ORDER_NOT_FOUND", "")) {
+		t.Error("should not match other reason")
+	}
+	if Code(err) != codes.NotFound || Reason(err) != "USER_NOT_FOUND" || Metadata(err)["uid"] != "1" {
+		t.Errorf("invalid code error:%+v", err)
+	}
+	if GetFullStack(err) == "" {
+		t.Error("should have stack")
+	}
+	if len(errUserNotFound.Metadata()) != 0 {
+		t.Error("sentinel should not be modified")
+	}
+	t.Logf("%+v", err)
+}
+
+func TestCodeErrorGRPC(t *testing.T) {
+	var err = WrapCode(io.EOF, errUserNotFound.WithMeta("uid", "1"))
+	var st = ToGRPCStatus(err)
+	if st.Code() != codes.NotFound || st.Message() != "user.not.found" {
+		t.Errorf("invalid status:%+v", st)
+	}
+	var back = FromGRPCStatus(st)
+	if !Is(back, errUserNotFound) || Metadata(back)["uid"] != "1" {
+		t.Errorf("invalid code error:%+v", back)
+	}
+	if FromGRPCStatus(status.New(codes.OK, "")) != nil {
+		t.Error("ok status should be nil")
+	}
+	if Code(status.Error(codes.Aborted, "x")) != codes.Aborted {
+		t.Error("should read grpc status")
+	}
+	if Code(Wrap(context.DeadlineExceeded, "x")) != codes.DeadlineExceeded {
+		t.Error("should map context error")
+	}
+	if Code(nil) != codes.OK || Code(io.EOF) != codes.Unknown {
+		t.Error("invalid default code")
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	if HTTPStatus(errUserNotFound) != http.StatusNotFound {
+		t.Error("not found should be 404")
+	}
+	if HTTPStatus(io.EOF) != http.StatusInternalServerError {
+		t.Error("unknown should be 500")
+	}
+	if CodeFromHTTPStatus(http.StatusTooManyRequests) != codes.ResourceExhausted {
+		t.Error("429 should be resource exhausted")
+	}
+	if CodeFromHTTPStatus(http.StatusTeapot) != codes.FailedPrecondition {
+		t.Error("4xx should be failed precondition")
+	}
+}
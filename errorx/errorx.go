@@ -14,6 +14,18 @@
 //
 // 3)包装error，并附带额外的信息
 // Wrap / Wrapf / WrapWithStack / WrapfWithStack
+//
+// 4)带错误码的error，可以用errors.Is匹配，并且可以与grpc status/http status互相转换
+// NewCode / NewCodef / WrapCode / Code / ToGRPCStatus / FromGRPCStatus / HTTPStatus
+//
+// 5)聚合多个error，errors.Is/As会遍历所有成员，每个成员保留各自的stack
+// Multi / Combine / Append / Errors
+//
+// 6)结构化日志，error实现了zapcore.ObjectMarshaler，可以把消息、cause链、错误码和堆栈以结构化字段输出
+// ZapField / ZapNamedField / LogObject / SetLogFrameFilters / SkipRuntimeFrames / SkipVendorFrames
+//
+// 7)把panic转换为带堆栈的error
+// Recover / SafeRun / SafeGo
 
 package errorx
 
@@ -77,7 +89,8 @@ func (f *fundamental) Format(s fmt.State, verb rune) {
 func hasBeenWithStack(err error) bool {
 	for err != nil {
 		switch err.(type) {
-		case *withStack, *fundamental:
+		// members of Multi keep their own stacks
+		case *withStack, *fundamental, *PanicError, *Multi:
 			return true
 		}
 		err = Unwrap(err)
@@ -249,6 +262,10 @@ func GetFullStack(err error) string {
 			return errT.getFullStackStr()
 		case *fundamental:
 			return errT.getFullStackStr()
+		case *PanicError:
+			return errT.getFullStackStr()
+		case *Multi:
+			return errT.getFullStackStr()
 		}
 		err = Unwrap(err)
 	}
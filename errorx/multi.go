@@ -0,0 +1,144 @@
+package errorx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Multi aggregates multiple errors, it's useful for batch operations which report several failures.
+// Each member keeps its own stack, errors.Is/As traverse all members by "Unwrap() []error".
+// The zero value is ready to use, but it's not thread safe.
+//
+//	var me errorx.Multi
+//	for _, key := range keys {
+//		me.Append(del(key))
+//	}
+//	return me.ErrorOrNil()
+type Multi struct {
+	errs []error
+}
+
+// Append adds errors to Multi, nil errors(including typed nil *Multi) are skipped and *Multi members are flattened.
+func (m *Multi) Append(errs ...error) {
+	for _, err := range errs {
+		switch v := err.(type) {
+		case nil:
+		case *Multi:
+			if v != nil {
+				m.errs = append(m.errs, v.errs...)
+			}
+		default:
+			m.errs = append(m.errs, err)
+		}
+	}
+}
+
+// Len returns the number of errors.
+func (m *Multi) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.errs)
+}
+
+// Errors returns a copy of all errors.
+func (m *Multi) Errors() []error {
+	if m == nil {
+		return nil
+	}
+	var errs = make([]error, len(m.errs))
+	copy(errs, m.errs)
+	return errs
+}
+
+// ErrorOrNil returns nil if Multi is empty, otherwise returns Multi itself.
+func (m *Multi) ErrorOrNil() error {
+	if m == nil || len(m.errs) == 0 {
+		return nil
+	}
+	return m
+}
+
+func (m *Multi) Error() string {
+	switch len(m.errs) {
+	case 0:
+		return "no error"
+	case 1:
+		return m.errs[0].Error()
+	}
+	var buf bytes.Buffer
+	buf.WriteString(strconv.Itoa(len(m.errs)))
+	buf.WriteString(" errors occurred: ")
+	for i, err := range m.errs {
+		if i > 0 {
+			buf.WriteString("; ")
+		}
+		buf.WriteString(err.Error())
+	}
+	return buf.String()
+}
+
+// Unwrap provides compatibility for Go 1.20 multiple errors.
+func (m *Multi) Unwrap() []error {
+	return m.errs
+}
+
+func (m *Multi) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			_, _ = fmt.Fprintf(s, "%d errors occurred:", len(m.errs))
+			for i, err := range m.errs {
+				_, _ = fmt.Fprintf(s, "\n[%d] %+v", i, err)
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		_, _ = io.WriteString(s, m.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", m.Error())
+	}
+}
+
+// getFullStackStr returns message and full stack of each member
+func (m *Multi) getFullStackStr() string {
+	var buf bytes.Buffer
+	for i, err := range m.errs {
+		_, _ = fmt.Fprintf(&buf, "[%d] %s\n", i, err.Error())
+		buf.WriteString(GetFullStack(err))
+	}
+	return buf.String()
+}
+
+// Combine combines errors into one error, nil errors are skipped.
+// If no error left, it returns nil; if only one error left, it returns the error itself;
+// otherwise it returns a *Multi.
+func Combine(errs ...error) error {
+	var m Multi
+	m.Append(errs...)
+	if len(m.errs) == 1 {
+		return m.errs[0]
+	}
+	return m.ErrorOrNil()
+}
+
+// Append appends errs to err, it's the same as Combine(err, errs...).
+func Append(err error, errs ...error) error {
+	return Combine(append([]error{err}, errs...)...)
+}
+
+// Errors returns members of the first *Multi in err's chain.
+// If err is nil, it returns nil; if there is no *Multi in err's chain, it returns []error{err}.
+func Errors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	var m *Multi
+	if As(err, &m) {
+		return m.Errors()
+	}
+	return []error{err}
+}
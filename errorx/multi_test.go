@@ -0,0 +1,68 @@
+package errorx
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestMulti(t *testing.T) {
+	var m Multi
+	if m.ErrorOrNil() != nil {
+		t.Error("empty multi should be nil")
+	}
+	m.Append(nil, NewWithStack("first"), nil)
+	m.Append(Combine(WrapCode(io.EOF, errUserNotFound), NewWithStack("third")))
+	if m.Len() != 3 {
+		t.Errorf("invalid len:%d", m.Len())
+	}
+
+	var err = WrapWithStack(m.ErrorOrNil(), "batch")
+	if !Is(err, io.EOF) || !Is(err, errUserNotFound) {
+		t.Error("should match member")
+	}
+	var ce *CodeError
+	if !As(err, &ce) || ce.Code() != codes.NotFound {
+		t.Error("should find member code error")
+	}
+	if len(Errors(err)) != 3 {
+		t.Error("should return all members")
+	}
+
+	var stack = GetFullStack(err)
+	if strings.Count(stack, "TestMulti") < 3 {
+		t.Errorf("should keep member stacks:%s", stack)
+	}
+	t.Log(err)
+	t.Logf("%+v", err)
+}
+
+func TestMulti_TypedNil(t *testing.T) {
+	var nilMulti *Multi
+	var err error = nilMulti
+	var m Multi
+	m.Append(err, io.EOF, err)
+	if m.Len() != 1 || !Is(m.ErrorOrNil(), io.EOF) {
+		t.Errorf("typed nil should be skipped:%v", m.ErrorOrNil())
+	}
+	if Combine(err, nil) != nil {
+		t.Error("combine typed nil should be nil")
+	}
+	if nilMulti.Len() != 0 || nilMulti.Errors() != nil || len(Errors(err)) != 0 {
+		t.Error("typed nil should be empty")
+	}
+}
+
+func TestCombine(t *testing.T) {
+	if Combine(nil, nil) != nil {
+		t.Error("should be nil")
+	}
+	if Combine(nil, io.EOF) != io.EOF {
+		t.Error("single error should be itself")
+	}
+	if Append(io.EOF, io.ErrUnexpectedEOF).Error() != "2 errors occurred: EOF; unexpected EOF" {
+		t.Error("invalid message")
+	}
+}
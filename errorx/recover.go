@@ -0,0 +1,101 @@
+package errorx
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// PanicError is the error converted from a panic, it records the stack of the panic point.
+type PanicError struct {
+	value any
+	*stack
+}
+
+// Value returns the value passed to panic.
+func (p *PanicError) Value() any { return p.value }
+
+func (p *PanicError) Error() string { return fmt.Sprintf("panic: %v", p.value) }
+
+// Unwrap returns the panic value if it's an error.
+func (p *PanicError) Unwrap() error {
+	var err, _ = p.value.(error)
+	return err
+}
+
+func (p *PanicError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			_, _ = io.WriteString(s, p.Error())
+			p.stack.Format(s, verb)
+			return
+		}
+		fallthrough
+	case 's':
+		_, _ = io.WriteString(s, p.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", p.Error())
+	}
+}
+
+// Recover converts a panic to *PanicError and stores it to *errp,
+// if *errp is not nil, the panic error is combined with it.
+// Recover must be called directly by defer:
+//
+//	func do() (err error) {
+//		defer errorx.Recover(&err)
+//		...
+//	}
+func Recover(errp *error) {
+	var r = recover()
+	if r == nil {
+		return
+	}
+	var pe = newPanicError(r)
+	if errp != nil {
+		*errp = Combine(*errp, pe)
+	}
+}
+
+// SafeRun runs fn, a panic in fn is returned as *PanicError.
+func SafeRun(fn func()) (err error) {
+	defer Recover(&err)
+	fn()
+	return nil
+}
+
+// SafeGo runs fn in a new go routine, a panic in fn is converted to *PanicError then passed to onPanic.
+// If onPanic is nil, the panic is swallowed.
+func SafeGo(fn func(), onPanic func(err error)) {
+	go func() {
+		var err = SafeRun(fn)
+		if err != nil && onPanic != nil {
+			onPanic(err)
+		}
+	}()
+}
+
+// newPanicError creates PanicError, it must be called in the deferred function directly.
+func newPanicError(r any) *PanicError {
+	return &PanicError{
+		value: r,
+		stack: panicCallers(),
+	}
+}
+
+// panicCallers records the stack from the panic point, frames of recover/runtime.gopanic are dropped.
+func panicCallers() *stack {
+	const depth = 64
+	var pcs [depth]uintptr
+	var n = runtime.Callers(3, pcs[:])
+	var skip int
+	for i := 0; i < n; i++ {
+		if Frame(pcs[i]).name() == "runtime.gopanic" {
+			skip = i + 1
+			break
+		}
+	}
+	var st stack = pcs[skip:n]
+	return &st
+}
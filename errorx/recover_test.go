@@ -0,0 +1,45 @@
+package errorx
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func panicFn() {
+	panic(io.EOF)
+}
+
+func recoverFn() (err error) {
+	defer Recover(&err)
+	panicFn()
+	return nil
+}
+
+func TestRecover(t *testing.T) {
+	var err = recoverFn()
+	var pe *PanicError
+	if !As(err, &pe) || pe.Value() != io.EOF {
+		t.Fatalf("should be panic error:%+v", err)
+	}
+	if !Is(err, io.EOF) {
+		t.Error("should unwrap panic value")
+	}
+	var stack = GetFullStack(err)
+	if !strings.Contains(stack, "panicFn") || strings.Contains(stack, "gopanic") {
+		t.Errorf("stack should start from panic point:%s", stack)
+	}
+	t.Logf("%+v", err)
+}
+
+func TestSafeGo(t *testing.T) {
+	if SafeRun(func() {}) != nil {
+		t.Error("should be nil")
+	}
+	var ch = make(chan error, 1)
+	SafeGo(func() { panic("boom") }, func(err error) { ch <- err })
+	var err = <-ch
+	if err.Error() != "panic: boom" {
+		t.Errorf("invalid panic error:%v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package errorx
+
+import (
+	"path"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// FrameFilter decides whether a stack frame is skipped in structured log.
+// funcName -- full function name, e.g. "github.com/pinealctx/neptune/errorx.New"
+// file -- full path of source file
+type FrameFilter func(funcName string, file string) (skip bool)
+
+var (
+	_logFrameFilters atomic.Pointer[[]FrameFilter]
+)
+
+// SkipRuntimeFrames skips go runtime and testing frames.
+func SkipRuntimeFrames(funcName string, _ string) bool {
+	return strings.HasPrefix(funcName, "runtime.") || strings.HasPrefix(funcName, "testing.")
+}
+
+// SkipVendorFrames skips frames in vendor directory or go module cache.
+func SkipVendorFrames(_ string, file string) bool {
+	return strings.Contains(file, "/vendor/") || strings.Contains(file, "/pkg/mod/")
+}
+
+// SetLogFrameFilters setup default frame filters used by LogObject.
+func SetLogFrameFilters(filters ...FrameFilter) {
+	_logFrameFilters.Store(&filters)
+}
+
+// LogObject wraps any error as zapcore.ObjectMarshaler with frame filters.
+// If no filter is given, the default filters setup by SetLogFrameFilters are used.
+// The error types implement zapcore.ObjectMarshaler with the default filters,
+// LogObject is only needed to use other filters or to log a non errorx error.
+//
+//	ulog.Error("save.fail", zap.Object("error", errorx.LogObject(err, errorx.SkipRuntimeFrames)))
+func LogObject(err error, filters ...FrameFilter) zapcore.ObjectMarshaler {
+	if len(filters) == 0 {
+		filters = loadLogFrameFilters()
+	}
+	return &logObject{err: err, filters: filters}
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (f *fundamental) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return marshalLogObject(enc, f, loadLogFrameFilters())
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (w *withStack) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return marshalLogObject(enc, w, loadLogFrameFilters())
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (w *withMessage) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return marshalLogObject(enc, w, loadLogFrameFilters())
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (e *CodeError) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return marshalLogObject(enc, e, loadLogFrameFilters())
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (p *PanicError) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return marshalLogObject(enc, p, loadLogFrameFilters())
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (m *Multi) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return marshalLogObject(enc, m, loadLogFrameFilters())
+}
+
+// ZapField structured error field with key "error", nil error is skipped.
+// It works for any error and accepts frame filters, zap.Error(err) still emits error text.
+//
+//	ulog.Error("save.fail", errorx.ZapField(err))
+func ZapField(err error, filters ...FrameFilter) zap.Field {
+	return ZapNamedField("error", err, filters...)
+}
+
+// ZapNamedField structured error field with key, nil error is skipped.
+func ZapNamedField(key string, err error, filters ...FrameFilter) zap.Field {
+	if err == nil {
+		return zap.Skip()
+	}
+	return zap.Object(key, LogObject(err, filters...))
+}
+
+type logObject struct {
+	err     error
+	filters []FrameFilter
+}
+
+func (o *logObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return marshalLogObject(enc, o.err, o.filters)
+}
+
+// marshalLogObject encodes error as:
+// msg -- error message
+// code/reason/metadata -- if there is a CodeError in chain
+// causes -- messages of error chain, duplicated adjacent messages are merged
+// stack -- frames of the first stack in chain
+// errors -- members if there is a Multi in chain
+func marshalLogObject(enc zapcore.ObjectEncoder, err error, filters []FrameFilter) error {
+	if err == nil {
+		return nil
+	}
+	enc.AddString("msg", err.Error())
+
+	var ce *CodeError
+	if As(err, &ce) {
+		enc.AddString("code", ce.code.String())
+		if ce.reason != "" {
+			enc.AddString("reason", ce.reason)
+		}
+		if len(ce.metadata) > 0 {
+			_ = enc.AddObject("metadata", stringMap(ce.metadata))
+		}
+	}
+
+	var causes []string
+	var st *stack
+	var multi *Multi
+	for e, first := err, true; e != nil; e, first = Unwrap(e), false {
+		// withStack has the same message as its cause
+		if !first && (len(causes) == 0 || causes[len(causes)-1] != e.Error()) {
+			causes = append(causes, e.Error())
+		}
+		switch v := e.(type) {
+		case *withStack:
+			if st == nil {
+				st = v.stack
+			}
+		case *fundamental:
+			if st == nil {
+				st = v.stack
+			}
+		case *PanicError:
+			if st == nil {
+				st = v.stack
+			}
+		case *Multi:
+			multi = v
+		}
+		if multi != nil {
+			break
+		}
+	}
+	if len(causes) > 0 {
+		_ = enc.AddArray("causes", stringArray(causes))
+	}
+	if st != nil {
+		_ = enc.AddArray("stack", &frameArray{st: st, filters: filters})
+	}
+	if multi != nil {
+		_ = enc.AddArray("errors", &errorArray{errs: multi.errs, filters: filters})
+	}
+	return nil
+}
+
+func loadLogFrameFilters() []FrameFilter {
+	var filters = _logFrameFilters.Load()
+	if filters == nil {
+		return nil
+	}
+	return *filters
+}
+
+type frameArray struct {
+	st      *stack
+	filters []FrameFilter
+}
+
+func (a *frameArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, pc := range *a.st {
+		var f = Frame(pc)
+		var name, file = f.name(), f.file()
+		if skipFrame(a.filters, name, file) {
+			continue
+		}
+		_ = enc.AppendObject(&frameObject{name: name, file: file, line: f.line()})
+	}
+	return nil
+}
+
+type frameObject struct {
+	name string
+	file string
+	line int
+}
+
+func (o *frameObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("func", funcname(o.name))
+	enc.AddString("file", path.Base(o.file))
+	enc.AddInt("line", o.line)
+	return nil
+}
+
+type errorArray struct {
+	errs    []error
+	filters []FrameFilter
+}
+
+func (a *errorArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, err := range a.errs {
+		_ = enc.AppendObject(&logObject{err: err, filters: a.filters})
+	}
+	return nil
+}
+
+type stringArray []string
+
+func (ss stringArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for i := range ss {
+		enc.AppendString(ss[i])
+	}
+	return nil
+}
+
+type stringMap map[string]string
+
+func (m stringMap) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for k, v := range m {
+		enc.AddString(k, v)
+	}
+	return nil
+}
+
+func skipFrame(filters []FrameFilter, name string, file string) bool {
+	for _, filter := range filters {
+		if filter(name, file) {
+			return true
+		}
+	}
+	return false
+}
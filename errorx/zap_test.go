@@ -0,0 +1,84 @@
+package errorx
+
+import (
+	"io"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestMarshalLogObject(t *testing.T) {
+	var err = WrapWithStack(WrapCode(io.EOF, errUserNotFound.WithMeta("uid", "1")), "load.user")
+	var enc = zapcore.NewMapObjectEncoder()
+	var lo, ok = err.(zapcore.ObjectMarshaler)
+	if !ok {
+		t.Fatal("should implement object marshaler")
+	}
+	if e := lo.MarshalLogObject(enc); e != nil {
+		t.Fatal(e)
+	}
+	if enc.Fields["code"] != "NotFound" || enc.Fields["reason"] != "USER_NOT_FOUND" {
+		t.Errorf("invalid code fields:%+v", enc.Fields)
+	}
+	var causes, _ = enc.Fields["causes"].([]any)
+	if len(causes) != 2 {
+		t.Errorf("invalid causes:%+v", enc.Fields["causes"])
+	}
+	var all, _ = enc.Fields["stack"].([]any)
+
+	enc = zapcore.NewMapObjectEncoder()
+	_ = LogObject(err, SkipRuntimeFrames, SkipVendorFrames).MarshalLogObject(enc)
+	var filtered, _ = enc.Fields["stack"].([]any)
+	if len(filtered) == 0 || len(filtered) >= len(all) {
+		t.Errorf("frames should be filtered, all:%d, filtered:%d", len(all), len(filtered))
+	}
+	t.Log(enc.Fields)
+}
+
+func TestMarshalLogObjectMulti(t *testing.T) {
+	var err = Combine(NewWithStack("first"), io.EOF)
+	var enc = zapcore.NewMapObjectEncoder()
+	_ = LogObject(err).MarshalLogObject(enc)
+	var errs, _ = enc.Fields["errors"].([]any)
+	if len(errs) != 2 {
+		t.Errorf("invalid errors:%+v", enc.Fields)
+	}
+	t.Log(enc.Fields)
+}
+
+func TestZapField(t *testing.T) {
+	var err = WrapCode(io.EOF, errUserNotFound)
+	var enc = zapcore.NewMapObjectEncoder()
+	zap.Error(err).AddTo(enc)
+	if _, ok := enc.Fields["error"].(string); !ok {
+		t.Errorf("zap.Error should keep error text:%+v", enc.Fields)
+	}
+
+	enc = zapcore.NewMapObjectEncoder()
+	ZapField(err).AddTo(enc)
+	var obj, _ = enc.Fields["error"].(map[string]any)
+	if obj["reason"] != "USER_NOT_FOUND" || obj["msg"] != err.Error() {
+		t.Errorf("invalid structured field:%+v", enc.Fields)
+	}
+
+	enc = zapcore.NewMapObjectEncoder()
+	zap.Any("error", err).AddTo(enc)
+	obj, _ = enc.Fields["error"].(map[string]any)
+	if obj["reason"] != "USER_NOT_FOUND" || obj["msg"] != err.Error() {
+		t.Errorf("zap.Any should be structured:%+v", enc.Fields)
+	}
+
+	enc = zapcore.NewMapObjectEncoder()
+	ZapField(io.EOF).AddTo(enc)
+	obj, _ = enc.Fields["error"].(map[string]any)
+	if obj["msg"] != io.EOF.Error() {
+		t.Errorf("plain error should be structured:%+v", enc.Fields)
+	}
+
+	enc = zapcore.NewMapObjectEncoder()
+	ZapField(nil).AddTo(enc)
+	if len(enc.Fields) != 0 {
+		t.Errorf("nil error should be skipped:%+v", enc.Fields)
+	}
+}
@@ -0,0 +1,244 @@
+package mpb
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/pinealctx/neptune/errorx"
+)
+
+// batch layout:
+// [frame 0][frame 1]...[frame N-1][end 0][end 1]...[end N-1][N]
+// frame -- a tagged message, the same as MarshalMsg output
+// end -- 4 bytes little endian, end offset of each frame
+// N -- 4 bytes little endian, frame count
+// the index is put at the tail, so the frames can be appended into one buffer without moving.
+
+const (
+	// batchWordSize size of each index word
+	batchWordSize = 4
+	// maxPooledBatchCap batch buffer larger than this will not be put back to pool
+	maxPooledBatchCap = 1024 * 1024
+)
+
+var (
+	_batchPool = sync.Pool{
+		New: func() any {
+			return &BatchWriter{}
+		},
+	}
+	_marshalAppendOpt = proto.MarshalOptions{}
+)
+
+// BatchWriter pack multiple tagged messages into one buffer
+// It's not thread safe.
+type BatchWriter struct {
+	packer *MsgPacker
+	buf    []byte
+	ends   []uint32
+}
+
+// NewBatchWriter get a batch writer from pool
+// call Release to put it back when the packed bytes are no longer used.
+func (x *MsgPacker) NewBatchWriter() *BatchWriter {
+	// nolint : forcetypeassert // I know the type is exactly here
+	var b = _batchPool.Get().(*BatchWriter)
+	b.packer = x
+	return b
+}
+
+// Append marshal a message and append it to the batch
+// support *emptypb.Empty and registered proto message which extends "Fingerprint() uint32"
+func (b *BatchWriter) Append(msg proto.Message) error {
+	switch v := msg.(type) {
+	case FingerprintMsg:
+		var fingerprint = v.Fingerprint()
+		var _, ok = b.packer.genFuncMap[fingerprint]
+		if !ok {
+			return errorx.NewfWithStack("not registered message:%+v",
+				msg.ProtoReflect().Descriptor().FullName())
+		}
+		return b.appendProtoMsg(fingerprint, v)
+	case *emptypb.Empty:
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, EmptyMark)
+		b.ends = append(b.ends, uint32(len(b.buf)))
+		return nil
+	default:
+		return errorx.NewfWithStack("unsupported message:%+v", msg.ProtoReflect().Descriptor().FullName())
+	}
+}
+
+// AppendError append an error to the batch, it uses a specific tag "ErrMark"
+// errorx.CodeError in err's chain is marshaled with its code, reason and metadata, the same as MarshalError.
+func (b *BatchWriter) AppendError(err error) error {
+	return b.appendProtoMsg(ErrMark, errorx.ToGRPCStatus(err).Proto())
+}
+
+// Count return message count in batch
+func (b *BatchWriter) Count() int {
+	return len(b.ends)
+}
+
+// Bytes return the packed batch
+// The slice aliases the internal buffer, it's valid only until the next Append/Reset/Release.
+func (b *BatchWriter) Bytes() []byte {
+	var n = len(b.buf)
+	var out = b.buf
+	for _, end := range b.ends {
+		out = binary.LittleEndian.AppendUint32(out, end)
+	}
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(b.ends)))
+	// keep the index out of frames, the next Append overwrites it.
+	b.buf = out[:n]
+	return out
+}
+
+// Reset clear all messages, but keep the allocated buffer
+func (b *BatchWriter) Reset() {
+	b.buf = b.buf[:0]
+	b.ends = b.ends[:0]
+}
+
+// Release reset the batch writer and put it back to pool
+// The batch writer and any bytes returned by Bytes should not be used after Release.
+func (b *BatchWriter) Release() {
+	if cap(b.buf) > maxPooledBatchCap {
+		return
+	}
+	b.Reset()
+	b.packer = nil
+	_batchPool.Put(b)
+}
+
+// appendProtoMsg append a tagged proto message
+// if marshal failed, the buffer is rolled back.
+func (b *BatchWriter) appendProtoMsg(fingerprint uint32, msg proto.Message) error {
+	var n = len(b.buf)
+	var buf = binary.LittleEndian.AppendUint32(b.buf, fingerprint)
+	var err error
+	buf, err = _marshalAppendOpt.MarshalAppend(buf, msg)
+	if err != nil {
+		b.buf = buf[:n]
+		return errorx.WrapWithStack(err, "marshal proto msg")
+	}
+	b.buf = buf
+	b.ends = append(b.ends, uint32(len(b.buf)))
+	return nil
+}
+
+// BatchIter iterate a packed batch lazily without copy
+// It's not thread safe.
+type BatchIter struct {
+	packer *MsgPacker
+	//frames area
+	frames []byte
+	//index area
+	index []byte
+	//frame count
+	count int
+	//current position, -1 means before first
+	pos int
+	//current frame
+	frame []byte
+}
+
+// NewBatchIter create an iterator on packed batch
+// The iterator references data, data should not be modified while iterating.
+func (x *MsgPacker) NewBatchIter(data []byte) (*BatchIter, error) {
+	var size = len(data)
+	if size < batchWordSize {
+		return nil, errorx.NewWithStack("invalid batch length")
+	}
+	var count = int(binary.LittleEndian.Uint32(data[size-batchWordSize:]))
+	var indexSize = count * batchWordSize
+	if count < 0 || indexSize > size-batchWordSize {
+		return nil, errorx.NewfWithStack("invalid batch count:%d", count)
+	}
+	var framesSize = size - batchWordSize - indexSize
+	var it = &BatchIter{
+		packer: x,
+		frames: data[:framesSize],
+		index:  data[framesSize : size-batchWordSize],
+		count:  count,
+		pos:    -1,
+	}
+
+	var prev uint32
+	for i := 0; i < count; i++ {
+		var end = it.end(i)
+		if end < prev+batchWordSize || int(end) > framesSize {
+			return nil, errorx.NewfWithStack("invalid batch index:%d, end:%d", i, end)
+		}
+		prev = end
+	}
+	if int(prev) != framesSize {
+		return nil, errorx.NewfWithStack("invalid batch frames size:%d, expected:%d", framesSize, prev)
+	}
+	return it, nil
+}
+
+// Count return message count in batch
+func (it *BatchIter) Count() int {
+	return it.count
+}
+
+// Next move to next message, return false if no more message
+func (it *BatchIter) Next() bool {
+	if it.pos+1 >= it.count {
+		it.pos = it.count
+		it.frame = nil
+		return false
+	}
+	it.pos++
+	it.frame = it.At(it.pos)
+	return true
+}
+
+// Index return the position of current message
+func (it *BatchIter) Index() int {
+	return it.pos
+}
+
+// Frame return current tagged message bytes, the same as MarshalMsg output
+// The slice aliases the batch data.
+func (it *BatchIter) Frame() []byte {
+	return it.frame
+}
+
+// Msg unmarshal current message, see MsgPacker.UnmarshalMsg
+func (it *BatchIter) Msg() (proto.Message, error) {
+	return it.packer.UnmarshalMsg(it.frame)
+}
+
+// Response unmarshal current message as rpc response, see MsgPacker.UnmarshalResponse
+func (it *BatchIter) Response() (msg proto.Message, msgErr error, sysErr error) {
+	return it.packer.UnmarshalResponse(it.frame)
+}
+
+// At return tagged message bytes at index i
+// It panics if i is out of range.
+func (it *BatchIter) At(i int) []byte {
+	var start uint32
+	if i > 0 {
+		start = it.end(i - 1)
+	}
+	return it.frames[start:it.end(i)]
+}
+
+// end return end offset of frame i
+func (it *BatchIter) end(i int) uint32 {
+	return binary.LittleEndian.Uint32(it.index[i*batchWordSize:])
+}
+
+// NewBatchWriter get a batch writer from pool
+func NewBatchWriter() *BatchWriter {
+	return _defaultMsgPacker.NewBatchWriter()
+}
+
+// NewBatchIter create an iterator on packed batch
+func NewBatchIter(data []byte) (*BatchIter, error) {
+	return _defaultMsgPacker.NewBatchIter(data)
+}
@@ -0,0 +1,153 @@
+package mpb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/pinealctx/neptune/errorx"
+)
+
+func TestBatch_RoundTrip(t *testing.T) {
+	var x = newTestPacker()
+	var w = x.NewBatchWriter()
+	defer w.Release()
+
+	var codeErr = errorx.NewCode(codes.NotFound, "USER_NOT_FOUND", "user.not.found").WithMeta("uid", "1")
+	var msgs = []proto.Message{newStr("a"), &emptypb.Empty{}, nil, newInt(3), newStr("")}
+	for _, m := range msgs {
+		var err error
+		if m == nil {
+			err = w.AppendError(errorx.WrapCode(errorx.New("db"), codeErr))
+		} else {
+			err = w.Append(m)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Append(&testStr{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewMsgPacker().NewBatchWriter().Append(newStr("a")); err == nil {
+		t.Fatal("not registered message should fail")
+	}
+	msgs = append(msgs, newStr(""))
+	if w.Count() != len(msgs) {
+		t.Fatalf("unexpected count %d", w.Count())
+	}
+
+	var data = w.Bytes()
+	var it, err = x.NewBatchIter(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it.Count() != len(msgs) {
+		t.Fatalf("unexpected count %d", it.Count())
+	}
+	for it.Next() {
+		var i = it.Index()
+		if !bytes.Equal(it.Frame(), it.At(i)) {
+			t.Fatalf("frame %d mismatch", i)
+		}
+		var msg, msgErr, sysErr = it.Response()
+		if sysErr != nil {
+			t.Fatal(sysErr)
+		}
+		if msgs[i] == nil {
+			var ds = GetErrDetails(msgErr)
+			if ds.Code != codes.NotFound || ds.Reason() != "USER_NOT_FOUND" || ds.Metadata()["uid"] != "1" {
+				t.Fatalf("error details are lost %+v", ds)
+			}
+			continue
+		}
+		if !proto.Equal(msg, msgs[i]) {
+			t.Fatalf("message %d mismatch %+v", i, msg)
+		}
+		var single, _ = x.MarshalMsg(msgs[i])
+		if !bytes.Equal(it.Frame(), single) {
+			t.Fatalf("frame %d is not the same as MarshalMsg", i)
+		}
+	}
+	if it.Next() || it.Index() != len(msgs) || it.Frame() != nil {
+		t.Fatal("iterator should be done")
+	}
+
+	//append after Bytes, then reset
+	if err = w.Append(newInt(4)); err != nil {
+		t.Fatal(err)
+	}
+	if it, err = x.NewBatchIter(w.Bytes()); err != nil || it.Count() != len(msgs)+1 {
+		t.Fatalf("append after bytes failed %v", err)
+	}
+	w.Reset()
+	if it, err = x.NewBatchIter(w.Bytes()); err != nil || it.Count() != 0 || it.Next() {
+		t.Fatalf("empty batch failed %v", err)
+	}
+}
+
+func TestBatchIter_Corrupted(t *testing.T) {
+	var x = newTestPacker()
+	var w = x.NewBatchWriter()
+	defer w.Release()
+	_ = w.Append(newStr("a"))
+	_ = w.Append(newStr("bb"))
+	var valid = bytes.Clone(w.Bytes())
+	var size = len(valid)
+	//layout: frames(4+3, 4+4) ends(7, 15) count(2)
+	var cases = []struct {
+		name   string
+		mutate func(b []byte) []byte
+	}{
+		{name: "too short", mutate: func(b []byte) []byte { return b[:3] }},
+		{name: "count too large", mutate: func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[size-4:], 100)
+			return b
+		}},
+		{name: "count overflow", mutate: func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[size-4:], 0xFFFFFFFF)
+			return b
+		}},
+		{name: "frame too small", mutate: func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[size-12:], 3)
+			return b
+		}},
+		{name: "ends decreasing", mutate: func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[size-12:], 15)
+			binary.LittleEndian.PutUint32(b[size-8:], 7)
+			return b
+		}},
+		{name: "end out of frames", mutate: func(b []byte) []byte {
+			binary.LittleEndian.PutUint32(b[size-8:], 100)
+			return b
+		}},
+		{name: "frames size mismatch", mutate: func(b []byte) []byte {
+			return append(b[:size-12:size-12], 0, 7, 0, 0, 0, 15, 0, 0, 0, 2, 0, 0, 0)
+		}},
+		{name: "truncated", mutate: func(b []byte) []byte { return b[1:] }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var it, err = x.NewBatchIter(c.mutate(bytes.Clone(valid)))
+			if err == nil {
+				t.Fatalf("it should fail, count %d", it.Count())
+			}
+		})
+	}
+
+	//corrupted frame body is reported by Msg
+	var data = bytes.Clone(valid)
+	data[4] = 0xFF
+	var it, err = x.NewBatchIter(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it.Next()
+	if _, err = it.Msg(); err == nil {
+		t.Fatal("corrupted frame should fail")
+	}
+}
@@ -0,0 +1,211 @@
+package mpb
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/pinealctx/neptune/errorx"
+)
+
+// ErrDetail error detail option, it appends a detail message to the error status
+type ErrDetail func() proto.Message
+
+// WithDetailMsg attach any proto message as error detail
+func WithDetailMsg(msg proto.Message) ErrDetail {
+	return func() proto.Message {
+		return msg
+	}
+}
+
+// WithBadRequest attach field violations as error detail
+// violations -- field, description pairs
+func WithBadRequest(violations ...*errdetails.BadRequest_FieldViolation) ErrDetail {
+	return func() proto.Message {
+		return &errdetails.BadRequest{FieldViolations: violations}
+	}
+}
+
+// FieldViolation create a field violation for WithBadRequest
+func FieldViolation(field string, description string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: description,
+	}
+}
+
+// WithRetryInfo attach retry delay as error detail
+func WithRetryInfo(delay time.Duration) ErrDetail {
+	return func() proto.Message {
+		return &errdetails.RetryInfo{RetryDelay: durationpb.New(delay)}
+	}
+}
+
+// WithErrorInfo attach error reason, domain and metadata as error detail
+func WithErrorInfo(reason string, domain string, metadata map[string]string) ErrDetail {
+	return func() proto.Message {
+		return &errdetails.ErrorInfo{
+			Reason:   reason,
+			Domain:   domain,
+			Metadata: metadata,
+		}
+	}
+}
+
+// WithLocalizedMessage attach a localized message as error detail
+// locale -- e.g. "en-US", "zh-CN"
+func WithLocalizedMessage(locale string, message string) ErrDetail {
+	return func() proto.Message {
+		return &errdetails.LocalizedMessage{
+			Locale:  locale,
+			Message: message,
+		}
+	}
+}
+
+// WithErrDetails attach details to an error, return a grpc status error
+// msgErr -- status error with details, nil if err is nil
+// sysErr -- attach details error
+// If err is not a grpc status error, it's converted as codes.Unknown status.
+func WithErrDetails(err error, details ...ErrDetail) (msgErr error, sysErr error) {
+	if err == nil {
+		return nil, nil
+	}
+	var st *status.Status
+	st, sysErr = attachDetails(err, details)
+	if sysErr != nil {
+		return nil, sysErr
+	}
+	return st.Err(), nil
+}
+
+// MarshalErrorWithDetails marshal error with details, it uses a specific tag "ErrMark"
+func MarshalErrorWithDetails(err error, details ...ErrDetail) ([]byte, error) {
+	var st, sysErr = attachDetails(err, details)
+	if sysErr != nil {
+		return nil, sysErr
+	}
+	return marshalProtoMsg(ErrMark, st.Proto())
+}
+
+// ErrDetails details carried by a grpc status error
+type ErrDetails struct {
+	// Code status code
+	Code codes.Code
+	// Message status message
+	Message string
+	// BadRequest field violations, nil if absent
+	BadRequest *errdetails.BadRequest
+	// RetryInfo retry delay, nil if absent
+	RetryInfo *errdetails.RetryInfo
+	// ErrorInfo reason/domain/metadata, nil if absent
+	ErrorInfo *errdetails.ErrorInfo
+	// LocalizedMessages all localized messages
+	LocalizedMessages []*errdetails.LocalizedMessage
+	// Others details which are not listed above
+	Others []proto.Message
+}
+
+// GetErrDetails read details from an error, such as msgErr returned by UnmarshalResponse
+// If err is nil, it returns nil.
+// Unknown detail types which can not be resolved are ignored.
+func GetErrDetails(err error) *ErrDetails {
+	if err == nil {
+		return nil
+	}
+	var st = status.Convert(err)
+	var ds = &ErrDetails{
+		Code:    st.Code(),
+		Message: st.Message(),
+	}
+	for _, a := range st.Proto().GetDetails() {
+		var m, e = a.UnmarshalNew()
+		if e != nil {
+			continue
+		}
+		switch v := m.(type) {
+		case *errdetails.BadRequest:
+			ds.BadRequest = v
+		case *errdetails.RetryInfo:
+			ds.RetryInfo = v
+		case *errdetails.ErrorInfo:
+			ds.ErrorInfo = v
+		case *errdetails.LocalizedMessage:
+			ds.LocalizedMessages = append(ds.LocalizedMessages, v)
+		default:
+			ds.Others = append(ds.Others, m)
+		}
+	}
+	return ds
+}
+
+// RetryDelay return retry delay if exists
+func (d *ErrDetails) RetryDelay() (time.Duration, bool) {
+	if d == nil || d.RetryInfo == nil || d.RetryInfo.GetRetryDelay() == nil {
+		return 0, false
+	}
+	return d.RetryInfo.GetRetryDelay().AsDuration(), true
+}
+
+// Reason return reason in ErrorInfo, empty if absent
+func (d *ErrDetails) Reason() string {
+	if d == nil {
+		return ""
+	}
+	return d.ErrorInfo.GetReason()
+}
+
+// Metadata return metadata in ErrorInfo, nil if absent
+func (d *ErrDetails) Metadata() map[string]string {
+	if d == nil {
+		return nil
+	}
+	return d.ErrorInfo.GetMetadata()
+}
+
+// FieldViolations return field violations in BadRequest
+func (d *ErrDetails) FieldViolations() []*errdetails.BadRequest_FieldViolation {
+	if d == nil {
+		return nil
+	}
+	return d.BadRequest.GetFieldViolations()
+}
+
+// LocalizedMessage return localized message of locale
+// if no such locale, return the status message.
+func (d *ErrDetails) LocalizedMessage(locale string) string {
+	if d == nil {
+		return ""
+	}
+	for _, lm := range d.LocalizedMessages {
+		if lm.GetLocale() == locale {
+			return lm.GetMessage()
+		}
+	}
+	return d.Message
+}
+
+// attachDetails convert error to status, then append details
+func attachDetails(err error, details []ErrDetail) (*status.Status, error) {
+	var st = errorx.ToGRPCStatus(err)
+	if len(details) == 0 {
+		return st, nil
+	}
+	if st.Code() == codes.OK {
+		return nil, errorx.NewWithStack("no error details with code OK")
+	}
+	var p = st.Proto()
+	for _, detail := range details {
+		var a, e = anypb.New(detail())
+		if e != nil {
+			return nil, errorx.WrapWithStack(e, "marshal error detail")
+		}
+		p.Details = append(p.Details, a)
+	}
+	return status.FromProto(p), nil
+}
@@ -0,0 +1,116 @@
+package mpb
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/pinealctx/neptune/errorx"
+)
+
+func TestErrDetails(t *testing.T) {
+	var details = []ErrDetail{
+		WithBadRequest(FieldViolation("name", "empty"), FieldViolation("age", "negative")),
+		WithRetryInfo(time.Second),
+		WithErrorInfo("INVALID", "user", map[string]string{"k": "v"}),
+		WithLocalizedMessage("en-US", "invalid user"),
+		WithLocalizedMessage("zh-CN", "无效用户"),
+		WithDetailMsg(wrapperspb.String("x")),
+	}
+	var msgErr, sysErr = WithErrDetails(status.Error(codes.InvalidArgument, "invalid"), details...)
+	if sysErr != nil {
+		t.Fatal(sysErr)
+	}
+	var data, err = MarshalErrorWithDetails(status.Error(codes.InvalidArgument, "invalid"), details...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unmarshalErr error
+	_, unmarshalErr, err = UnmarshalResponse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, e := range map[string]error{"status": msgErr, "unmarshal": unmarshalErr} {
+		t.Run(name, func(t *testing.T) {
+			var ds = GetErrDetails(e)
+			if ds.Code != codes.InvalidArgument || ds.Message != "invalid" {
+				t.Fatalf("unexpected status %v %s", ds.Code, ds.Message)
+			}
+			var fvs = ds.FieldViolations()
+			if len(fvs) != 2 || fvs[0].GetField() != "name" || fvs[1].GetDescription() != "negative" {
+				t.Fatalf("unexpected field violations %+v", fvs)
+			}
+			if d, ok := ds.RetryDelay(); !ok || d != time.Second {
+				t.Fatalf("unexpected retry delay %v", d)
+			}
+			if ds.Reason() != "INVALID" || ds.ErrorInfo.GetDomain() != "user" || ds.Metadata()["k"] != "v" {
+				t.Fatalf("unexpected error info %+v", ds.ErrorInfo)
+			}
+			var locales = []struct {
+				locale string
+				want   string
+			}{
+				{locale: "en-US", want: "invalid user"},
+				{locale: "zh-CN", want: "无效用户"},
+				{locale: "fr-FR", want: "invalid"},
+			}
+			for _, l := range locales {
+				if got := ds.LocalizedMessage(l.locale); got != l.want {
+					t.Fatalf("locale %s: got %s, want %s", l.locale, got, l.want)
+				}
+			}
+			if len(ds.Others) != 1 || !proto.Equal(ds.Others[0], wrapperspb.String("x")) {
+				t.Fatalf("unexpected others %+v", ds.Others)
+			}
+		})
+	}
+}
+
+func TestErrDetails_CodeError(t *testing.T) {
+	var ce = errorx.NewCode(codes.NotFound, "USER_NOT_FOUND", "user.not.found").WithMeta("uid", "1")
+	var msgErr, sysErr = WithErrDetails(ce, WithRetryInfo(time.Minute))
+	if sysErr != nil {
+		t.Fatal(sysErr)
+	}
+	var ds = GetErrDetails(msgErr)
+	if ds.Code != codes.NotFound || ds.Reason() != "USER_NOT_FOUND" || ds.Metadata()["uid"] != "1" {
+		t.Fatalf("code error is lost %+v", ds)
+	}
+	if d, ok := ds.RetryDelay(); !ok || d != time.Minute {
+		t.Fatalf("unexpected retry delay %v", d)
+	}
+
+	//plain error is unknown without details
+	ds = GetErrDetails(errorx.New("x"))
+	if ds.Code != codes.Unknown || ds.Reason() != "" || ds.BadRequest != nil {
+		t.Fatalf("unexpected details %+v", ds)
+	}
+	if _, ok := ds.RetryDelay(); ok {
+		t.Fatal("there should be no retry delay")
+	}
+}
+
+func TestErrDetails_Invalid(t *testing.T) {
+	if msgErr, sysErr := WithErrDetails(nil, WithRetryInfo(time.Second)); msgErr != nil || sysErr != nil {
+		t.Fatalf("nil error should be nil %v %v", msgErr, sysErr)
+	}
+	if _, sysErr := WithErrDetails(errorx.NewCode(codes.OK, "", "ok"), WithRetryInfo(time.Second)); sysErr == nil {
+		t.Fatal("details with code OK should fail")
+	}
+	if _, sysErr := WithErrDetails(errorx.New("x"), WithDetailMsg(nil)); sysErr == nil {
+		t.Fatal("nil detail should fail")
+	}
+
+	var ds *ErrDetails = GetErrDetails(nil)
+	if ds != nil || ds.Reason() != "" || ds.Metadata() != nil || ds.FieldViolations() != nil || ds.LocalizedMessage("en") != "" {
+		t.Fatal("nil details should be empty")
+	}
+	if _, ok := ds.RetryDelay(); ok {
+		t.Fatal("nil details should have no retry delay")
+	}
+}
@@ -0,0 +1,204 @@
+package mpb
+
+import (
+	"encoding/json"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/pinealctx/neptune/errorx"
+)
+
+var (
+	_errMsgName   = (&spb.Status{}).ProtoReflect().Descriptor().FullName()
+	_emptyMsgName = _emptyMsg.ProtoReflect().Descriptor().FullName()
+
+	_jsonMarshalOpt   = protojson.MarshalOptions{}
+	_jsonUnmarshalOpt = protojson.UnmarshalOptions{}
+)
+
+// JSONMsg json representation of a tagged message
+// Type -- full name of the proto message, e.g. "pkg.Msg"
+// Fingerprint -- message fingerprint, ErrMark for *Status, EmptyMark for *emptypb.Empty
+// Body -- message body encoded by protojson
+type JSONMsg struct {
+	Type        string          `json:"type"`
+	Fingerprint uint32          `json:"fingerprint"`
+	Body        json.RawMessage `json:"body"`
+}
+
+// MsgToJSON marshal a proto message to json representation
+// support *Status(as error), *emptypb.Empty and registered messages
+func (x *MsgPacker) MsgToJSON(msg proto.Message) ([]byte, error) {
+	var fingerprint, err = x.fingerprintOf(msg)
+	if err != nil {
+		return nil, err
+	}
+	return marshalJSONMsg(fingerprint, msg)
+}
+
+// JSONToMsg unmarshal a proto message from json representation
+// if both "type" and "fingerprint" are present, they must refer to the same message.
+func (x *MsgPacker) JSONToMsg(data []byte) (proto.Message, error) {
+	var jm JSONMsg
+	var err = json.Unmarshal(data, &jm)
+	if err != nil {
+		return nil, errorx.WrapWithStack(err, "unmarshal json msg")
+	}
+	var fingerprint uint32
+	fingerprint, err = x.resolveJSONMsg(&jm)
+	if err != nil {
+		return nil, err
+	}
+
+	var m proto.Message
+	switch fingerprint {
+	case ErrMark:
+		m = &spb.Status{}
+	case EmptyMark:
+		m = &emptypb.Empty{}
+	default:
+		m = x.genFuncMap[fingerprint]()
+	}
+	if len(jm.Body) == 0 {
+		return m, nil
+	}
+	err = _jsonUnmarshalOpt.Unmarshal(jm.Body, m)
+	if err != nil {
+		return nil, errorx.WrapfWithStack(err, "unmarshal json body:%s", m.ProtoReflect().Descriptor().FullName())
+	}
+	return m, nil
+}
+
+// BinaryToJSON convert tagged binary data to json representation
+func (x *MsgPacker) BinaryToJSON(data []byte) ([]byte, error) {
+	var preProc = unmarshalEmptyMsgOrErr(data)
+	if preProc.sysErr != nil {
+		return nil, preProc.sysErr
+	}
+	if preProc.union != nil {
+		return marshalJSONMsg(preProc.fingerprint, preProc.union)
+	}
+	var m, err = x.unmarshalRegisteredMsg(preProc.fingerprint, data[4:])
+	if err != nil {
+		return nil, err
+	}
+	return marshalJSONMsg(preProc.fingerprint, m)
+}
+
+// JSONToBinary convert json representation to tagged binary data
+func (x *MsgPacker) JSONToBinary(data []byte) ([]byte, error) {
+	var m, err = x.JSONToMsg(data)
+	if err != nil {
+		return nil, err
+	}
+	var fingerprint uint32
+	fingerprint, err = x.fingerprintOf(m)
+	if err != nil {
+		return nil, err
+	}
+	if fingerprint == EmptyMark {
+		return _emptyData, nil
+	}
+	return marshalProtoMsg(fingerprint, m)
+}
+
+// fingerprintOf get fingerprint of a supported message
+func (x *MsgPacker) fingerprintOf(msg proto.Message) (uint32, error) {
+	switch v := msg.(type) {
+	case FingerprintMsg:
+		var fingerprint = v.Fingerprint()
+		var _, ok = x.genFuncMap[fingerprint]
+		if !ok {
+			return 0, errorx.NewfWithStack("not registered message:%+v",
+				msg.ProtoReflect().Descriptor().FullName())
+		}
+		return fingerprint, nil
+	case *emptypb.Empty:
+		return EmptyMark, nil
+	case *spb.Status:
+		return ErrMark, nil
+	default:
+		return 0, errorx.NewfWithStack("unsupported message:%+v", msg.ProtoReflect().Descriptor().FullName())
+	}
+}
+
+// resolveJSONMsg resolve fingerprint from "type" and "fingerprint"
+func (x *MsgPacker) resolveJSONMsg(jm *JSONMsg) (uint32, error) {
+	if jm.Type == "" {
+		return x.checkFingerprint(jm.Fingerprint)
+	}
+
+	var fingerprint uint32
+	var name = protoreflect.FullName(jm.Type)
+	switch name {
+	case _errMsgName:
+		fingerprint = ErrMark
+	case _emptyMsgName:
+		fingerprint = EmptyMark
+	default:
+		var ok bool
+		fingerprint, ok = x.nameMap[name]
+		if !ok {
+			return 0, errorx.NewfWithStack("type:%s, not.found", jm.Type)
+		}
+	}
+	// fingerprint 0 is ErrMark, it can not tell from absent, so only check a non-zero one
+	if jm.Fingerprint != 0 && jm.Fingerprint != fingerprint {
+		return 0, errorx.NewfWithStack("type:%s, fingerprint:%x, mismatch", jm.Type, jm.Fingerprint)
+	}
+	return fingerprint, nil
+}
+
+// checkFingerprint check a fingerprint is supported
+func (x *MsgPacker) checkFingerprint(fingerprint uint32) (uint32, error) {
+	if fingerprint == ErrMark || fingerprint == EmptyMark {
+		return fingerprint, nil
+	}
+	var _, ok = x.genFuncMap[fingerprint]
+	if !ok {
+		return 0, errorx.NewfWithStack("fingerprint:%x, not.found", fingerprint)
+	}
+	return fingerprint, nil
+}
+
+// MsgToJSON marshal a proto message to json representation
+func MsgToJSON(msg proto.Message) ([]byte, error) {
+	return _defaultMsgPacker.MsgToJSON(msg)
+}
+
+// JSONToMsg unmarshal a proto message from json representation
+func JSONToMsg(data []byte) (proto.Message, error) {
+	return _defaultMsgPacker.JSONToMsg(data)
+}
+
+// BinaryToJSON convert tagged binary data to json representation
+func BinaryToJSON(data []byte) ([]byte, error) {
+	return _defaultMsgPacker.BinaryToJSON(data)
+}
+
+// JSONToBinary convert json representation to tagged binary data
+func JSONToBinary(data []byte) ([]byte, error) {
+	return _defaultMsgPacker.JSONToBinary(data)
+}
+
+func marshalJSONMsg(fingerprint uint32, msg proto.Message) ([]byte, error) {
+	var body, err = _jsonMarshalOpt.Marshal(msg)
+	if err != nil {
+		return nil, errorx.WrapWithStack(err, "marshal json body")
+	}
+	var jm = JSONMsg{
+		Type:        string(msg.ProtoReflect().Descriptor().FullName()),
+		Fingerprint: fingerprint,
+		Body:        body,
+	}
+	var data []byte
+	data, err = json.Marshal(&jm)
+	if err != nil {
+		return nil, errorx.WrapWithStack(err, "marshal json msg")
+	}
+	return data, nil
+}
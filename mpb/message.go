@@ -7,6 +7,7 @@ import (
 	spb "google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/known/emptypb"
 
 	"github.com/pinealctx/neptune/errorx"
@@ -36,6 +37,7 @@ type FingerprintMsg interface {
 type MsgPacker struct {
 	genFuncMap map[uint32]func() proto.Message
 	typeMap    map[reflect.Type]struct{}
+	nameMap    map[protoreflect.FullName]uint32
 }
 
 // NewMsgPacker new MsgPacker instance
@@ -43,6 +45,7 @@ func NewMsgPacker() *MsgPacker {
 	return &MsgPacker{
 		genFuncMap: make(map[uint32]func() proto.Message),
 		typeMap:    make(map[reflect.Type]struct{}),
+		nameMap:    make(map[protoreflect.FullName]uint32),
 	}
 }
 
@@ -80,6 +83,7 @@ func (x *MsgPacker) RegisterGenerator(genFn func() proto.Message) {
 
 	x.genFuncMap[fingerprint] = genFn
 	x.typeMap[reflectT] = struct{}{}
+	x.nameMap[mo.ProtoReflect().Descriptor().FullName()] = fingerprint
 }
 
 // MarshalMsg marshal a protobuf message
@@ -176,9 +180,9 @@ func MarshalMsg(msg proto.Message) ([]byte, error) {
 }
 
 // MarshalError err can marshal/unmarshal, it uses a specific tag "ErrMark"
+// errorx.CodeError in err's chain is marshaled with its code, reason and metadata.
 func MarshalError(err error) ([]byte, error) {
-	var v, _ = status.FromError(err)
-	return marshalProtoMsg(ErrMark, v.Proto())
+	return marshalProtoMsg(ErrMark, errorx.ToGRPCStatus(err).Proto())
 }
 
 // MarshalEmpty tag an empty message
@@ -0,0 +1,161 @@
+package mpb
+
+import (
+	"bytes"
+	"testing"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type testStr struct {
+	wrapperspb.StringValue
+}
+
+func (*testStr) Fingerprint() uint32 { return 100 }
+
+type testInt struct {
+	wrapperspb.Int64Value
+}
+
+func (*testInt) Fingerprint() uint32 { return 101 }
+
+func newStr(v string) *testStr {
+	return &testStr{StringValue: wrapperspb.StringValue{Value: v}}
+}
+
+func newInt(v int64) *testInt {
+	return &testInt{Int64Value: wrapperspb.Int64Value{Value: v}}
+}
+
+func newTestPacker() *MsgPacker {
+	var x = NewMsgPacker()
+	x.RegisterGenerator(func() proto.Message { return &testStr{} })
+	x.RegisterGenerator(func() proto.Message { return &testInt{} })
+	return x
+}
+
+func TestMsgPacker_Marshal(t *testing.T) {
+	var x = newTestPacker()
+	var data, err = x.MarshalMsg(newStr("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg proto.Message
+	msg, err = x.UnmarshalMsg(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := msg.(*testStr); !ok || v.Value != "a" {
+		t.Fatalf("unexpected msg %+v", msg)
+	}
+
+	if _, err = x.MarshalMsg(wrapperspb.String("a")); err == nil {
+		t.Fatal("message without fingerprint should fail")
+	}
+	if _, err = NewMsgPacker().MarshalMsg(newStr("a")); err == nil {
+		t.Fatal("not registered message should fail")
+	}
+	if _, err = x.UnmarshalMsg([]byte{1, 2}); err == nil {
+		t.Fatal("short data should fail")
+	}
+	if _, err = x.UnmarshalMsg([]byte{9, 9, 9, 9}); err == nil {
+		t.Fatal("unknown fingerprint should fail")
+	}
+}
+
+func TestMsgPacker_JSON(t *testing.T) {
+	var x = newTestPacker()
+	var errData, _ = MarshalError(status.Error(codes.NotFound, "not.found"))
+	var cases = []struct {
+		name string
+		msg  proto.Message
+		bin  []byte
+	}{
+		{name: "registered", msg: newStr("a")},
+		{name: "registered int", msg: newInt(7)},
+		{name: "empty", msg: &emptypb.Empty{}, bin: MarshalEmpty()},
+		{name: "error", msg: status.New(codes.NotFound, "not.found").Proto(), bin: errData},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var js, err = x.MsgToJSON(c.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var msg proto.Message
+			msg, err = x.JSONToMsg(js)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(msg, c.msg) {
+				t.Fatalf("json round trip mismatch %s", js)
+			}
+
+			var bin = c.bin
+			if bin == nil {
+				bin, err = x.MarshalMsg(c.msg)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			var bjs []byte
+			bjs, err = x.BinaryToJSON(bin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(bjs, js) {
+				t.Fatalf("binary to json mismatch %s %s", bjs, js)
+			}
+			var out []byte
+			out, err = x.JSONToBinary(bjs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, bin) {
+				t.Fatalf("json to binary mismatch %x %x", out, bin)
+			}
+		})
+	}
+}
+
+func TestMsgPacker_JSONToMsg(t *testing.T) {
+	var x = newTestPacker()
+	var cases = []struct {
+		name string
+		data string
+		ok   bool
+		want proto.Message
+	}{
+		{name: "by type", data: `{"type":"google.protobuf.StringValue","body":"a"}`, ok: true, want: newStr("a")},
+		{name: "by fingerprint", data: `{"fingerprint":101,"body":"3"}`, ok: true, want: newInt(3)},
+		{name: "no body", data: `{"type":"google.protobuf.Empty"}`, ok: true, want: &emptypb.Empty{}},
+		{name: "error", data: `{"type":"google.rpc.Status","body":{"code":5}}`, ok: true, want: &spb.Status{Code: 5}},
+		{name: "invalid json", data: `{`},
+		{name: "unknown type", data: `{"type":"x.Unknown"}`},
+		{name: "unknown fingerprint", data: `{"fingerprint":999}`},
+		{name: "fingerprint mismatch", data: `{"type":"google.protobuf.StringValue","fingerprint":101}`},
+		{name: "invalid body", data: `{"fingerprint":101,"body":"x"}`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var msg, err = x.JSONToMsg([]byte(c.data))
+			if !c.ok {
+				if err == nil {
+					t.Fatalf("it should fail, got %+v", msg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(msg, c.want) {
+				t.Fatalf("unexpected msg %+v", msg)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net"
 	"sync"
 
+	"github.com/pinealctx/neptune/errorx"
 	"github.com/pinealctx/neptune/ulog"
 	"go.uber.org/zap"
 )
@@ -53,45 +54,30 @@ func (x *ConnHandler) AddExitHook(hook ConnExitEvent) {
 // Start : start connection handler
 func (x *ConnHandler) Start() {
 	x.startOnce.Do(func() {
-		go func() {
-			defer func() {
-				r := recover()
-				if r != nil {
-					ulog.Error("ConnHandler.loopReceive.recover", zap.Any("panic", r), zap.Object("metaInfo", x.iConnIO.MetaInfo()),
-						zap.Stack("stack"))
-				}
-			}()
+		errorx.SafeGo(func() {
 			defer x.Exit()
 
 			conn := x.iConnIO.Conn()
 			x.loopReceive(conn)
-		}()
-
-		go func() {
-			defer func() {
-				r := recover()
-				if r != nil {
-					ulog.Error("ConnHandler.loopSend.recover", zap.Any("panic", r), zap.Object("metaInfo", x.iConnIO.MetaInfo()),
-						zap.Stack("stack"))
-				}
-			}()
+		}, func(err error) {
+			ulog.Error("ConnHandler.loopReceive.recover", ulog.ErrorObj(err), zap.Object("metaInfo", x.iConnIO.MetaInfo()))
+		})
+
+		errorx.SafeGo(func() {
 			defer x.Exit()
 
 			x.loopSend()
-		}()
+		}, func(err error) {
+			ulog.Error("ConnHandler.loopSend.recover", ulog.ErrorObj(err), zap.Object("metaInfo", x.iConnIO.MetaInfo()))
+		})
 
 		for _, hook := range x.startHooks {
-			func() {
-				defer func() {
-					r := recover()
-					if r != nil {
-						ulog.Error("ConnHandler.startHook.recover", zap.Any("panic", r), zap.Object("metaInfo", x.iConnIO.MetaInfo()),
-							zap.Stack("stack"))
-					}
-				}()
-
+			err := errorx.SafeRun(func() {
 				hook(x.iConnIO)
-			}()
+			})
+			if err != nil {
+				ulog.Error("ConnHandler.startHook.recover", ulog.ErrorObj(err), zap.Object("metaInfo", x.iConnIO.MetaInfo()))
+			}
 		}
 	})
 }
@@ -104,16 +90,12 @@ func (x *ConnHandler) Exit() {
 			ulog.Error("ConnHandler.iConnIO.Close", zap.Error(err), zap.Object("metaInfo", x.iConnIO.MetaInfo()))
 		}
 		for _, hook := range x.exitHooks {
-			func() {
-				defer func() {
-					r := recover()
-					if r != nil {
-						ulog.Error("ConnHandler.exitHook.recover", zap.Any("panic", r), zap.Object("metaInfo", x.iConnIO.MetaInfo()),
-							zap.Stack("stack"))
-					}
-				}()
+			err = errorx.SafeRun(func() {
 				hook(x.iConnIO)
-			}()
+			})
+			if err != nil {
+				ulog.Error("ConnHandler.exitHook.recover", ulog.ErrorObj(err), zap.Object("metaInfo", x.iConnIO.MetaInfo()))
+			}
 		}
 	})
 }
@@ -129,7 +111,7 @@ func (x *ConnHandler) loopReceive(conn net.Conn) {
 			ulog.Info("ConnHandler.loopReceive.connReader", zap.Object("metaInfo", x.iConnIO.MetaInfo()), zap.Error(err))
 			break
 		}
-		err = x.readProcessor(x.iConnIO, buf)
+		err = x.process(buf)
 		if err != nil {
 			ulog.Info("ConnHandler.loopReceive.readProcessor", zap.Object("metaInfo", x.iConnIO.MetaInfo()), zap.Error(err))
 			break
@@ -137,6 +119,12 @@ func (x *ConnHandler) loopReceive(conn net.Conn) {
 	}
 }
 
+// process calls read processor, a panic in read processor is returned as error
+func (x *ConnHandler) process(buf []byte) (err error) {
+	defer errorx.Recover(&err)
+	return x.readProcessor(x.iConnIO, buf)
+}
+
 // loopSend is the internal sending loop (required, NOT goroutine-safe)
 // WARNING: This method is ONLY called by ConnHandler internally.
 // NEVER call this method from external code.
@@ -0,0 +1,400 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/pinealctx/neptune/ulog"
+)
+
+const (
+	//DefaultConfigTimeout default timeout of loading config
+	DefaultConfigTimeout = 3 * time.Second
+	//DefaultConfigBackoff default backoff of re-watching config
+	DefaultConfigBackoff = time.Second
+)
+
+// Decoder decode node value to v
+type Decoder[T any] func(data []byte, v *T) error
+
+// JSONDecoder decode json value
+func JSONDecoder[T any]() Decoder[T] {
+	return func(data []byte, v *T) error {
+		return json.Unmarshal(data, v)
+	}
+}
+
+// TOMLDecoder decode toml value
+func TOMLDecoder[T any]() Decoder[T] {
+	return func(data []byte, v *T) error {
+		return toml.Unmarshal(data, v)
+	}
+}
+
+// ProtoDecoder decode proto binary value, *T must be a proto message, otherwise it panics
+func ProtoDecoder[T any]() Decoder[T] {
+	mustProto[T]()
+	return func(data []byte, v *T) error {
+		return proto.Unmarshal(data, any(v).(proto.Message))
+	}
+}
+
+// ProtoJSONDecoder decode proto json value, *T must be a proto message, otherwise it panics
+func ProtoJSONDecoder[T any]() Decoder[T] {
+	mustProto[T]()
+	return func(data []byte, v *T) error {
+		return protojson.Unmarshal(data, any(v).(proto.Message))
+	}
+}
+
+// mustProto panic if *T is not a proto message
+func mustProto[T any]() {
+	if _, ok := any(new(T)).(proto.Message); !ok {
+		panic("etcd.config.decoder.not.proto.message")
+	}
+}
+
+// ChangeFunc config change callback
+// name -- node path relative to watched dir, it's empty for a key watcher
+// prev -- previous value, nil if it's created
+// cur -- current value, nil if it's deleted
+type ChangeFunc[T any] func(name string, prev, cur *T)
+
+// ConfigSnapshot snapshot of config values, it's immutable and the values must not be modified
+type ConfigSnapshot[T any] struct {
+	//Revision etcd revision of snapshot
+	Revision int64
+	//Values decoded values by name, name is node path relative to watched dir, it's empty for a key watcher
+	Values map[string]*T
+
+	//mod revision of values by name
+	revisions map[string]int64
+}
+
+// config watcher option
+type _ConfigOption struct {
+	//timeout of loading config
+	timeout time.Duration
+	//backoff of re-watching config
+	backoff time.Duration
+}
+
+// ConfigOption setup config watcher option
+type ConfigOption func(*_ConfigOption)
+
+// WithConfigTimeout set timeout of loading config
+func WithConfigTimeout(timeout time.Duration) ConfigOption {
+	return func(o *_ConfigOption) {
+		o.timeout = timeout
+	}
+}
+
+// WithConfigBackoff set backoff of re-watching config if etcd is unavailable
+func WithConfigBackoff(backoff time.Duration) ConfigOption {
+	return func(o *_ConfigOption) {
+		o.backoff = backoff
+	}
+}
+
+// range config option
+func rangeConfigOption(opts ...ConfigOption) *_ConfigOption {
+	var o = &_ConfigOption{
+		timeout: DefaultConfigTimeout,
+		backoff: DefaultConfigBackoff,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// ConfigWatcher typed config watcher, it loads a dir or a key, decodes values and keeps a snapshot.
+// The snapshot is swapped atomically on each change, and change callbacks are called after swapping.
+// If watch is broken, it's re-setup from a full load, the changes during re-setup are got by diff.
+type ConfigWatcher[T any] struct {
+	//watched path relative to client root
+	nodePath string
+	//full path of key, or full path of dir with "/"
+	prefix string
+	//watch a key or dir
+	key bool
+	//decoder
+	decode Decoder[T]
+	//raw watcher
+	watcher *Watcher
+
+	//current snapshot
+	snapshot atomic.Pointer[ConfigSnapshot[T]]
+
+	//guard callbacks
+	mu       sync.Mutex
+	onChange []ChangeFunc[T]
+
+	//closed when first snapshot is loaded
+	ready     chan struct{}
+	readyOnce sync.Once
+	//called in watching go routine when first snapshot is loaded, before change callbacks
+	onReady func()
+	//closed when loop is out
+	done    chan struct{}
+	started atomic.Bool
+}
+
+// NewConfigWatcher new config watcher of a dir, each node under the dir is a config value
+func NewConfigWatcher[T any](cli *Client, nodePath string, decode Decoder[T], opts ...ConfigOption) *ConfigWatcher[T] {
+	var o = rangeConfigOption(opts...)
+	var w = newConfigWatcher(nodePath, decode)
+	w.prefix = path.Join(cli.root, nodePath) + "/"
+	w.watcher = NewWatcher(cli, nodePath, o.timeout, o.backoff)
+	w.watcher.emptySnapshot = true
+	return w
+}
+
+// NewKeyConfigWatcher new config watcher of a key, the value name is empty
+func NewKeyConfigWatcher[T any](cli *Client, nodePath string, decode Decoder[T], opts ...ConfigOption) *ConfigWatcher[T] {
+	var o = rangeConfigOption(opts...)
+	var w = newConfigWatcher(nodePath, decode)
+	w.prefix = path.Join(cli.root, nodePath)
+	w.key = true
+	w.watcher = NewKeyWatcher(cli, nodePath, o.timeout, o.backoff)
+	w.watcher.emptySnapshot = true
+	return w
+}
+
+// new config watcher with empty snapshot
+func newConfigWatcher[T any](nodePath string, decode Decoder[T]) *ConfigWatcher[T] {
+	var w = &ConfigWatcher[T]{
+		nodePath: nodePath,
+		decode:   decode,
+		ready:    make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	w.snapshot.Store(&ConfigSnapshot[T]{
+		Values:    map[string]*T{},
+		revisions: map[string]int64{},
+	})
+	return w
+}
+
+// OnChange add change callback, callbacks are called one by one in watching go routine.
+// Changes of first load are notified too if callback is added before Start.
+func (w *ConfigWatcher[T]) OnChange(fn ChangeFunc[T]) {
+	w.mu.Lock()
+	w.onChange = append(w.onChange, fn)
+	w.mu.Unlock()
+}
+
+// Start start watching, even if the dir or key does not exist
+func (w *ConfigWatcher[T]) Start() {
+	if w.started.Swap(true) {
+		return
+	}
+	w.watcher.StartWatchDir()
+	go w.loop()
+}
+
+// Stop stop watching and wait until callbacks are out
+func (w *ConfigWatcher[T]) Stop() {
+	if !w.started.Load() {
+		return
+	}
+	w.watcher.Stop()
+	<-w.done
+}
+
+// Ready return a channel which is closed when first snapshot is loaded
+func (w *ConfigWatcher[T]) Ready() <-chan struct{} {
+	return w.ready
+}
+
+// Wait wait until first snapshot is loaded or ctx is done
+func (w *ConfigWatcher[T]) Wait(ctx context.Context) error {
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		return convertErr(w.nodePath, ctx.Err())
+	}
+}
+
+// Snapshot get current snapshot
+func (w *ConfigWatcher[T]) Snapshot() *ConfigSnapshot[T] {
+	return w.snapshot.Load()
+}
+
+// Get get value by name in current snapshot
+func (w *ConfigWatcher[T]) Get(name string) (*T, bool) {
+	var v, ok = w.snapshot.Load().Values[name]
+	return v, ok
+}
+
+// Value get value of a key watcher
+func (w *ConfigWatcher[T]) Value() (*T, bool) {
+	return w.Get("")
+}
+
+// config change
+type configChange[T any] struct {
+	name string
+	prev *T
+	cur  *T
+}
+
+// loop apply events until watcher is stopped
+func (w *ConfigWatcher[T]) loop() {
+	defer close(w.done)
+	for event := range w.watcher.DirChan() {
+		w.apply(event)
+	}
+}
+
+// apply event to a new snapshot, swap it then notify changes
+func (w *ConfigWatcher[T]) apply(event DirEvent) {
+	var prev = w.snapshot.Load()
+	var (
+		cur     *ConfigSnapshot[T]
+		changes []configChange[T]
+	)
+	if event.Snapshot {
+		cur, changes = w.applySnapshot(prev, event)
+	} else {
+		cur, changes = w.applyEvents(prev, event)
+	}
+	w.snapshot.Store(cur)
+	if event.Snapshot {
+		w.readyOnce.Do(func() {
+			close(w.ready)
+			if w.onReady != nil {
+				w.onReady()
+			}
+		})
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	var callbacks = slices.Clone(w.onChange)
+	w.mu.Unlock()
+	for _, c := range changes {
+		for _, fn := range callbacks {
+			fn(c.name, c.prev, c.cur)
+		}
+	}
+}
+
+// applySnapshot : rebuild snapshot from full content, unchanged values are reused, changes are got by diff
+func (w *ConfigWatcher[T]) applySnapshot(prev *ConfigSnapshot[T], event DirEvent) (*ConfigSnapshot[T], []configChange[T]) {
+	var cur = &ConfigSnapshot[T]{
+		Revision:  max(prev.Revision, event.Revision),
+		Values:    make(map[string]*T, len(event.Events)),
+		revisions: make(map[string]int64, len(event.Events)),
+	}
+	var changes []configChange[T]
+	for _, ev := range event.Events {
+		var name, ok = w.name(ev.Kv.Key)
+		if !ok {
+			continue
+		}
+		var old, exist = prev.Values[name]
+		if exist && prev.revisions[name] == ev.Kv.ModRevision {
+			cur.Values[name], cur.revisions[name] = old, ev.Kv.ModRevision
+			continue
+		}
+		var v, err = w.decodeKV(ev.Kv)
+		if err != nil {
+			if exist {
+				//keep previous value
+				cur.Values[name], cur.revisions[name] = old, prev.revisions[name]
+			}
+			continue
+		}
+		cur.Values[name], cur.revisions[name] = v, ev.Kv.ModRevision
+		changes = append(changes, configChange[T]{name: name, prev: old, cur: v})
+	}
+	for name, old := range prev.Values {
+		if _, ok := cur.Values[name]; !ok {
+			changes = append(changes, configChange[T]{name: name, prev: old})
+		}
+	}
+	slices.SortFunc(changes, func(a, b configChange[T]) int {
+		return strings.Compare(a.name, b.name)
+	})
+	return cur, changes
+}
+
+// applyEvents : apply incremental events on a copy of previous snapshot, events already applied are skipped
+func (w *ConfigWatcher[T]) applyEvents(prev *ConfigSnapshot[T], event DirEvent) (*ConfigSnapshot[T], []configChange[T]) {
+	var cur = &ConfigSnapshot[T]{
+		Revision:  max(prev.Revision, event.Revision),
+		Values:    make(map[string]*T, len(prev.Values)+len(event.Events)),
+		revisions: make(map[string]int64, len(prev.Values)+len(event.Events)),
+	}
+	for name, v := range prev.Values {
+		cur.Values[name], cur.revisions[name] = v, prev.revisions[name]
+	}
+	var changes []configChange[T]
+	for _, ev := range event.Events {
+		if ev.Kv.ModRevision <= prev.Revision {
+			continue
+		}
+		var name, ok = w.name(ev.Kv.Key)
+		if !ok {
+			continue
+		}
+		var old, exist = cur.Values[name]
+		switch ev.Type {
+		case mvccpb.PUT:
+			var v, err = w.decodeKV(ev.Kv)
+			if err != nil {
+				continue
+			}
+			cur.Values[name], cur.revisions[name] = v, ev.Kv.ModRevision
+			changes = append(changes, configChange[T]{name: name, prev: old, cur: v})
+		case mvccpb.DELETE:
+			if !exist {
+				continue
+			}
+			delete(cur.Values, name)
+			delete(cur.revisions, name)
+			changes = append(changes, configChange[T]{name: name, prev: old})
+		}
+	}
+	return cur, changes
+}
+
+// name : value name of key, false if key is not under watched path
+func (w *ConfigWatcher[T]) name(key []byte) (string, bool) {
+	var k = string(key)
+	if w.key {
+		return "", k == w.prefix
+	}
+	if !strings.HasPrefix(k, w.prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(k, w.prefix), true
+}
+
+// decodeKV : decode value, log error if failed
+func (w *ConfigWatcher[T]) decodeKV(kv *mvccpb.KeyValue) (*T, error) {
+	var v = new(T)
+	var err = w.decode(kv.Value, v)
+	if err != nil {
+		ulog.Error("etcd.config.decode.error", zap.ByteString("key", kv.Key),
+			zap.Int64("revision", kv.ModRevision), zap.Error(err))
+		return nil, err
+	}
+	return v, nil
+}
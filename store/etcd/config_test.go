@@ -0,0 +1,187 @@
+package etcd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/client/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type testConfig struct {
+	Name string `json:"name" toml:"name"`
+	Port int    `json:"port" toml:"port"`
+}
+
+type testChange struct {
+	name string
+	prev *testConfig
+	cur  *testConfig
+}
+
+func watchChanges(w *ConfigWatcher[testConfig]) <-chan testChange {
+	var ch = make(chan testChange, 16)
+	w.OnChange(func(name string, prev, cur *testConfig) {
+		ch <- testChange{name: name, prev: prev, cur: cur}
+	})
+	return ch
+}
+
+func nextChange(t *testing.T, ch <-chan testChange) testChange {
+	t.Helper()
+	select {
+	case c := <-ch:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait change timeout")
+	}
+	return testChange{}
+}
+
+func TestConfigWatcher_Dir(t *testing.T) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	var c = newTestClient(t, "/config")
+	c.DeleteDir(ctx, "svc", IgnoreRevision)
+	c.Put(ctx, "svc/a", []byte(`{"name":"a","port":1}`), IgnoreRevision)
+	c.Put(ctx, "svc/b", []byte(`{"name":"b","port":2}`), IgnoreRevision)
+
+	var w = NewConfigWatcher(c, "svc", JSONDecoder[testConfig]())
+	var ch = watchChanges(w)
+	w.Start()
+	defer w.Stop()
+	if err := w.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ch1, ch2 := nextChange(t, ch), nextChange(t, ch); ch1.name != "a" || ch1.prev != nil || ch1.cur.Port != 1 ||
+		ch2.name != "b" || ch2.cur.Port != 2 {
+		t.Fatalf("unexpected first load %+v %+v", ch1, ch2)
+	}
+	if v, ok := w.Get("a"); !ok || v.Name != "a" {
+		t.Fatalf("unexpected a %+v", v)
+	}
+
+	c.Put(ctx, "svc/a", []byte(`{"name":"a","port":10}`), IgnoreRevision)
+	if chg := nextChange(t, ch); chg.name != "a" || chg.prev.Port != 1 || chg.cur.Port != 10 {
+		t.Fatalf("unexpected change %+v", chg)
+	}
+	//bad value is ignored, previous value is kept
+	c.Put(ctx, "svc/a", []byte(`{bad`), IgnoreRevision)
+	c.Delete(ctx, "svc/b", IgnoreRevision)
+	if chg := nextChange(t, ch); chg.name != "b" || chg.prev.Port != 2 || chg.cur != nil {
+		t.Fatalf("unexpected change %+v", chg)
+	}
+	c.Put(ctx, "svc/c/d", []byte(`{"name":"d","port":4}`), IgnoreRevision)
+	if chg := nextChange(t, ch); chg.name != "c/d" || chg.prev != nil || chg.cur.Port != 4 {
+		t.Fatalf("unexpected change %+v", chg)
+	}
+	var s = w.Snapshot()
+	if len(s.Values) != 2 || s.Values["a"].Port != 10 || s.Values["c/d"].Port != 4 {
+		t.Fatalf("unexpected snapshot %+v", s.Values)
+	}
+}
+
+func TestConfigWatcher_Key(t *testing.T) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	var c = newTestClient(t, "/config")
+	c.Delete(ctx, "key.toml", IgnoreRevision)
+	var w = NewKeyConfigWatcher(c, "key.toml", TOMLDecoder[testConfig]())
+	var ch = watchChanges(w)
+	w.Start()
+	defer w.Stop()
+	if err := w.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := w.Value(); ok {
+		t.Fatal("key should not exist")
+	}
+	c.Put(ctx, "key.toml", []byte("name = \"k\"\nport = 8\n"), IgnoreRevision)
+	if chg := nextChange(t, ch); chg.name != "" || chg.prev != nil || chg.cur.Name != "k" || chg.cur.Port != 8 {
+		t.Fatalf("unexpected change %+v", chg)
+	}
+	//sibling key is not watched
+	c.Put(ctx, "key.toml2", []byte("name = \"x\""), IgnoreRevision)
+	c.Delete(ctx, "key.toml", IgnoreRevision)
+	if chg := nextChange(t, ch); chg.name != "" || chg.prev.Name != "k" || chg.cur != nil {
+		t.Fatalf("unexpected change %+v", chg)
+	}
+}
+
+func TestConfigWatcher_Reload(t *testing.T) {
+	var w = newConfigWatcher("svc", JSONDecoder[testConfig]())
+	w.prefix = "/config/svc/"
+	var ch = watchChanges(w)
+	var kv = func(name string, value string, rev int64) *clientv3.Event {
+		return &clientv3.Event{Type: mvccpb.PUT, Kv: &mvccpb.KeyValue{
+			Key: []byte(w.prefix + name), Value: []byte(value), ModRevision: rev}}
+	}
+	w.apply(DirEvent{Revision: 10, Snapshot: true, Events: []*clientv3.Event{
+		kv("a", `{"port":1}`, 5), kv("b", `{"port":2}`, 6),
+	}})
+	var a, _ = w.Get("a")
+	<-ch
+	<-ch
+
+	//replayed event is skipped
+	w.apply(DirEvent{Revision: 10, Events: []*clientv3.Event{kv("a", `{"port":100}`, 5)}})
+	//reload after re-setup: a is unchanged, b is deleted, c is created
+	w.apply(DirEvent{Revision: 20, Snapshot: true, Events: []*clientv3.Event{
+		kv("a", `{"port":1}`, 5), kv("c", `{"port":3}`, 15),
+	}})
+	if chg := nextChange(t, ch); chg.name != "b" || chg.cur != nil {
+		t.Fatalf("unexpected change %+v", chg)
+	}
+	if chg := nextChange(t, ch); chg.name != "c" || chg.cur.Port != 3 {
+		t.Fatalf("unexpected change %+v", chg)
+	}
+	if len(ch) != 0 {
+		t.Fatal("unexpected change")
+	}
+	var s = w.Snapshot()
+	if s.Revision != 20 || len(s.Values) != 2 || s.Values["a"] != a {
+		t.Fatalf("unexpected snapshot %+v", s)
+	}
+}
+
+func TestWatcher_EmptyDir(t *testing.T) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	var c = newTestClient(t, "/config")
+	c.DeleteDir(ctx, "empty", IgnoreRevision)
+
+	//plain watcher skips empty dir, the first event is the put
+	var w = NewWatcher(c, "empty", time.Second*3, time.Millisecond*100)
+	w.StartWatchDir()
+	defer w.Stop()
+	time.Sleep(200 * time.Millisecond)
+	c.Put(ctx, "empty/a", []byte("1"), IgnoreRevision)
+	select {
+	case e := <-w.DirChan():
+		if e.Snapshot || e.Err != nil || len(e.Events) != 1 || string(e.Events[0].Kv.Key) != "/config/empty/a" {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	case <-ctx.Done():
+		t.Fatal("wait event timeout")
+	}
+}
+
+func TestProtoDecoder(t *testing.T) {
+	var data, _ = proto.Marshal(wrapperspb.String("x"))
+	var v wrapperspb.StringValue
+	if err := ProtoDecoder[wrapperspb.StringValue]()(data, &v); err != nil || v.Value != "x" {
+		t.Fatalf("unexpected %v %v", v.Value, err)
+	}
+	if err := ProtoJSONDecoder[wrapperspb.StringValue]()([]byte(`"y"`), &v); err != nil || v.Value != "y" {
+		t.Fatalf("unexpected %v %v", v.Value, err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("it should panic")
+		}
+	}()
+	ProtoDecoder[testConfig]()
+}
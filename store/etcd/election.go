@@ -0,0 +1,223 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+const (
+	//DefaultObserveBackoff default backoff of observing leader if etcd is unavailable
+	DefaultObserveBackoff = time.Second
+)
+
+// Leader leader of election
+type Leader struct {
+	//Key leader key, empty if there is no leader
+	Key string
+	//Value leader value
+	Value []byte
+	//Revision create revision of leader key
+	Revision int64
+}
+
+// Election leader election under client root path
+// Campaign creates a session(lease with keepalive), the leadership is held until Resign or the session is lost.
+type Election struct {
+	cli    *Client
+	prefix string
+	option *_SessionOption
+
+	//serialize campaigns
+	campaignMu sync.Mutex
+	//guard election and holding
+	mu       sync.Mutex
+	election *concurrency.Election
+	holding  *holding
+}
+
+// NewElection new election, name is the election path under client root path
+func (c *Client) NewElection(name string, opts ...SessionOption) *Election {
+	return &Election{
+		cli:    c,
+		prefix: path.Join(c.root, name),
+		option: rangeSessionOption(opts...),
+	}
+}
+
+// Campaign block until it's elected as leader, or ctx is done.
+// value -- leader value, such as address of the instance
+func (e *Election) Campaign(ctx context.Context, value string) error {
+	e.campaignMu.Lock()
+	defer e.campaignMu.Unlock()
+	if e.IsLeader() {
+		return nil
+	}
+	var s, err = e.cli.newSession(ctx, e.option)
+	if err != nil {
+		return convertErr(e.prefix, err)
+	}
+	var el = concurrency.NewElection(s, e.prefix)
+	err = el.Campaign(ctx, value)
+	if err != nil {
+		_ = s.Close()
+		return convertErr(e.prefix, err)
+	}
+	e.mu.Lock()
+	e.election = el
+	e.holding = e.cli.newHolding(s, el.Key(), el.Rev(), NotLeader, e.lost)
+	e.mu.Unlock()
+	return nil
+}
+
+// Proclaim update leader value without another election
+func (e *Election) Proclaim(ctx context.Context, value string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.holding == nil {
+		return genErr(e.prefix, NotLeader)
+	}
+	var err = e.election.Proclaim(ctx, value)
+	if errors.Is(err, concurrency.ErrElectionNotLeader) {
+		return genErr(e.prefix, NotLeader)
+	}
+	return convertErr(e.prefix, err)
+}
+
+// Resign give up leadership, the session is closed and the lease is revoked
+func (e *Election) Resign(ctx context.Context) error {
+	e.mu.Lock()
+	var el, h = e.election, e.holding
+	e.election, e.holding = nil, nil
+	e.mu.Unlock()
+	if h == nil {
+		return genErr(e.prefix, NotLeader)
+	}
+	var err = el.Resign(ctx)
+	var cErr = h.release()
+	if err == nil {
+		err = cErr
+	}
+	return convertErr(e.prefix, err)
+}
+
+// IsLeader return true if it's leader now
+func (e *Election) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.holding != nil
+}
+
+// Done return a channel which is closed when leadership ends(lost or resigned).
+// It's closed already if it's not leader.
+func (e *Election) Done() <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.holding == nil {
+		var ch = make(chan struct{})
+		close(ch)
+		return ch
+	}
+	return e.holding.done
+}
+
+// Leader get current leader, return NodeNotFound error if there is no leader
+func (e *Election) Leader(ctx context.Context) (Leader, error) {
+	var rsp, err = e.cli.eCli.Get(ctx, e.prefix+"/", clientv3.WithFirstCreate()...)
+	if err != nil {
+		return Leader{}, convertErr(e.prefix, err)
+	}
+	if len(rsp.Kvs) == 0 {
+		return Leader{}, genErr(e.prefix, NodeNotFound)
+	}
+	return Leader{
+		Key:      string(rsp.Kvs[0].Key),
+		Value:    rsp.Kvs[0].Value,
+		Revision: rsp.Kvs[0].CreateRevision,
+	}, nil
+}
+
+// Observe watch leader changes until ctx is done, the channel is closed then.
+// Current leader is sent first, a Leader with empty key is sent if there is no leader.
+// It does not need to campaign, any instance can observe.
+func (e *Election) Observe(ctx context.Context) <-chan Leader {
+	var ch = make(chan Leader)
+	go e.observe(ctx, ch)
+	return ch
+}
+
+// observe loop: get leader, send it if changed, then wait for any change under prefix
+func (e *Election) observe(ctx context.Context, ch chan<- Leader) {
+	defer close(ch)
+	var last *Leader
+	for {
+		var rsp, err = e.cli.eCli.Get(ctx, e.prefix+"/", clientv3.WithFirstCreate()...)
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(DefaultObserveBackoff):
+			}
+			continue
+		}
+		var leader Leader
+		if len(rsp.Kvs) > 0 {
+			leader = Leader{
+				Key:      string(rsp.Kvs[0].Key),
+				Value:    rsp.Kvs[0].Value,
+				Revision: rsp.Kvs[0].CreateRevision,
+			}
+		}
+		if last == nil || last.Key != leader.Key || string(last.Value) != string(leader.Value) {
+			select {
+			case ch <- leader:
+			case <-ctx.Done():
+				return
+			}
+			last = &leader
+		}
+		if !e.waitChange(ctx, rsp.Header.Revision) {
+			return
+		}
+	}
+}
+
+// waitChange wait for any change under prefix after revision, return false if ctx is done
+func (e *Election) waitChange(ctx context.Context, revision int64) bool {
+	var wCtx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	var wch = e.cli.eCli.Watch(clientv3.WithRequireLeader(wCtx), e.prefix+"/",
+		clientv3.WithPrefix(), clientv3.WithRev(revision+1))
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case rsp, ok := <-wch:
+			if !ok || rsp.Err() != nil || len(rsp.Events) > 0 {
+				//changed or watch is broken, get leader again
+				return ctx.Err() == nil
+			}
+		}
+	}
+}
+
+// lost leadership without Resign
+func (e *Election) lost(h *holding, err error) {
+	e.mu.Lock()
+	if e.holding != h {
+		e.mu.Unlock()
+		return
+	}
+	e.election, e.holding = nil, nil
+	e.mu.Unlock()
+	h.end()
+	_ = h.session.Close()
+	if e.option.onLost != nil {
+		e.option.onLost(err)
+	}
+}
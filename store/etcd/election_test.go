@@ -0,0 +1,192 @@
+package etcd
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestElection(t *testing.T) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	var c1, c2 = newTestClient(t, "/election"), newTestClient(t, "/election")
+	var lost = make(chan error, 1)
+	var e1 = c1.NewElection("a", WithSessionTTL(5), WithOnLost(func(err error) {
+		lost <- err
+	}))
+	var lost2 = make(chan error, 1)
+	var e2 = c2.NewElection("a", WithSessionTTL(5), WithOnLost(func(err error) {
+		lost2 <- err
+	}))
+
+	var obCtx, obCancel = context.WithCancel(ctx)
+	defer obCancel()
+	var ob = e2.Observe(obCtx)
+	if l := <-ob; l.Key != "" {
+		t.Fatalf("there should be no leader, got %+v", l)
+	}
+
+	if err := e1.Campaign(ctx, "n1"); err != nil {
+		t.Fatal(err)
+	}
+	if !e1.IsLeader() || e2.IsLeader() {
+		t.Fatal("e1 should be leader")
+	}
+	if l := <-ob; string(l.Value) != "n1" {
+		t.Fatalf("unexpected leader %+v", l)
+	}
+	if err := e1.Proclaim(ctx, "n1x"); err != nil {
+		t.Fatal(err)
+	}
+	if l := <-ob; string(l.Value) != "n1x" {
+		t.Fatalf("unexpected leader %+v", l)
+	}
+	if err := e2.Proclaim(ctx, "n2"); ErrCode(err) != NotLeader {
+		t.Fatalf("expected not leader, got %v", err)
+	}
+
+	// e2 waits until e1 resigns
+	var elected = make(chan error, 1)
+	go func() {
+		elected <- e2.Campaign(ctx, "n2")
+	}()
+	select {
+	case <-elected:
+		t.Fatal("e2 should wait")
+	case <-time.After(200 * time.Millisecond):
+	}
+	var done = e1.Done()
+	if err := e1.Resign(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if err := <-elected; err != nil {
+		t.Fatal(err)
+	}
+	if l, err := e1.Leader(ctx); err != nil || string(l.Value) != "n2" {
+		t.Fatalf("unexpected leader %+v %v", l, err)
+	}
+	if l := <-ob; string(l.Value) != "n2" {
+		t.Fatalf("unexpected leader %+v", l)
+	}
+	if err := e1.Resign(ctx); ErrCode(err) != NotLeader {
+		t.Fatalf("expected not leader, got %v", err)
+	}
+	if len(lost) != 0 {
+		t.Fatal("resign should not call lost callback")
+	}
+
+	// leadership is lost when leader key is deleted
+	var h = e2.holding
+	if _, err := c1.eCli.Delete(ctx, e2.election.Key()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-lost2; ErrCode(err) != NotLeader {
+		t.Fatalf("expected not leader, got %v", err)
+	}
+	<-h.done
+	if e2.IsLeader() {
+		t.Fatal("e2 should not be leader")
+	}
+
+	// leadership is lost when lease is revoked
+	if err := e1.Campaign(ctx, "n1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c2.eCli.Revoke(ctx, e1.holding.session.Lease()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-lost; ErrCode(err) != NotLeader && ErrCode(err) != SessionExpired {
+		t.Fatalf("unexpected lost error %v", err)
+	}
+	if _, err := e1.Leader(ctx); !IsNotFoundErr(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestMutex(t *testing.T) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	var c = newTestClient(t, "/mutex")
+	var lost = make(chan error, 1)
+	var m1 = c.NewMutex("m", WithOnLost(func(err error) {
+		lost <- err
+	}))
+	var m2 = c.NewMutex("m")
+	if err := m1.Lock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m1.TryLock(ctx); ErrCode(err) != Locked {
+		t.Fatalf("expected locked, got %v", err)
+	}
+	if err := m2.TryLock(ctx); ErrCode(err) != Locked {
+		t.Fatalf("expected locked, got %v", err)
+	}
+	var lCtx, lCancel = context.WithTimeout(ctx, 100*time.Millisecond)
+	defer lCancel()
+	if err := m2.Lock(lCtx); ErrCode(err) != Timeout {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if err := m1.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m1.Unlock(ctx); !IsNotFoundErr(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if err := m2.Lock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m2.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// lock is lost when lease is revoked
+	if err := m1.Lock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var done = m1.Done()
+	if _, err := c.eCli.Revoke(ctx, m1.holding.session.Lease()); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if err := <-lost; ErrCode(err) != Locked && ErrCode(err) != SessionExpired {
+		t.Fatalf("unexpected lost error %v", err)
+	}
+	if m1.IsLocked() {
+		t.Fatal("m1 should not be locked")
+	}
+	if err := m2.TryLock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_ = m2.Unlock(ctx)
+}
+
+func TestMutex_ShortContext(t *testing.T) {
+	var c = newTestClient(t, "/mutex.ctx")
+	var m1, m2 = c.NewMutex("m", WithSessionTTL(2)), c.NewMutex("m")
+
+	var cCtx, cCancel = context.WithCancel(context.Background())
+	cCancel()
+	if err := m1.Lock(cCtx); err == nil {
+		t.Fatal("lock should fail with cancelled context")
+	}
+
+	// keepalive outlives the context used to lock
+	var lCtx, lCancel = context.WithTimeout(context.Background(), 5*time.Second)
+	if err := m1.Lock(lCtx); err != nil {
+		t.Fatal(err)
+	}
+	lCancel()
+	time.Sleep(3 * time.Second)
+	if !m1.IsLocked() {
+		t.Fatal("m1 should still be locked")
+	}
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m2.TryLock(ctx); ErrCode(err) != Locked {
+		t.Fatalf("expected locked, got %v", err)
+	}
+	if err := m1.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
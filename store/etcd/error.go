@@ -32,6 +32,12 @@ const (
 	WatchUnexpected
 	//WatchClosed watch closed
 	WatchClosed
+	//Locked lock is held by others
+	Locked
+	//NotLeader not leader of election
+	NotLeader
+	//SessionExpired session lease expired or revoked
+	SessionExpired
 	//Unknown error
 	Unknown
 )
@@ -60,6 +66,37 @@ func (e *Error) Code() Code {
 	return e.code
 }
 
+// GRPCStatus convert to grpc status, so errorx.Code/errorx.HTTPStatus can read it
+func (e *Error) GRPCStatus() *status.Status {
+	return status.New(e.code.GRPCCode(), e.Error())
+}
+
+// GRPCCode map etcd error code to grpc code
+func (c Code) GRPCCode() codes.Code {
+	switch c {
+	case OK:
+		return codes.OK
+	case Unavailable, WatchFail, WatchClosed, SessionExpired:
+		return codes.Unavailable
+	case Timeout:
+		return codes.DeadlineExceeded
+	case Canceled:
+		return codes.Canceled
+	case NodeExist:
+		return codes.AlreadyExists
+	case NodeNotFound:
+		return codes.NotFound
+	case BadVersion, Locked:
+		return codes.Aborted
+	case NotLeader:
+		return codes.FailedPrecondition
+	case BadRsp, WatchUnexpected:
+		return codes.Internal
+	default:
+		return codes.Unknown
+	}
+}
+
 // ErrCode get error code of etcd
 func ErrCode(err error) Code {
 	if err == nil {
@@ -155,6 +192,12 @@ func genErr(node string, code Code) error {
 		msg = fmt.Sprintf("%s: watch unexcepted", node)
 	case WatchClosed:
 		msg = fmt.Sprintf("%s: watch closed", node)
+	case Locked:
+		msg = fmt.Sprintf("%s: locked", node)
+	case NotLeader:
+		msg = fmt.Sprintf("%s: not leader", node)
+	case SessionExpired:
+		msg = fmt.Sprintf("%s: session expired", node)
 	default:
 		msg = fmt.Sprintf("%s: undefined error", node)
 	}
@@ -0,0 +1,86 @@
+package etcd
+
+import (
+	"strings"
+
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/resolver"
+)
+
+const (
+	//GRPCScheme grpc target scheme of etcd resolver, target is "etcd:///service"
+	GRPCScheme = "etcd"
+)
+
+// metadataKey attribute key of instance metadata
+type metadataKey string
+
+// AddrMetadata get instance metadata from grpc resolved address, it can be used in balancer
+func AddrMetadata(addr resolver.Address, key string) (string, bool) {
+	var v, ok = addr.BalancerAttributes.Value(metadataKey(key)).(string)
+	return v, ok
+}
+
+// grpcBuilder grpc resolver builder
+type grpcBuilder struct {
+	cli  *Client
+	opts []ConfigOption
+}
+
+// NewGRPCBuilder new grpc resolver builder, service is resolved under client root path.
+// Use it by grpc.WithResolvers(builder) and dial "etcd:///service".
+func NewGRPCBuilder(cli *Client, opts ...ConfigOption) resolver.Builder {
+	return &grpcBuilder{cli: cli, opts: opts}
+}
+
+// Build build resolver of target service
+func (b *grpcBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	var res, err = b.cli.NewResolver(strings.TrimPrefix(target.Endpoint(), "/"), b.opts...)
+	if err != nil {
+		return nil, err
+	}
+	var r = &grpcResolver{
+		resolver: res,
+		cc:       cc,
+	}
+	r.resolver.OnUpdate(r.update)
+	r.resolver.Start()
+	return r, nil
+}
+
+// Scheme scheme of builder
+func (b *grpcBuilder) Scheme() string {
+	return GRPCScheme
+}
+
+// grpcResolver grpc resolver
+type grpcResolver struct {
+	resolver *Resolver
+	cc       resolver.ClientConn
+}
+
+// ResolveNow endpoint list is pushed by watching, nothing to do
+func (r *grpcResolver) ResolveNow(resolver.ResolveNowOptions) {
+}
+
+// Close stop watching
+func (r *grpcResolver) Close() {
+	r.resolver.Stop()
+}
+
+// update push endpoint list to grpc, the first list is pushed even if it's empty,
+// then RPCs fail fast instead of waiting for the first resolver state.
+func (r *grpcResolver) update(instances []Instance) {
+	var addrs = make([]resolver.Address, 0, len(instances))
+	for _, ins := range instances {
+		var attrs *attributes.Attributes
+		for k, v := range ins.Metadata {
+			attrs = attrs.WithValue(metadataKey(k), v)
+		}
+		addrs = append(addrs, resolver.Address{
+			Addr:               ins.Addr,
+			BalancerAttributes: attrs,
+		})
+	}
+	_ = r.cc.UpdateState(resolver.State{Addresses: addrs})
+}
@@ -0,0 +1,78 @@
+package etcd
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+// testURL client url of embedded etcd
+var testURL string
+
+func TestMain(m *testing.M) {
+	var dir, err = os.MkdirTemp("", "neptune-etcd")
+	if err != nil {
+		panic(err)
+	}
+	var e *embed.Etcd
+	e, testURL, err = startEmbedEtcd(dir)
+	if err != nil {
+		panic(err)
+	}
+	var code = m.Run()
+	e.Close()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// startEmbedEtcd start an embedded etcd on random ports
+func startEmbedEtcd(dir string) (*embed.Etcd, string, error) {
+	var cfg = embed.NewConfig()
+	cfg.Dir = dir
+	cfg.LogLevel = "error"
+	var cu, pu = localURL(), localURL()
+	cfg.ListenClientUrls = []url.URL{cu}
+	cfg.AdvertiseClientUrls = []url.URL{cu}
+	cfg.ListenPeerUrls = []url.URL{pu}
+	cfg.AdvertisePeerUrls = []url.URL{pu}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	var e, err = embed.StartEtcd(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		e.Close()
+		return nil, "", fmt.Errorf("embedded etcd start timeout")
+	}
+	return e, cu.Host, nil
+}
+
+// localURL url of a free local port
+func localURL() url.URL {
+	var l, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	defer l.Close()
+	return url.URL{Scheme: "http", Host: l.Addr().String()}
+}
+
+// newTestClient new client on embedded etcd
+func newTestClient(t *testing.T, root string) *Client {
+	t.Helper()
+	var c, err = NewClient(testURL, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+	return c
+}
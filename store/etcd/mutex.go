@@ -0,0 +1,132 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sync"
+
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// Mutex distributed mutex under client root path
+// Lock creates a session(lease with keepalive), the lock is held until Unlock or the session is lost.
+// A Mutex can only be locked once at same time, use different Mutex instances in different go routines.
+type Mutex struct {
+	cli    *Client
+	prefix string
+	option *_SessionOption
+
+	//serialize locking
+	lockMu sync.Mutex
+	//guard mutex and holding
+	mu      sync.Mutex
+	mutex   *concurrency.Mutex
+	holding *holding
+}
+
+// NewMutex new mutex, name is the lock path under client root path
+func (c *Client) NewMutex(name string, opts ...SessionOption) *Mutex {
+	return &Mutex{
+		cli:    c,
+		prefix: path.Join(c.root, name),
+		option: rangeSessionOption(opts...),
+	}
+}
+
+// Lock block until locked or ctx is done
+func (m *Mutex) Lock(ctx context.Context) error {
+	return m.lock(ctx, true)
+}
+
+// TryLock lock without waiting, return Locked error if it's held by others
+func (m *Mutex) TryLock(ctx context.Context) error {
+	return m.lock(ctx, false)
+}
+
+// Unlock release the lock, the session is closed and the lease is revoked
+func (m *Mutex) Unlock(ctx context.Context) error {
+	m.mu.Lock()
+	var mu, h = m.mutex, m.holding
+	m.mutex, m.holding = nil, nil
+	m.mu.Unlock()
+	if h == nil {
+		return genErr(m.prefix, NodeNotFound)
+	}
+	var err = mu.Unlock(ctx)
+	var cErr = h.release()
+	if err == nil {
+		err = cErr
+	}
+	return convertErr(m.prefix, err)
+}
+
+// IsLocked return true if it's locked by this mutex now
+func (m *Mutex) IsLocked() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.holding != nil
+}
+
+// Done return a channel which is closed when the lock is released or lost.
+// It's closed already if it's not locked.
+func (m *Mutex) Done() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.holding == nil {
+		var ch = make(chan struct{})
+		close(ch)
+		return ch
+	}
+	return m.holding.done
+}
+
+// lock with session
+func (m *Mutex) lock(ctx context.Context, wait bool) error {
+	m.lockMu.Lock()
+	defer m.lockMu.Unlock()
+	if m.IsLocked() {
+		return genErr(m.prefix, Locked)
+	}
+	var s, err = m.cli.newSession(ctx, m.option)
+	if err != nil {
+		return convertErr(m.prefix, err)
+	}
+	var mu = concurrency.NewMutex(s, m.prefix)
+	if wait {
+		err = mu.Lock(ctx)
+	} else {
+		err = mu.TryLock(ctx)
+	}
+	if err != nil {
+		_ = s.Close()
+		switch {
+		case errors.Is(err, concurrency.ErrLocked):
+			return genErr(m.prefix, Locked)
+		case errors.Is(err, concurrency.ErrSessionExpired):
+			return genErr(m.prefix, SessionExpired)
+		}
+		return convertErr(m.prefix, err)
+	}
+	m.mu.Lock()
+	m.mutex = mu
+	m.holding = m.cli.newHolding(s, mu.Key(), mu.Header().Revision, Locked, m.lost)
+	m.mu.Unlock()
+	return nil
+}
+
+// lost lock without Unlock
+func (m *Mutex) lost(h *holding, err error) {
+	m.mu.Lock()
+	if m.holding != h {
+		m.mu.Unlock()
+		return
+	}
+	m.mutex, m.holding = nil, nil
+	m.mu.Unlock()
+	h.end()
+	_ = h.session.Close()
+	if m.option.onLost != nil {
+		m.option.onLost(err)
+	}
+}
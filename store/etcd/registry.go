@@ -0,0 +1,223 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+
+	"github.com/pinealctx/neptune/ulog"
+)
+
+const (
+	//DefaultRegisterTimeout default timeout of re-registering
+	DefaultRegisterTimeout = 3 * time.Second
+	//DefaultRegisterBackoff default backoff of re-registering if registration is lost
+	DefaultRegisterBackoff = time.Second
+)
+
+var (
+	//ErrInvalidInstance instance id or address is empty
+	ErrInvalidInstance = errors.New("etcd.invalid.instance")
+	//ErrInvalidService service name is empty or contains "/"
+	ErrInvalidService = errors.New("etcd.invalid.service")
+)
+
+// Instance service instance
+type Instance struct {
+	//ID unique id of instance in service, it must not contain "/"
+	ID string `json:"id"`
+	//Addr address of instance, such as "10.0.0.1:8080"
+	Addr string `json:"addr"`
+	//Metadata metadata of instance, such as version/zone/weight
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Registration registration of a service instance, the instance is registered under root/service/id.
+// The instance key is attached to a session lease, it's deleted if the lease expires.
+// If the registration is lost(lease expired or key deleted), it's re-registered with a new lease in background.
+type Registration struct {
+	cli    *Client
+	key    string
+	option *_SessionOption
+
+	//guard instance and holding
+	mu       sync.Mutex
+	instance Instance
+	holding  *holding
+
+	//lost notify
+	lostCh chan error
+	//stop keeping
+	stop     chan struct{}
+	stopOnce sync.Once
+	//closed when keeping is out
+	done chan struct{}
+}
+
+// Register register a service instance with lease and keepalive, it returns after first registration.
+// Call Deregister on shutdown.
+// opts -- WithSessionTTL set lease ttl, WithOnLost set callback when registration is lost before re-registering.
+func (c *Client) Register(ctx context.Context, service string, ins Instance, opts ...SessionOption) (*Registration, error) {
+	if !validService(service) {
+		return nil, ErrInvalidService
+	}
+	if ins.ID == "" || ins.Addr == "" || strings.Contains(ins.ID, "/") {
+		return nil, ErrInvalidInstance
+	}
+	var r = &Registration{
+		cli:      c,
+		key:      path.Join(c.root, service, ins.ID),
+		option:   rangeSessionOption(opts...),
+		instance: ins,
+		lostCh:   make(chan error, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	var err = r.register(ctx)
+	if err != nil {
+		return nil, err
+	}
+	go r.keep()
+	return r, nil
+}
+
+// Instance get registered instance
+func (r *Registration) Instance() Instance {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.instance
+}
+
+// Update update metadata of instance with the same lease
+func (r *Registration) Update(ctx context.Context, metadata map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ins = r.instance
+	ins.Metadata = metadata
+	if r.holding != nil {
+		var data, err = json.Marshal(ins)
+		if err != nil {
+			return err
+		}
+		_, err = r.cli.eCli.Put(ctx, r.key, string(data), clientv3.WithLease(r.holding.session.Lease()))
+		if err != nil {
+			return convertErr(r.key, err)
+		}
+	}
+	//if it's lost now, it's re-registered with new metadata later
+	r.instance = ins
+	return nil
+}
+
+// Deregister stop keeping and delete instance, the lease is revoked
+func (r *Registration) Deregister(ctx context.Context) error {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+	r.mu.Lock()
+	var h = r.holding
+	r.holding = nil
+	r.mu.Unlock()
+	if h == nil {
+		return nil
+	}
+	var _, err = r.cli.eCli.Delete(ctx, r.key)
+	var cErr = h.release()
+	if err == nil {
+		err = cErr
+	}
+	return convertErr(r.key, err)
+}
+
+// register put instance with a new session
+func (r *Registration) register(ctx context.Context) error {
+	var s, err = r.cli.newSession(ctx, r.option)
+	if err != nil {
+		return convertErr(r.key, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var data []byte
+	data, err = json.Marshal(r.instance)
+	if err != nil {
+		_ = s.Close()
+		return err
+	}
+	var rsp *clientv3.PutResponse
+	rsp, err = r.cli.eCli.Put(ctx, r.key, string(data), clientv3.WithLease(s.Lease()))
+	if err != nil {
+		_ = s.Close()
+		return convertErr(r.key, err)
+	}
+	r.holding = r.cli.newHolding(s, r.key, rsp.Header.Revision, NodeNotFound, r.lost)
+	return nil
+}
+
+// keep re-register if registration is lost until stop
+func (r *Registration) keep() {
+	defer close(r.done)
+	for {
+		select {
+		case <-r.stop:
+			return
+		case err := <-r.lostCh:
+			ulog.Error("etcd.registration.lost", zap.String("key", r.key), zap.Error(err))
+			if r.option.onLost != nil {
+				r.option.onLost(err)
+			}
+			if !r.reRegister() {
+				return
+			}
+		}
+	}
+}
+
+// reRegister register until ok, return false if stop
+func (r *Registration) reRegister() bool {
+	for {
+		var ctx, cancel = context.WithTimeout(context.Background(), DefaultRegisterTimeout)
+		var err = r.register(ctx)
+		cancel()
+		if err == nil {
+			ulog.Info("etcd.registration.recovered", zap.String("key", r.key))
+			return true
+		}
+		ulog.Error("etcd.registration.retry", zap.String("key", r.key), zap.Error(err))
+		select {
+		case <-r.stop:
+			return false
+		case <-time.After(DefaultRegisterBackoff):
+		}
+	}
+}
+
+// lost registration, notify keeping go routine
+func (r *Registration) lost(h *holding, err error) {
+	r.mu.Lock()
+	if r.holding != h {
+		r.mu.Unlock()
+		return
+	}
+	r.holding = nil
+	r.mu.Unlock()
+	h.end()
+	_ = h.session.Close()
+	select {
+	case r.lostCh <- err:
+	default:
+	}
+}
+
+// validService : service name must not be empty or contain "/",
+// otherwise instances of service "a/b" are watched as instances of service "a"
+func validService(service string) bool {
+	return service != "" && !strings.Contains(service, "/")
+}
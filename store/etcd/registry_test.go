@@ -0,0 +1,179 @@
+package etcd
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func nextInstances(t *testing.T, ch <-chan []Instance) []Instance {
+	t.Helper()
+	select {
+	case ins := <-ch:
+		return ins
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait instances timeout")
+	}
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	var c = newTestClient(t, "/registry")
+	c.DeleteDir(ctx, "svc", IgnoreRevision)
+
+	if _, err := c.Register(ctx, "svc", Instance{ID: "a"}); err != ErrInvalidInstance {
+		t.Fatalf("expected invalid instance, got %v", err)
+	}
+	if _, err := c.Register(ctx, "svc", Instance{ID: "a/b", Addr: "127.0.0.1:1"}); err != ErrInvalidInstance {
+		t.Fatalf("expected invalid instance, got %v", err)
+	}
+	//service "svc" must not watch instances of "svc/x"
+	if _, err := c.Register(ctx, "svc/x", Instance{ID: "a", Addr: "127.0.0.1:1"}); err != ErrInvalidService {
+		t.Fatalf("expected invalid service, got %v", err)
+	}
+	if _, err := c.NewResolver("svc/x"); err != ErrInvalidService {
+		t.Fatalf("expected invalid service, got %v", err)
+	}
+	var lost = make(chan error, 1)
+	var r1, err = c.Register(ctx, "svc", Instance{ID: "a", Addr: "127.0.0.1:1", Metadata: map[string]string{"v": "1"}},
+		WithSessionTTL(5), WithOnLost(func(err error) {
+			lost <- err
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res *Resolver
+	res, err = c.NewResolver("svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ch = make(chan []Instance, 16)
+	res.OnUpdate(func(instances []Instance) {
+		ch <- instances
+	})
+	res.Start()
+	defer res.Stop()
+	if ins := nextInstances(t, ch); len(ins) != 1 || ins[0].Addr != "127.0.0.1:1" || ins[0].Metadata["v"] != "1" {
+		t.Fatalf("unexpected instances %+v", ins)
+	}
+
+	var r2 *Registration
+	r2, err = c.Register(ctx, "svc", Instance{ID: "b", Addr: "127.0.0.1:2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ins := nextInstances(t, ch); len(ins) != 2 || ins[0].ID != "a" || ins[1].ID != "b" {
+		t.Fatalf("unexpected instances %+v", ins)
+	}
+	if err = r1.Update(ctx, map[string]string{"v": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if ins := nextInstances(t, ch); len(ins) != 2 || ins[0].Metadata["v"] != "2" {
+		t.Fatalf("unexpected instances %+v", ins)
+	}
+
+	//lease is revoked, it's re-registered
+	r1.mu.Lock()
+	var lease = r1.holding.session.Lease()
+	r1.mu.Unlock()
+	if _, err = c.eCli.Revoke(ctx, lease); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-lost; ErrCode(err) != NodeNotFound && ErrCode(err) != SessionExpired {
+		t.Fatalf("unexpected lost error %v", err)
+	}
+	//instance a may be deleted and re-registered in one update
+	var ins = nextInstances(t, ch)
+	if len(ins) == 1 && ins[0].ID == "b" {
+		ins = nextInstances(t, ch)
+	}
+	if len(ins) != 2 || ins[0].ID != "a" || ins[0].Metadata["v"] != "2" {
+		t.Fatalf("unexpected instances %+v", ins)
+	}
+
+	if err = r2.Deregister(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ins := nextInstances(t, ch); len(ins) != 1 || ins[0].ID != "a" {
+		t.Fatalf("unexpected instances %+v", ins)
+	}
+	if err = r1.Deregister(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ins := nextInstances(t, ch); len(ins) != 0 {
+		t.Fatalf("unexpected instances %+v", ins)
+	}
+	if len(res.Instances()) != 0 {
+		t.Fatal("there should be no instance")
+	}
+}
+
+func TestGRPCResolver(t *testing.T) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	var c = newTestClient(t, "/registry")
+	c.DeleteDir(ctx, "grpc", IgnoreRevision)
+
+	var l, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var srv = grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	defer srv.Stop()
+
+	var r *Registration
+	r, err = c.Register(ctx, "grpc", Instance{ID: "s1", Addr: l.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = r.Deregister(context.Background())
+	}()
+
+	var conn *grpc.ClientConn
+	conn, err = grpc.NewClient(GRPCScheme+":///grpc",
+		grpc.WithResolvers(NewGRPCBuilder(c)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var rsp *healthpb.HealthCheckResponse
+	rsp, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("unexpected status %v", rsp.Status)
+	}
+
+	//no instance, empty state is pushed and RPC fails fast instead of waiting until deadline
+	c.DeleteDir(ctx, "none", IgnoreRevision)
+	var empty *grpc.ClientConn
+	empty, err = grpc.NewClient(GRPCScheme+":///none",
+		grpc.WithResolvers(NewGRPCBuilder(c)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer empty.Close()
+	_, err = healthpb.NewHealthClient(empty).Check(ctx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected unavailable, got %v", err)
+	}
+}
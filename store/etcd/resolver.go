@@ -0,0 +1,102 @@
+package etcd
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Resolver service resolver, it watches instances under root/service and keeps a live endpoint list
+type Resolver struct {
+	service string
+	watcher *ConfigWatcher[Instance]
+
+	//guard callbacks
+	mu       sync.Mutex
+	onUpdate []func(instances []Instance)
+	//last notified snapshot, only accessed in watching go routine
+	last *ConfigSnapshot[Instance]
+}
+
+// NewResolver new service resolver, call Start to watch.
+// It returns ErrInvalidService if service is empty or contains "/".
+func (c *Client) NewResolver(service string, opts ...ConfigOption) (*Resolver, error) {
+	if !validService(service) {
+		return nil, ErrInvalidService
+	}
+	var r = &Resolver{
+		service: service,
+		watcher: NewConfigWatcher(c, service, JSONDecoder[Instance](), opts...),
+	}
+	r.watcher.onReady = r.ready
+	r.watcher.OnChange(r.change)
+	return r, nil
+}
+
+// OnUpdate add callback of endpoint list update, it's called with full list(sorted by id) once for each change batch.
+// Update of first load is notified too if callback is added before Start, even if there is no instance.
+func (r *Resolver) OnUpdate(fn func(instances []Instance)) {
+	r.mu.Lock()
+	r.onUpdate = append(r.onUpdate, fn)
+	r.mu.Unlock()
+}
+
+// Start start watching, even if there is no instance
+func (r *Resolver) Start() {
+	r.watcher.Start()
+}
+
+// Stop stop watching
+func (r *Resolver) Stop() {
+	r.watcher.Stop()
+}
+
+// Wait wait until first endpoint list is loaded or ctx is done
+func (r *Resolver) Wait(ctx context.Context) error {
+	return r.watcher.Wait(ctx)
+}
+
+// Instances get current instances sorted by id, metadata of instances must not be modified
+func (r *Resolver) Instances() []Instance {
+	return snapshotInstances(r.watcher.Snapshot())
+}
+
+// ready : notify first snapshot even if it has no change(no instance), grpc waits for the first state
+func (r *Resolver) ready() {
+	r.notify(r.watcher.Snapshot())
+}
+
+// change : changes of a snapshot are notified one by one after the snapshot is swapped,
+// so notify the full list at the first change of the snapshot only.
+func (r *Resolver) change(_ string, _, _ *Instance) {
+	r.notify(r.watcher.Snapshot())
+}
+
+// notify : notify full list of snapshot once
+func (r *Resolver) notify(s *ConfigSnapshot[Instance]) {
+	if s == r.last {
+		return
+	}
+	r.last = s
+
+	r.mu.Lock()
+	var callbacks = slices.Clone(r.onUpdate)
+	r.mu.Unlock()
+	var instances = snapshotInstances(s)
+	for _, fn := range callbacks {
+		fn(instances)
+	}
+}
+
+// snapshotInstances : instances of snapshot sorted by id
+func snapshotInstances(s *ConfigSnapshot[Instance]) []Instance {
+	var instances = make([]Instance, 0, len(s.Values))
+	for _, v := range s.Values {
+		instances = append(instances, *v)
+	}
+	slices.SortFunc(instances, func(a, b Instance) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return instances
+}
@@ -0,0 +1,158 @@
+package etcd
+
+import (
+	"context"
+	"sync"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+const (
+	//DefaultSessionTTL default session lease ttl in seconds
+	DefaultSessionTTL = 10
+)
+
+// session option
+type _SessionOption struct {
+	//lease ttl in seconds
+	ttl int
+	//lost callback
+	onLost func(err error)
+}
+
+// SessionOption setup session option of election and mutex
+type SessionOption func(*_SessionOption)
+
+// WithSessionTTL set session lease ttl in seconds.
+// The lease is kept alive in background, leadership or lock is lost if keepalive fails longer than ttl.
+func WithSessionTTL(ttl int) SessionOption {
+	return func(o *_SessionOption) {
+		o.ttl = ttl
+	}
+}
+
+// WithOnLost set callback when leadership or lock is lost without Resign/Unlock.
+// err -- SessionExpired if lease is expired or revoked, NotLeader/Locked if key is deleted by others.
+func WithOnLost(fn func(err error)) SessionOption {
+	return func(o *_SessionOption) {
+		o.onLost = fn
+	}
+}
+
+// range session option
+func rangeSessionOption(opts ...SessionOption) *_SessionOption {
+	var o = &_SessionOption{ttl: DefaultSessionTTL}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// newSession new session with lease keepalive.
+// The lease is granted with ctx, so it returns when ctx is done even if etcd has no quorum,
+// but keepalive is not bound to ctx, it lasts until the session is closed.
+func (c *Client) newSession(ctx context.Context, o *_SessionOption) (*concurrency.Session, error) {
+	var rsp, err = c.eCli.Grant(ctx, int64(o.ttl))
+	if err != nil {
+		return nil, err
+	}
+	var s *concurrency.Session
+	s, err = concurrency.NewSession(c.eCli, concurrency.WithTTL(o.ttl), concurrency.WithLease(rsp.ID))
+	if err != nil {
+		_, _ = c.eCli.Revoke(ctx, rsp.ID)
+		return nil, err
+	}
+	return s, nil
+}
+
+// holding : a holding of leadership or lock, it's guarded by a session
+type holding struct {
+	session *concurrency.Session
+	//stop guarding, closed by Resign/Unlock
+	stop chan struct{}
+	//closed when holding ends, lost or released
+	done chan struct{}
+	//close done once
+	once sync.Once
+}
+
+// newHolding new holding and guard it in background.
+// key/rev -- own key and its revision, holding is lost if key is deleted.
+// lostCode -- error code if key is deleted.
+// lost -- called once if holding is lost, not called if stop is closed.
+func (c *Client) newHolding(s *concurrency.Session, key string, rev int64, lostCode Code,
+	lost func(h *holding, err error)) *holding {
+	var h = &holding{
+		session: s,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go h.guard(c.eCli, key, rev, lostCode, lost)
+	return h
+}
+
+// release : stop guarding and close session, the lease is revoked
+func (h *holding) release() error {
+	close(h.stop)
+	h.end()
+	return h.session.Close()
+}
+
+// end : close done channel once
+func (h *holding) end() {
+	h.once.Do(func() {
+		close(h.done)
+	})
+}
+
+// guard : wait until session is done, key is deleted or stop
+func (h *holding) guard(cli *clientv3.Client, key string, rev int64, lostCode Code, lost func(h *holding, err error)) {
+	var ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	var wch = cli.Watch(ctx, key, clientv3.WithRev(rev+1))
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-h.session.Done():
+			lost(h, genErr(key, SessionExpired))
+			return
+		case rsp, ok := <-wch:
+			if !ok {
+				//client is closed, session will be done
+				wch = nil
+				continue
+			}
+			if rsp.CompactRevision > 0 {
+				//revision compacted, check key then re-watch from current revision
+				var exist bool
+				wch, exist = rewatch(ctx, cli, key)
+				if !exist {
+					lost(h, genErr(key, lostCode))
+					return
+				}
+				continue
+			}
+			for _, ev := range rsp.Events {
+				if ev.Type == mvccpb.DELETE {
+					lost(h, genErr(key, lostCode))
+					return
+				}
+			}
+		}
+	}
+}
+
+// rewatch : get key and watch it from current revision, exist is true if it's failed to get
+func rewatch(ctx context.Context, cli *clientv3.Client, key string) (wch clientv3.WatchChan, exist bool) {
+	var rsp, err = cli.Get(ctx, key)
+	if err != nil {
+		return nil, true
+	}
+	if rsp.Count == 0 {
+		return nil, false
+	}
+	return cli.Watch(ctx, key, clientv3.WithRev(rsp.Header.Revision+1)), true
+}
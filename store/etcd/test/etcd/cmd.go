@@ -104,6 +104,93 @@ func watchDir(c *cli.Context) error {
 	return nil
 }
 
+func watchConfig(c *cli.Context) error {
+	var watcher = etcd.NewConfigWatcher(eCli, c.String("key"), etcd.JSONDecoder[map[string]any]())
+	watcher.OnChange(func(name string, prev, cur *map[string]any) {
+		log.Println("config changed:", name, " previous:", prev, " current:", cur)
+	})
+	watcher.Start()
+	<-watcher.Ready()
+	log.Println("config loaded, revision:", watcher.Snapshot().Revision)
+	select {}
+}
+
+func register(c *cli.Context) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	var instance = etcd.Instance{ID: c.String("content"), Addr: c.String("addr")}
+	var registration, err = eCli.Register(ctx, c.String("key"), instance, etcd.WithOnLost(func(err error) {
+		log.Println("registration lost:", err)
+	}))
+	if err != nil {
+		return err
+	}
+	log.Println("registered:", c.String("key"), " instance:", instance.ID, " addr:", instance.Addr)
+	time.Sleep(time.Second * 30)
+
+	var dCtx, dCancel = context.WithTimeout(context.Background(), time.Second*3)
+	defer dCancel()
+	return registration.Deregister(dCtx)
+}
+
+func resolve(c *cli.Context) error {
+	var resolver, err = eCli.NewResolver(c.String("key"))
+	if err != nil {
+		return err
+	}
+	resolver.OnUpdate(func(instances []etcd.Instance) {
+		log.Println("instances of", c.String("key"), ":")
+		for _, ins := range instances {
+			log.Println("id:", ins.ID, " addr:", ins.Addr, " metadata:", ins.Metadata)
+		}
+	})
+	resolver.Start()
+	select {}
+}
+
+func campaign(c *cli.Context) error {
+	var election = eCli.NewElection(c.String("key"), etcd.WithOnLost(func(err error) {
+		log.Println("leadership lost:", err)
+	}))
+	go func() {
+		for leader := range election.Observe(context.Background()) {
+			log.Println("leader:", leader.Key, " value:", string(leader.Value), " revision:", leader.Revision)
+		}
+	}()
+	var err = election.Campaign(context.Background(), c.String("content"))
+	if err != nil {
+		return err
+	}
+	log.Println("elected as leader:", c.String("content"))
+	<-election.Done()
+	return nil
+}
+
+func lock(c *cli.Context) error {
+	var mutex = eCli.NewMutex(c.String("key"), etcd.WithOnLost(func(err error) {
+		log.Println("lock lost:", err)
+	}))
+	var err = mutex.Lock(context.Background())
+	if err != nil {
+		return err
+	}
+	log.Println("locked:", c.String("key"))
+	select {
+	case <-mutex.Done():
+		return nil
+	case <-time.After(time.Second * 10):
+	}
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	err = mutex.Unlock(ctx)
+	if err != nil {
+		return err
+	}
+	log.Println("unlocked:", c.String("key"))
+	return nil
+}
+
 func printItem(i *mvccpb.KeyValue) {
 	log.Println("key:", string(i.Key), " version:", i.Version, "mod version", i.ModRevision, " value:", string(i.Value))
 }
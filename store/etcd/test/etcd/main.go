@@ -28,7 +28,7 @@ func main() {
 			},
 			&cli.StringFlag{
 				Name:  "mode",
-				Usage: "create/delete/deleteDir/update/get/getDir/watchDir",
+				Usage: "create/delete/deleteDir/update/get/getDir/watchDir/watchConfig/campaign/lock/register/resolve",
 			},
 			&cli.StringFlag{
 				Name:  "key",
@@ -42,6 +42,10 @@ func main() {
 				Name:  "file",
 				Usage: "read file path",
 			},
+			&cli.StringFlag{
+				Name:  "addr",
+				Usage: "instance address",
+			},
 			&cli.IntFlag{
 				Name:  "dev",
 				Usage: "data version",
@@ -83,6 +87,16 @@ func runCmd(c *cli.Context) error {
 		return getDir(c)
 	case "watchDir":
 		return watchDir(c)
+	case "watchConfig":
+		return watchConfig(c)
+	case "campaign":
+		return campaign(c)
+	case "lock":
+		return lock(c)
+	case "register":
+		return register(c)
+	case "resolve":
+		return resolve(c)
 	}
 	return errors.New("unsupported.mode")
 }
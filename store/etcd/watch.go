@@ -16,6 +16,10 @@ type DirEvent struct {
 	Events   []*clientv3.Event
 	Err      error
 	Revision int64
+	//Snapshot events are full content of dir(all PUT), it's sent when watch is setup or re-setup.
+	//Keys not in snapshot are deleted during re-setup.
+	//Watcher skips empty dir by default, only ConfigWatcher gets an empty snapshot with the not found error.
+	Snapshot bool
 }
 
 // AddEvent add event
@@ -65,8 +69,9 @@ func (c *Client) WatchDir(ctx context.Context,
 
 	var watchPath = path.Join(c.root, nodePath) + "/"
 	var outCtx, outCancel = context.WithCancel(ctx)
+	//watch from next revision of get, no change is missed between get and watch
 	var watcher = c.eCli.Watch(clientv3.WithRequireLeader(outCtx),
-		watchPath, clientv3.WithPrefix())
+		watchPath, clientv3.WithPrefix(), clientv3.WithRev(dRet.Revision+1))
 	if watcher == nil {
 		dRet.Err = genErr(watchPath, WatchFail)
 		return dRet, nil, outCancel
@@ -81,6 +86,52 @@ func (c *Client) WatchDir(ctx context.Context,
 	return dRet, nChan, outCancel
 }
 
+// WatchKey watch a key, it's same as WatchDir but watch a single key.
+// ctx -- cancel controller, it's a parent context for watch
+// nodePath -- path
+// timeout -- get key timeout
+// ignoreEmpty -- if ignored, even key does not exist, watch still continue to work
+// return first get key content(as a dir with one kv)/key watch event/current watch session cancel
+func (c *Client) WatchKey(ctx context.Context,
+	nodePath string, timeout time.Duration, ignoreEmpty bool) (DirRet, <-chan DirEvent, context.CancelFunc) {
+
+	var keyPath = path.Join(c.root, nodePath)
+
+	//get key
+	var gCtx, cancel = context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var dRet DirRet
+	var rsp, err = c.eCli.Get(gCtx, keyPath)
+	if err != nil {
+		dRet.Err = convertErr(keyPath, err)
+		return dRet, nil, nil
+	}
+	dRet.Revision = rsp.Header.Revision
+	if len(rsp.Kvs) == 0 {
+		dRet.Err = genErr(keyPath, NodeNotFound)
+		if !ignoreEmpty {
+			return dRet, nil, nil
+		}
+	} else {
+		dRet.KVS = rsp.Kvs
+	}
+
+	var outCtx, outCancel = context.WithCancel(ctx)
+	var watcher = c.eCli.Watch(clientv3.WithRequireLeader(outCtx),
+		keyPath, clientv3.WithRev(dRet.Revision+1))
+	if watcher == nil {
+		dRet.Err = genErr(keyPath, WatchFail)
+		return dRet, nil, outCancel
+	}
+
+	var nChan = make(chan DirEvent, 1)
+	go func() {
+		// loop receive notify
+		dirLoop(outCtx, nChan, watcher, nodePath)
+	}()
+	return dRet, nChan, outCancel
+}
+
 // dir watch loop
 func dirLoop(ctx context.Context,
 	notifyChan chan<- DirEvent, watcher clientv3.WatchChan, nodePath string) {
@@ -137,12 +188,12 @@ func procDirEvents(wRsp clientv3.WatchResponse, nodePath string, notifyChan chan
 		switch ev.Type {
 		case mvccpb.PUT, mvccpb.DELETE:
 			watchDir.AddEvent(ev)
-			notifyChan <- watchDir
 		default:
 			watchDir.Err = genErr(nodePath, WatchUnexpected)
 			notifyChan <- watchDir
 			return true
 		}
 	}
+	notifyChan <- watchDir
 	return false
 }
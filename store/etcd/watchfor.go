@@ -38,6 +38,13 @@ type Watcher struct {
 	//time config
 	timeout time.Duration
 	backoff time.Duration
+
+	//watch setup, Client.WatchDir or Client.WatchKey
+	setup func(ctx context.Context,
+		nodePath string, timeout time.Duration, ignoreEmpty bool) (DirRet, <-chan DirEvent, context.CancelFunc)
+	//put an empty snapshot with the not found error if dir is empty when watch is setup,
+	//it's only for consumers keeping full content such as ConfigWatcher
+	emptySnapshot bool
 }
 
 // NewWatcher new watcher
@@ -53,9 +60,18 @@ func NewWatcher(cli *Client, path string, timeout, backoff time.Duration) *Watch
 		path:    path,
 		timeout: timeout,
 		backoff: backoff,
+		setup:   cli.WatchDir,
 	}
 }
 
+// NewKeyWatcher new watcher of a single key, start it by StartWatchDir/StartWatchDirWhenExist,
+// events are sent to DirChan as well
+func NewKeyWatcher(cli *Client, path string, timeout, backoff time.Duration) *Watcher {
+	var w = NewWatcher(cli, path, timeout, backoff)
+	w.setup = cli.WatchKey
+	return w
+}
+
 // DirChan dir chan
 func (w *Watcher) DirChan() <-chan DirEvent {
 	return w.dirChan
@@ -124,7 +140,7 @@ func (w *Watcher) startWatchDir(ignoreEmpty bool) {
 		}
 
 		//setup watch
-		dRet, iChan, cancel = w.cli.WatchDir(w.ctx, w.path, w.timeout, ignoreEmpty)
+		dRet, iChan, cancel = w.setup(w.ctx, w.path, w.timeout, ignoreEmpty)
 
 		if !w.watchDirMoveOn(dRet, ignoreEmpty) {
 			ulog.Error("cli.watch.dir.setup.error", zap.String("path", w.path), zap.Error(dRet.Err))
@@ -182,7 +198,8 @@ func (w *Watcher) procSession(iChan <-chan DirEvent) bool {
 }
 
 // proc first dir result
-// if dRet.Err is not nil, means get dir is empty, just return to next to watch.
+// if dRet.Err is not nil, means get dir is empty, just return to next to watch,
+// or put an empty snapshot with the error to channel if emptySnapshot is set.
 // if dRet.Err is nil, put the result to channel
 // if ctx.Done, return true, means watch is done.
 // if return false, just quit current session, continue to next session
@@ -192,10 +209,19 @@ func (w *Watcher) procFirstRet(dRet DirRet) bool {
 			ulog.Error("cli.watch.dir.setup.crazy.error", zap.String("path", w.path), zap.Error(dRet.Err))
 		}
 		ulog.Info("cli.watch.dir.setup.error.no.children", zap.String("path", w.path), zap.Error(dRet.Err))
+		if !w.emptySnapshot {
+			select {
+			case <-w.ctx.Done():
+				return true
+			default:
+			}
+			return false
+		}
 		select {
+		//put empty snapshot, keys may be deleted during re-setup
+		case w.dirChan <- DirEvent{Err: dRet.Err, Revision: dRet.Revision, Snapshot: true}:
 		case <-w.ctx.Done():
 			return true
-		default:
 		}
 		return false
 	}
@@ -227,6 +253,7 @@ func first2Event(dirRet DirRet) DirEvent {
 	var event = DirEvent{
 		Err:      dirRet.Err,
 		Revision: dirRet.Revision,
+		Snapshot: true,
 	}
 	var size = len(dirRet.KVS)
 	if size == 0 {
@@ -237,8 +264,11 @@ func first2Event(dirRet DirRet) DirEvent {
 		event.Events[i] = &clientv3.Event{
 			Type: mvccpb.PUT,
 			Kv: &mvccpb.KeyValue{
-				Key:   dirRet.KVS[i].Key,
-				Value: dirRet.KVS[i].Value,
+				Key:            dirRet.KVS[i].Key,
+				Value:          dirRet.KVS[i].Value,
+				CreateRevision: dirRet.KVS[i].CreateRevision,
+				ModRevision:    dirRet.KVS[i].ModRevision,
+				Version:        dirRet.KVS[i].Version,
 			},
 		}
 	}
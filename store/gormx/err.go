@@ -6,8 +6,16 @@ import (
 
 	"github.com/go-sql-driver/mysql"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 	"gorm.io/gorm"
+
+	"github.com/pinealctx/neptune/errorx"
+)
+
+var (
+	// ErrItemNotExist db item not found
+	ErrItemNotExist = errorx.NewCode(codes.NotFound, "DB_ITEM_NOT_EXIST", "db.item.not.exist")
+	// ErrItemAlreadyExist db item duplicated
+	ErrItemAlreadyExist = errorx.NewCode(codes.AlreadyExists, "DB_ITEM_ALREADY_EXIST", "db.item.already.exist")
 )
 
 // IsDupError is duplicated key error
@@ -38,7 +46,7 @@ func ToGRPCNotFoundErr(err error) error {
 		return nil
 	}
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		return status.Error(codes.NotFound, "db.item.not.exist")
+		return ErrItemNotExist
 	}
 	return err
 }
@@ -49,7 +57,7 @@ func ToGRPCDupErr(err error) error {
 		return nil
 	}
 	if IsDupError(err) {
-		return status.Error(codes.AlreadyExists, "db.item.already.exist")
+		return ErrItemAlreadyExist
 	}
 	return err
 }
@@ -0,0 +1,564 @@
+package keylock
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pinealctx/neptune/ulog"
+)
+
+const (
+	//DefaultHoldThreshold default threshold of holding a lock in debug mode
+	DefaultHoldThreshold = 10 * time.Second
+	//DefaultMaxOrderEdges default max lock order edges recorded in debug mode
+	DefaultMaxOrderEdges = 8192
+)
+
+// ReportKind : kind of debug report
+type ReportKind int
+
+const (
+	//ReportHoldTooLong a lock is held longer than threshold
+	ReportHoldTooLong ReportKind = iota + 1
+	//ReportOrderCycle keys are locked in different orders, it may cause deadlock
+	ReportOrderCycle
+)
+
+// String : implement fmt.Stringer
+func (k ReportKind) String() string {
+	switch k {
+	case ReportHoldTooLong:
+		return "hold.too.long"
+	case ReportOrderCycle:
+		return "order.cycle"
+	default:
+		return "unknown"
+	}
+}
+
+// Holder : a go routine holding or waiting a key
+type Holder struct {
+	//Goid : go routine id
+	Goid uint64
+	//Write : write lock or read lock
+	Write bool
+	//Since : acquire time for holder, or start waiting time for waiter
+	Since time.Time
+	//Stack : stack of the go routine when acquiring, empty if stack is disabled
+	Stack string
+}
+
+// KeyState : holders and waiters of a key
+type KeyState[T comparable] struct {
+	Key     T
+	Holders []Holder
+	Waiters []Holder
+}
+
+// DebugReport : report of debug locker
+type DebugReport[T comparable] struct {
+	Kind ReportKind
+	//Key : the key held too long, or the key being locked which closes the cycle
+	Key T
+	//Holder : the holder held too long, or the go routine locking Key
+	Holder Holder
+	//Cycle : lock order cycle, each key is locked before the next one and Cycle[0] == Cycle[len(Cycle)-1].
+	//Cycle[0] is held by Holder when locking Key(Cycle[1]), only for ReportOrderCycle
+	Cycle []T
+}
+
+// debug option
+type _DebugOption struct {
+	//hold threshold, 0 means disabled
+	holdThreshold time.Duration
+	//record stack
+	stack bool
+	//max lock order edges, 0 means disabled
+	maxEdges int
+	//reporter
+	reporter any
+}
+
+// DebugOption : debug locker option function
+type DebugOption func(o *_DebugOption)
+
+// WithHoldThreshold : report when a lock is held longer than threshold, 0 to disable it.
+// Default is DefaultHoldThreshold.
+func WithHoldThreshold(threshold time.Duration) DebugOption {
+	return func(o *_DebugOption) {
+		o.holdThreshold = threshold
+	}
+}
+
+// WithStack : record stack of holders and waiters, default is true.
+// Capturing stack is expensive, disable it if the locker is very hot.
+func WithStack(stack bool) DebugOption {
+	return func(o *_DebugOption) {
+		o.stack = stack
+	}
+}
+
+// WithMaxOrderEdges : max lock order edges recorded for cycle detection, 0 to disable detection.
+// Edges are never removed, new edges are ignored after reached. Default is DefaultMaxOrderEdges.
+func WithMaxOrderEdges(maxEdges int) DebugOption {
+	return func(o *_DebugOption) {
+		o.maxEdges = maxEdges
+	}
+}
+
+// WithReporter : setup reporter, default reporter logs warning by ulog.
+// The reporter must be func(DebugReport[T]) which T is the key type of debug locker, otherwise it's ignored.
+// It's called without any lock held.
+func WithReporter[T comparable](fn func(r DebugReport[T])) DebugOption {
+	return func(o *_DebugOption) {
+		o.reporter = fn
+	}
+}
+
+// debugKey : holders and waiters of a key
+type debugKey struct {
+	holders []*debugHolder
+	waiters []*debugHolder
+}
+
+// debugHolder : holder or waiter record
+type debugHolder struct {
+	Holder
+	timer *time.Timer
+}
+
+// DebugLocker : locker with diagnostics, it wraps an ExtLocker and records holders and waiters of keys.
+// It reports locks held longer than threshold and keys locked in different orders(like lockdep).
+// It's slow, use it only for debugging.
+// All lockers in this package implement ExtLocker, DebugLocker[any] implements Locker too.
+type DebugLocker[T comparable] struct {
+	l ExtLocker[T]
+
+	holdThreshold time.Duration
+	stack         bool
+	maxEdges      int
+	reporter      func(r DebugReport[T])
+
+	mu   sync.Mutex
+	keys map[T]*debugKey
+	//keys held by go routine in acquiring order
+	held map[uint64][]T
+	//lock order edges, key -> keys locked after it
+	edges  map[T]map[T]struct{}
+	nEdges int
+}
+
+// NewDebugLocker : wrap a locker in debug mode
+func NewDebugLocker[T comparable](l ExtLocker[T], opts ...DebugOption) *DebugLocker[T] {
+	var o = &_DebugOption{
+		holdThreshold: DefaultHoldThreshold,
+		stack:         true,
+		maxEdges:      DefaultMaxOrderEdges,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	var d = &DebugLocker[T]{
+		l:             l,
+		holdThreshold: o.holdThreshold,
+		stack:         o.stack,
+		maxEdges:      o.maxEdges,
+		keys:          make(map[T]*debugKey),
+		held:          make(map[uint64][]T),
+		edges:         make(map[T]map[T]struct{}),
+	}
+	var fn, ok = o.reporter.(func(r DebugReport[T]))
+	if ok && fn != nil {
+		d.reporter = fn
+	} else {
+		d.reporter = logReport[T]
+	}
+	return d
+}
+
+// Lock write lock
+func (d *DebugLocker[T]) Lock(key T) {
+	_ = d.lock(context.Background(), key, true)
+}
+
+// Unlock write unlock
+func (d *DebugLocker[T]) Unlock(key T) {
+	d.release(key, true)
+	d.l.Unlock(key)
+}
+
+// RLock read lock
+func (d *DebugLocker[T]) RLock(key T) {
+	_ = d.lock(context.Background(), key, false)
+}
+
+// RUnlock read unlock
+func (d *DebugLocker[T]) RUnlock(key T) {
+	d.release(key, false)
+	d.l.RUnlock(key)
+}
+
+// TryLock try write lock without waiting, return true if locked
+func (d *DebugLocker[T]) TryLock(key T) bool {
+	return d.tryLock(key, true)
+}
+
+// TryRLock try read lock without waiting, return true if locked
+func (d *DebugLocker[T]) TryRLock(key T) bool {
+	return d.tryLock(key, false)
+}
+
+// LockCtx write lock, return ctx error if ctx is done before locked
+func (d *DebugLocker[T]) LockCtx(ctx context.Context, key T) error {
+	return d.lock(ctx, key, true)
+}
+
+// RLockCtx read lock, return ctx error if ctx is done before locked
+func (d *DebugLocker[T]) RLockCtx(ctx context.Context, key T) error {
+	return d.lock(ctx, key, false)
+}
+
+// LockTimeout write lock, return context.DeadlineExceeded if not locked in timeout
+func (d *DebugLocker[T]) LockTimeout(key T, timeout time.Duration) error {
+	return lockTimeout(timeout, func(ctx context.Context) error {
+		return d.LockCtx(ctx, key)
+	})
+}
+
+// RLockTimeout read lock, return context.DeadlineExceeded if not locked in timeout
+func (d *DebugLocker[T]) RLockTimeout(key T, timeout time.Duration) error {
+	return lockTimeout(timeout, func(ctx context.Context) error {
+		return d.RLockCtx(ctx, key)
+	})
+}
+
+// Locks write lock multi keys, keys are deduplicated and locked in HashOrder to avoid deadlock
+func (d *DebugLocker[T]) Locks(keys []T) {
+	_ = d.LocksCtx(context.Background(), keys)
+}
+
+// Unlocks write unlock multi keys
+func (d *DebugLocker[T]) Unlocks(keys []T) {
+	unlockAll(sortKeys(keys, HashOrder[T]), d.Unlock)
+}
+
+// RLocks read lock multi keys, keys are deduplicated and locked in HashOrder to avoid deadlock
+func (d *DebugLocker[T]) RLocks(keys []T) {
+	_ = d.RLocksCtx(context.Background(), keys)
+}
+
+// RUnlocks read unlock multi keys
+func (d *DebugLocker[T]) RUnlocks(keys []T) {
+	unlockAll(sortKeys(keys, HashOrder[T]), d.RUnlock)
+}
+
+// LocksCtx write lock multi keys like Locks, all or nothing, return ctx error if ctx is done before all locked
+func (d *DebugLocker[T]) LocksCtx(ctx context.Context, keys []T) error {
+	return lockAll(ctx, sortKeys(keys, HashOrder[T]), d.LockCtx, d.Unlock)
+}
+
+// RLocksCtx read lock multi keys like RLocks, all or nothing, return ctx error if ctx is done before all locked
+func (d *DebugLocker[T]) RLocksCtx(ctx context.Context, keys []T) error {
+	return lockAll(ctx, sortKeys(keys, HashOrder[T]), d.RLockCtx, d.RUnlock)
+}
+
+// Snapshot : holders and waiters of all held or waited keys, sorted by HashOrder of keys
+func (d *DebugLocker[T]) Snapshot() []KeyState[T] {
+	d.mu.Lock()
+	var states = make([]KeyState[T], 0, len(d.keys))
+	for key, dk := range d.keys {
+		states = append(states, KeyState[T]{
+			Key:     key,
+			Holders: copyHolders(dk.holders),
+			Waiters: copyHolders(dk.waiters),
+		})
+	}
+	d.mu.Unlock()
+	slices.SortFunc(states, func(a, b KeyState[T]) int {
+		return HashOrder(a.Key, b.Key)
+	})
+	return states
+}
+
+// lock : record waiter and lock order, then lock
+func (d *DebugLocker[T]) lock(ctx context.Context, key T, write bool) error {
+	var h = d.newHolder(write)
+	var report, cycle = d.wait(key, h)
+	if cycle {
+		d.reporter(report)
+	}
+	var err error
+	if write {
+		err = d.l.LockCtx(ctx, key)
+	} else {
+		err = d.l.RLockCtx(ctx, key)
+	}
+	d.mu.Lock()
+	d.removeWaiter(key, h)
+	if err == nil {
+		d.hold(key, h)
+	}
+	d.mu.Unlock()
+	return err
+}
+
+// tryLock : try lock without waiting, lock order is not recorded because it never blocks
+func (d *DebugLocker[T]) tryLock(key T, write bool) bool {
+	var ok bool
+	if write {
+		ok = d.l.TryLock(key)
+	} else {
+		ok = d.l.TryRLock(key)
+	}
+	if ok {
+		var h = d.newHolder(write)
+		d.mu.Lock()
+		d.hold(key, h)
+		d.mu.Unlock()
+	}
+	return ok
+}
+
+// newHolder : new holder record of current go routine
+func (d *DebugLocker[T]) newHolder(write bool) *debugHolder {
+	var h = &debugHolder{
+		Holder: Holder{
+			Goid:  goid(),
+			Write: write,
+			Since: time.Now(),
+		},
+	}
+	if d.stack {
+		h.Stack = stack()
+	}
+	return h
+}
+
+// wait : record waiter, add lock order edges from keys held by the go routine to key
+func (d *DebugLocker[T]) wait(key T, h *debugHolder) (report DebugReport[T], cycle bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var dk = d.keys[key]
+	if dk == nil {
+		dk = &debugKey{}
+		d.keys[key] = dk
+	}
+	dk.waiters = append(dk.waiters, h)
+
+	for _, k := range d.held[h.Goid] {
+		if k == key || !d.addEdge(k, key) || cycle {
+			continue
+		}
+		//key -> ... -> k exists, k -> key closes the cycle
+		var path = d.findPath(key, k)
+		if path != nil {
+			report = DebugReport[T]{
+				Kind:   ReportOrderCycle,
+				Key:    key,
+				Holder: h.Holder,
+				Cycle:  append([]T{k, key}, path...),
+			}
+			cycle = true
+		}
+	}
+	return report, cycle
+}
+
+// hold : record holder, must be called with mu held
+func (d *DebugLocker[T]) hold(key T, h *debugHolder) {
+	var dk = d.keys[key]
+	if dk == nil {
+		dk = &debugKey{}
+		d.keys[key] = dk
+	}
+	h.Since = time.Now()
+	dk.holders = append(dk.holders, h)
+	d.held[h.Goid] = append(d.held[h.Goid], key)
+	if d.holdThreshold > 0 {
+		h.timer = time.AfterFunc(d.holdThreshold, func() {
+			d.holdTooLong(key, h)
+		})
+	}
+}
+
+// release : remove holder record, the holder of current go routine is preferred for read lock
+func (d *DebugLocker[T]) release(key T, write bool) {
+	var id = goid()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var dk = d.keys[key]
+	if dk == nil {
+		return
+	}
+	var index = -1
+	for i, h := range dk.holders {
+		if h.Write != write {
+			continue
+		}
+		if index < 0 || h.Goid == id {
+			index = i
+		}
+		if h.Goid == id {
+			break
+		}
+	}
+	if index < 0 {
+		return
+	}
+	var h = dk.holders[index]
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+	dk.holders = slices.Delete(dk.holders, index, index+1)
+	d.tryFree(key, dk)
+
+	var ks = d.held[h.Goid]
+	var i = slices.Index(ks, key)
+	if i >= 0 {
+		ks = slices.Delete(ks, i, i+1)
+	}
+	if len(ks) == 0 {
+		delete(d.held, h.Goid)
+	} else {
+		d.held[h.Goid] = ks
+	}
+}
+
+// removeWaiter : remove waiter record, must be called with mu held
+func (d *DebugLocker[T]) removeWaiter(key T, h *debugHolder) {
+	var dk = d.keys[key]
+	if dk == nil {
+		return
+	}
+	dk.waiters = slices.DeleteFunc(dk.waiters, func(x *debugHolder) bool {
+		return x == h
+	})
+	d.tryFree(key, dk)
+}
+
+// tryFree : remove key record if no holder and waiter
+func (d *DebugLocker[T]) tryFree(key T, dk *debugKey) {
+	if len(dk.holders) == 0 && len(dk.waiters) == 0 {
+		delete(d.keys, key)
+	}
+}
+
+// holdTooLong : report if the holder still holds the key
+func (d *DebugLocker[T]) holdTooLong(key T, h *debugHolder) {
+	d.mu.Lock()
+	var dk = d.keys[key]
+	var held = dk != nil && slices.Contains(dk.holders, h)
+	d.mu.Unlock()
+	if held {
+		d.reporter(DebugReport[T]{Kind: ReportHoldTooLong, Key: key, Holder: h.Holder})
+	}
+}
+
+// addEdge : add lock order edge, return false if it exists or edges are full
+func (d *DebugLocker[T]) addEdge(from, to T) bool {
+	var m = d.edges[from]
+	if _, ok := m[to]; ok {
+		return false
+	}
+	if d.nEdges >= d.maxEdges {
+		return false
+	}
+	if m == nil {
+		m = make(map[T]struct{})
+		d.edges[from] = m
+	}
+	m[to] = struct{}{}
+	d.nEdges++
+	return true
+}
+
+// findPath : find lock order path from -> ... -> to by BFS, return keys after from, nil if not found
+func (d *DebugLocker[T]) findPath(from, to T) []T {
+	var prev = map[T]T{from: from}
+	var queue = []T{from}
+	for len(queue) > 0 {
+		var cur = queue[0]
+		queue = queue[1:]
+		for next := range d.edges[cur] {
+			if _, visited := prev[next]; visited {
+				continue
+			}
+			prev[next] = cur
+			if next != to {
+				queue = append(queue, next)
+				continue
+			}
+			var path []T
+			for k := to; k != from; k = prev[k] {
+				path = append(path, k)
+			}
+			slices.Reverse(path)
+			return path
+		}
+	}
+	return nil
+}
+
+// copyHolders : copy holder records
+func copyHolders(hs []*debugHolder) []Holder {
+	if len(hs) == 0 {
+		return nil
+	}
+	var r = make([]Holder, len(hs))
+	for i, h := range hs {
+		r[i] = h.Holder
+	}
+	return r
+}
+
+// logReport : default reporter, log warning by ulog
+func logReport[T comparable](r DebugReport[T]) {
+	var fields = []zap.Field{
+		zap.Any("key", r.Key),
+		zap.Uint64("goid", r.Holder.Goid),
+		zap.Bool("write", r.Holder.Write),
+		zap.Time("since", r.Holder.Since),
+	}
+	switch r.Kind {
+	case ReportHoldTooLong:
+		fields = append(fields, zap.Duration("held", time.Since(r.Holder.Since)))
+	case ReportOrderCycle:
+		fields = append(fields, zap.Any("cycle", r.Cycle))
+	}
+	if r.Holder.Stack != "" {
+		fields = append(fields, zap.String("stack", r.Holder.Stack))
+	}
+	ulog.Warn("keylock."+r.Kind.String(), fields...)
+}
+
+// goid : id of current go routine, parsed from the first line of stack "goroutine 1 [running]:"
+func goid() uint64 {
+	var buf [64]byte
+	var b = buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	var i = bytes.IndexByte(b, ' ')
+	if i < 0 {
+		return 0
+	}
+	var id, _ = strconv.ParseUint(string(b[:i]), 10, 64)
+	return id
+}
+
+// stack : stack of current go routine
+func stack() string {
+	var buf = make([]byte, 4096)
+	for {
+		var n = runtime.Stack(buf, false)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
@@ -0,0 +1,111 @@
+package keylock
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDebugLocker_Snapshot(t *testing.T) {
+	var x ExtLocker[any] = NewDebugLocker[any](NewKeyLockerInstance(), WithHoldThreshold(0))
+	var d = x.(*DebugLocker[any])
+	x.Lock("a")
+	x.RLock("b")
+	x.RLock("b")
+
+	var locked = make(chan struct{})
+	go func() {
+		x.Lock("a")
+		close(locked)
+	}()
+	for {
+		var ss = d.Snapshot()
+		if len(ss) == 2 && len(ss[0].Waiters) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	var ss = d.Snapshot()
+	if ss[0].Key != "a" || len(ss[0].Holders) != 1 || !ss[0].Holders[0].Write || ss[0].Holders[0].Goid != goid() {
+		t.Errorf("unexpected state of a: %+v", ss[0])
+	}
+	if ss[0].Holders[0].Stack == "" || ss[0].Waiters[0].Goid == goid() {
+		t.Errorf("unexpected holder or waiter of a: %+v", ss[0])
+	}
+	if ss[1].Key != "b" || len(ss[1].Holders) != 2 || ss[1].Holders[0].Write || len(ss[1].Waiters) != 0 {
+		t.Errorf("unexpected state of b: %+v", ss[1])
+	}
+
+	x.Unlock("a")
+	<-locked
+	x.Unlock("a")
+	x.RUnlocks([]any{"b"})
+	x.RUnlock("b")
+	if ss = d.Snapshot(); len(ss) != 0 {
+		t.Errorf("all keys should be released, got %+v", ss)
+	}
+}
+
+func TestDebugLocker_Report(t *testing.T) {
+	var mu sync.Mutex
+	var reports []DebugReport[string]
+	var d = NewDebugLocker[string](NewTKeyLockerInstance[string](),
+		WithStack(false),
+		WithHoldThreshold(20*time.Millisecond),
+		WithReporter(func(r DebugReport[string]) {
+			mu.Lock()
+			reports = append(reports, r)
+			mu.Unlock()
+		}))
+
+	// a -> b
+	d.Lock("a")
+	d.Lock("b")
+	d.Unlock("b")
+	d.Unlock("a")
+	// b -> c
+	d.Lock("b")
+	d.RLock("c")
+	d.RUnlock("c")
+	d.Unlock("b")
+	// c -> a closes the cycle
+	d.RLock("c")
+	d.Lock("a")
+	time.Sleep(50 * time.Millisecond)
+	d.Unlock("a")
+	d.RUnlock("c")
+	// try lock does not record order
+	d.Lock("c")
+	if !d.TryLock("b") {
+		t.Fatal("b should be locked")
+	}
+	d.Unlock("b")
+	d.Unlock("c")
+
+	mu.Lock()
+	defer mu.Unlock()
+	var cycles, holds int
+	for _, r := range reports {
+		switch r.Kind {
+		case ReportOrderCycle:
+			cycles++
+			if r.Key != "a" || r.Holder.Goid != goid() || r.Holder.Stack != "" {
+				t.Errorf("unexpected report %+v", r)
+			}
+			var expected = []string{"c", "a", "b", "c"}
+			if len(r.Cycle) != len(expected) {
+				t.Fatalf("unexpected cycle %v", r.Cycle)
+			}
+			for i := range expected {
+				if r.Cycle[i] != expected[i] {
+					t.Fatalf("unexpected cycle %v", r.Cycle)
+				}
+			}
+		case ReportHoldTooLong:
+			holds++
+		}
+	}
+	if cycles != 1 || holds != 2 {
+		t.Errorf("expected 1 cycle and 2 hold reports, got %d %d", cycles, holds)
+	}
+}
@@ -1,6 +1,9 @@
 package keylock
 
 import (
+	"context"
+	"time"
+
 	"github.com/pinealctx/neptune/remap"
 )
 
@@ -30,6 +33,66 @@ func (w *KeyLockerGrp) RUnlock(key any) {
 	w.calculateKey(key).RUnlock(key)
 }
 
+// TryLock try write lock without waiting, return true if locked
+func (w *KeyLockerGrp) TryLock(key any) bool {
+	return w.calculateKey(key).TryLock(key)
+}
+
+// TryRLock try read lock without waiting, return true if locked
+func (w *KeyLockerGrp) TryRLock(key any) bool {
+	return w.calculateKey(key).TryRLock(key)
+}
+
+// LockCtx write lock, return ctx error if ctx is done before locked
+func (w *KeyLockerGrp) LockCtx(ctx context.Context, key any) error {
+	return w.calculateKey(key).LockCtx(ctx, key)
+}
+
+// RLockCtx read lock, return ctx error if ctx is done before locked
+func (w *KeyLockerGrp) RLockCtx(ctx context.Context, key any) error {
+	return w.calculateKey(key).RLockCtx(ctx, key)
+}
+
+// LockTimeout write lock, return context.DeadlineExceeded if not locked in timeout
+func (w *KeyLockerGrp) LockTimeout(key any, timeout time.Duration) error {
+	return w.calculateKey(key).LockTimeout(key, timeout)
+}
+
+// RLockTimeout read lock, return context.DeadlineExceeded if not locked in timeout
+func (w *KeyLockerGrp) RLockTimeout(key any, timeout time.Duration) error {
+	return w.calculateKey(key).RLockTimeout(key, timeout)
+}
+
+// Locks write lock multi keys, keys are deduplicated and locked in HashOrder to avoid deadlock
+func (w *KeyLockerGrp) Locks(keys []any) {
+	_ = w.LocksCtx(context.Background(), keys)
+}
+
+// Unlocks write unlock multi keys
+func (w *KeyLockerGrp) Unlocks(keys []any) {
+	unlockAll(sortKeys(keys, HashOrder[any]), w.Unlock)
+}
+
+// RLocks read lock multi keys, keys are deduplicated and locked in HashOrder to avoid deadlock
+func (w *KeyLockerGrp) RLocks(keys []any) {
+	_ = w.RLocksCtx(context.Background(), keys)
+}
+
+// RUnlocks read unlock multi keys
+func (w *KeyLockerGrp) RUnlocks(keys []any) {
+	unlockAll(sortKeys(keys, HashOrder[any]), w.RUnlock)
+}
+
+// LocksCtx write lock multi keys like Locks, all or nothing, return ctx error if ctx is done before all locked
+func (w *KeyLockerGrp) LocksCtx(ctx context.Context, keys []any) error {
+	return lockAll(ctx, sortKeys(keys, HashOrder[any]), w.LockCtx, w.Unlock)
+}
+
+// RLocksCtx read lock multi keys like RLocks, all or nothing, return ctx error if ctx is done before all locked
+func (w *KeyLockerGrp) RLocksCtx(ctx context.Context, keys []any) error {
+	return lockAll(ctx, sortKeys(keys, HashOrder[any]), w.RLockCtx, w.RUnlock)
+}
+
 // calculate key
 func (w *KeyLockerGrp) calculateKey(key any) *KeyLocker {
 	var i = w.calKeyFn(key)
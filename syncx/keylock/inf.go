@@ -1,5 +1,10 @@
 package keylock
 
+import (
+	"context"
+	"time"
+)
+
 type Locker interface {
 	// Lock write lock
 	Lock(key any)
@@ -30,3 +35,27 @@ type TLocker[T comparable] interface {
 	// RUnlocks read unlock
 	RUnlocks(keys []T)
 }
+
+// ExtLocker : locker with try, context, timeout and multi-key locking.
+// All lockers in this package implement it, type assert Locker to ExtLocker[any] or TLocker[T] to ExtLocker[T] to use it.
+type ExtLocker[T comparable] interface {
+	TLocker[T]
+
+	// TryLock try write lock without waiting, return true if locked
+	TryLock(key T) bool
+	// TryRLock try read lock without waiting, return true if locked
+	TryRLock(key T) bool
+	// LockCtx write lock, return ctx error if ctx is done before locked
+	LockCtx(ctx context.Context, key T) error
+	// RLockCtx read lock, return ctx error if ctx is done before locked
+	RLockCtx(ctx context.Context, key T) error
+	// LockTimeout write lock, return context.DeadlineExceeded if not locked in timeout
+	LockTimeout(key T, timeout time.Duration) error
+	// RLockTimeout read lock, return context.DeadlineExceeded if not locked in timeout
+	RLockTimeout(key T, timeout time.Duration) error
+
+	// LocksCtx write lock multi keys like Locks, all or nothing, return ctx error if ctx is done before all locked
+	LocksCtx(ctx context.Context, keys []T) error
+	// RLocksCtx read lock multi keys like RLocks, all or nothing, return ctx error if ctx is done before all locked
+	RLocksCtx(ctx context.Context, keys []T) error
+}
@@ -1,14 +1,127 @@
 package keylock
 
 import (
+	"container/list"
+	"context"
+	"errors"
 	"sync"
+	"time"
 )
 
+var (
+	//errBusy lock is busy, only for try lock
+	errBusy = errors.New("keylock.busy")
+)
+
+// waiter : waiter of a key, ready is closed when the lock is handed off to it
+type waiter struct {
+	write bool
+	ready chan struct{}
+}
+
 // wrap read/write locker
+// All fields are guarded by the mutex of the key locker which owns it.
+// Waiters are queued in FIFO order, a reader waits if any one is waiting, so writers are not starved.
+// On release, the lock is handed off to the waiter at head(or consecutive readers at head) directly,
+// only the waiters got the lock are woken up.
 type wrapLocker struct {
-	rwLocker   sync.RWMutex
+	//holders and waiters count, free it from map when both are 0
 	readCount  int
 	writeCount int
+
+	//reader holders count
+	readers int
+	//writer holds it
+	writer bool
+	//waiters in FIFO order
+	waiters list.List
+}
+
+// acquire : acquire write or read lock.
+// mu -- the mutex guards wrapLocker, it's held when called and returned.
+// wait -- wait until locked or ctx is done, otherwise return errBusy at once if lock is busy.
+func (w *wrapLocker) acquire(ctx context.Context, mu *sync.Mutex, write bool, wait bool) error {
+	if !w.busy(write) {
+		w.grant(write)
+		return nil
+	}
+	if !wait {
+		return errBusy
+	}
+	var wt = &waiter{write: write, ready: make(chan struct{})}
+	var e = w.waiters.PushBack(wt)
+	mu.Unlock()
+	select {
+	case <-wt.ready:
+		mu.Lock()
+		return nil
+	case <-ctx.Done():
+		mu.Lock()
+	}
+	select {
+	case <-wt.ready:
+		//handed off while giving up, pass it on
+		w.release(write)
+	default:
+		w.waiters.Remove(e)
+		//readers queued behind a writer may go now
+		w.handoff()
+	}
+	return ctx.Err()
+}
+
+// release : release write or read lock
+func (w *wrapLocker) release(write bool) {
+	if write {
+		if !w.writer {
+			panic("keylock: unlock of unlocked key")
+		}
+		w.writer = false
+	} else {
+		if w.readers <= 0 {
+			panic("keylock: runlock of unlocked key")
+		}
+		w.readers--
+	}
+	w.handoff()
+}
+
+// busy : lock can not be acquired now
+func (w *wrapLocker) busy(write bool) bool {
+	if w.writer || w.waiters.Len() > 0 {
+		return true
+	}
+	return write && w.readers > 0
+}
+
+// grant : hold the lock
+func (w *wrapLocker) grant(write bool) {
+	if write {
+		w.writer = true
+	} else {
+		w.readers++
+	}
+}
+
+// handoff : hand the lock off to a writer or consecutive readers at head of waiters
+func (w *wrapLocker) handoff() {
+	for e := w.waiters.Front(); e != nil; e = w.waiters.Front() {
+		// nolint : forcetypeassert // I know the type is exactly here
+		var wt = e.Value.(*waiter)
+		if w.writer || (wt.write && w.readers > 0) {
+			return
+		}
+		w.waiters.Remove(e)
+		w.grant(wt.write)
+		close(wt.ready)
+	}
+}
+
+// lockTimeout : call lock function with timeout context
+func lockTimeout(timeout time.Duration, lockFn func(ctx context.Context) error) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return lockFn(ctx)
 }
 
 // KeyLocker global locker based on key
@@ -33,62 +146,131 @@ func NewKeyLockerInstance() *KeyLocker {
 
 // Lock write lock
 func (d *KeyLocker) Lock(key any) {
-	var (
-		wrLocker *wrapLocker
-		ok       bool
-	)
-	d.locker.Lock()
-	wrLocker, ok = d.lockMap[key]
-	if !ok {
-		wrLocker = &wrapLocker{}
-		d.lockMap[key] = wrLocker
-	}
-	wrLocker.writeCount++
-	d.locker.Unlock()
-	wrLocker.rwLocker.Lock()
+	_ = d.lock(context.Background(), key, true, true)
 }
 
 // Unlock write unlock
 func (d *KeyLocker) Unlock(key any) {
-	var (
-		wrLocker *wrapLocker
-	)
 	d.locker.Lock()
-	wrLocker = d.lockMap[key]
-	wrLocker.rwLocker.Unlock()
-	wrLocker.writeCount--
-	d.tryFree(key, wrLocker)
+	d.unlock(key, true)
 	d.locker.Unlock()
 }
 
 // RLock read lock
 func (d *KeyLocker) RLock(key any) {
-	var (
-		wrLocker *wrapLocker
-		ok       bool
-	)
+	_ = d.lock(context.Background(), key, false, true)
+}
+
+// RUnlock read unlock
+func (d *KeyLocker) RUnlock(key any) {
 	d.locker.Lock()
-	wrLocker, ok = d.lockMap[key]
+	d.unlock(key, false)
+	d.locker.Unlock()
+}
+
+// TryLock try write lock without waiting, return true if locked
+func (d *KeyLocker) TryLock(key any) bool {
+	return d.lock(context.Background(), key, true, false) == nil
+}
+
+// TryRLock try read lock without waiting, return true if locked
+func (d *KeyLocker) TryRLock(key any) bool {
+	return d.lock(context.Background(), key, false, false) == nil
+}
+
+// LockCtx write lock, return ctx error if ctx is done before locked
+func (d *KeyLocker) LockCtx(ctx context.Context, key any) error {
+	return d.lock(ctx, key, true, true)
+}
+
+// RLockCtx read lock, return ctx error if ctx is done before locked
+func (d *KeyLocker) RLockCtx(ctx context.Context, key any) error {
+	return d.lock(ctx, key, false, true)
+}
+
+// LockTimeout write lock, return context.DeadlineExceeded if not locked in timeout
+func (d *KeyLocker) LockTimeout(key any, timeout time.Duration) error {
+	return lockTimeout(timeout, func(ctx context.Context) error {
+		return d.LockCtx(ctx, key)
+	})
+}
+
+// RLockTimeout read lock, return context.DeadlineExceeded if not locked in timeout
+func (d *KeyLocker) RLockTimeout(key any, timeout time.Duration) error {
+	return lockTimeout(timeout, func(ctx context.Context) error {
+		return d.RLockCtx(ctx, key)
+	})
+}
+
+// Locks write lock multi keys, keys are deduplicated and locked in HashOrder to avoid deadlock
+func (d *KeyLocker) Locks(keys []any) {
+	_ = d.LocksCtx(context.Background(), keys)
+}
+
+// Unlocks write unlock multi keys
+func (d *KeyLocker) Unlocks(keys []any) {
+	unlockAll(sortKeys(keys, HashOrder[any]), d.Unlock)
+}
+
+// RLocks read lock multi keys, keys are deduplicated and locked in HashOrder to avoid deadlock
+func (d *KeyLocker) RLocks(keys []any) {
+	_ = d.RLocksCtx(context.Background(), keys)
+}
+
+// RUnlocks read unlock multi keys
+func (d *KeyLocker) RUnlocks(keys []any) {
+	unlockAll(sortKeys(keys, HashOrder[any]), d.RUnlock)
+}
+
+// LocksCtx write lock multi keys like Locks, all or nothing, return ctx error if ctx is done before all locked
+func (d *KeyLocker) LocksCtx(ctx context.Context, keys []any) error {
+	return lockAll(ctx, sortKeys(keys, HashOrder[any]), d.LockCtx, d.Unlock)
+}
+
+// RLocksCtx read lock multi keys like RLocks, all or nothing, return ctx error if ctx is done before all locked
+func (d *KeyLocker) RLocksCtx(ctx context.Context, keys []any) error {
+	return lockAll(ctx, sortKeys(keys, HashOrder[any]), d.RLockCtx, d.RUnlock)
+}
+
+// lock : get or create key locker then acquire it, key locker is freed if failed
+func (d *KeyLocker) lock(ctx context.Context, key any, write bool, wait bool) error {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	var wrLocker, ok = d.lockMap[key]
 	if !ok {
 		wrLocker = &wrapLocker{}
 		d.lockMap[key] = wrLocker
 	}
-	wrLocker.readCount++
-	d.locker.Unlock()
-	wrLocker.rwLocker.RLock()
+	if write {
+		wrLocker.writeCount++
+	} else {
+		wrLocker.readCount++
+	}
+	var err = wrLocker.acquire(ctx, &d.locker, write, wait)
+	if err != nil {
+		d.done(key, wrLocker, write)
+	}
+	return err
 }
 
-// RUnlock read unlock
-func (d *KeyLocker) RUnlock(key any) {
-	var (
-		wrLocker *wrapLocker
-	)
-	d.locker.Lock()
-	wrLocker = d.lockMap[key]
-	wrLocker.rwLocker.RUnlock()
-	wrLocker.readCount--
+// unlock : release key locker, must be called with locker held
+func (d *KeyLocker) unlock(key any, write bool) {
+	var wrLocker = d.lockMap[key]
+	if wrLocker == nil {
+		panic("keylock: unlock of unlocked key")
+	}
+	wrLocker.release(write)
+	d.done(key, wrLocker, write)
+}
+
+// done : a holder or waiter leaves, try to free key locker
+func (d *KeyLocker) done(key any, wrLocker *wrapLocker, write bool) {
+	if write {
+		wrLocker.writeCount--
+	} else {
+		wrLocker.readCount--
+	}
 	d.tryFree(key, wrLocker)
-	d.locker.Unlock()
 }
 
 // try to free a key locker from map
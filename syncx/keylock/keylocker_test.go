@@ -1,6 +1,7 @@
 package keylock
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
@@ -60,3 +61,122 @@ func testLockerCrazy(t *testing.T, x Locker) {
 	var d = t2.Sub(t1)
 	t.Log("use time:", d, "average:", d/(200*time.Duration(count)))
 }
+
+func TestKeyLocker_TryAndCtx(t *testing.T) {
+	var x = NewKeyLockerInstance()
+	testLockerTryAndCtx(t, x)
+	if len(x.lockMap) != 0 {
+		t.Errorf("waiter state should be cleaned, got %d", len(x.lockMap))
+	}
+	testLockerTryAndCtx(t, NewKeyLockeGrp().(ExtLocker[any]))
+	testLockerTryAndCtx(t, NewXHashKeyLockeGrp().(ExtLocker[any]))
+}
+
+func testLockerTryAndCtx(t *testing.T, x ExtLocker[any]) {
+	t.Helper()
+	if !x.TryLock(1) {
+		t.Fatal("should be locked")
+	}
+	if x.TryLock(1) || x.TryRLock(1) {
+		t.Error("should not be locked again")
+	}
+	if err := x.LockTimeout(1, 10*time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	var ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if err := x.RLockCtx(ctx, 1); err != context.Canceled {
+		t.Errorf("expected canceled, got %v", err)
+	}
+	x.Unlock(1)
+
+	// a reader blocks a writer, the waiting writer blocks new readers until it gives up
+	if err := x.RLockCtx(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+	var writerDone = make(chan error, 1)
+	go func() {
+		writerDone <- x.LockTimeout(2, 30*time.Millisecond)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if x.TryRLock(2) {
+		t.Error("reader should wait for the pending writer")
+	}
+	if err := x.RLockTimeout(2, time.Second); err != nil {
+		t.Errorf("reader should be woken up after writer gives up, got %v", err)
+	}
+	if err := <-writerDone; err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	x.RUnlock(2)
+	x.RUnlock(2)
+	if !x.TryLock(2) {
+		t.Error("should be locked after all readers leave")
+	}
+	x.Unlock(2)
+}
+
+func TestKeyLocker_Handoff(t *testing.T) {
+	var x = NewKeyLockerInstance()
+	x.Lock(1)
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		order []int
+	)
+	var waitQueued = func(n int) {
+		for {
+			x.locker.Lock()
+			var l = x.lockMap[1].waiters.Len()
+			x.locker.Unlock()
+			if l == n {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	var run = func(i int, lockFn func() error, unlockFn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if lockFn() != nil {
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			unlockFn()
+		}()
+	}
+	var unlock = func() { x.Unlock(1) }
+	var runlock = func() { x.RUnlock(1) }
+
+	// writer 0, writer 1 gives up, reader 2 and 3, writer 4
+	run(0, func() error { return x.LockCtx(context.Background(), 1) }, unlock)
+	waitQueued(1)
+	if err := x.LockTimeout(1, 10*time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	waitQueued(1)
+	run(2, func() error { return x.RLockCtx(context.Background(), 1) }, runlock)
+	waitQueued(2)
+	run(3, func() error { return x.RLockCtx(context.Background(), 1) }, runlock)
+	waitQueued(3)
+	run(4, func() error { return x.LockCtx(context.Background(), 1) }, unlock)
+	waitQueued(4)
+
+	// the lock is handed off to a writer or consecutive readers in FIFO order
+	x.Unlock(1)
+	wg.Wait()
+	if len(order) != 4 || order[0] != 0 || order[1]+order[2] != 5 || order[3] != 4 {
+		t.Errorf("unexpected order %v", order)
+	}
+	if len(x.lockMap) != 0 {
+		t.Errorf("all keys should be freed, got %d", len(x.lockMap))
+	}
+}
@@ -0,0 +1,153 @@
+package keylock
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"hash/maphash"
+	"slices"
+)
+
+// orderSeed : hash seed of HashOrder, it's fixed in process so the order is same for all callers
+var orderSeed = maphash.MakeSeed()
+
+// KeyOrder : order of keys in multi-key locking, returns -1 if a < b, 0 if a == b, +1 if a > b.
+// To avoid deadlock, all callers locking the same keys must use the same order.
+type KeyOrder[T comparable] func(a, b T) int
+
+// CtxLocker : locker with context, all lockers in this package implement it
+type CtxLocker[T comparable] interface {
+	// LockCtx write lock, return ctx error if ctx is done before locked
+	LockCtx(ctx context.Context, key T) error
+	// Unlock write unlock
+	Unlock(key T)
+	// RLockCtx read lock, return ctx error if ctx is done before locked
+	RLockCtx(ctx context.Context, key T) error
+	// RUnlock read unlock
+	RUnlock(key T)
+}
+
+// HashOrder : default key order of multi-key locking.
+// Keys of integer or string type are compared directly, others are compared by hash.
+func HashOrder[T comparable](a, b T) int {
+	if c, ok := compareBasic(a, b); ok {
+		return c
+	}
+	if c := cmp.Compare(maphash.Comparable(orderSeed, a), maphash.Comparable(orderSeed, b)); c != 0 {
+		return c
+	}
+	if a == b {
+		return 0
+	}
+	// hash collision, it's rare
+	return cmp.Compare(fmt.Sprintf("%T:%v", a, a), fmt.Sprintf("%T:%v", b, b))
+}
+
+// LockKeys : write lock multi keys in order, all or nothing.
+// Keys are deduplicated, HashOrder is used if order is nil.
+// If ctx is done before all keys are locked, the locked keys are unlocked and ctx error is returned.
+// unlock -- unlock all keys, it's nil if failed.
+func LockKeys[T comparable](ctx context.Context, l CtxLocker[T], keys []T, order KeyOrder[T]) (unlock func(), err error) {
+	var ks = sortKeys(keys, order)
+	err = lockAll(ctx, ks, l.LockCtx, l.Unlock)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		unlockAll(ks, l.Unlock)
+	}, nil
+}
+
+// RLockKeys : read lock multi keys in order, all or nothing, see LockKeys.
+func RLockKeys[T comparable](ctx context.Context, l CtxLocker[T], keys []T, order KeyOrder[T]) (unlock func(), err error) {
+	var ks = sortKeys(keys, order)
+	err = lockAll(ctx, ks, l.RLockCtx, l.RUnlock)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		unlockAll(ks, l.RUnlock)
+	}, nil
+}
+
+// sortKeys : copy keys, sort and deduplicate them
+func sortKeys[T comparable](keys []T, order KeyOrder[T]) []T {
+	if order == nil {
+		order = HashOrder[T]
+	}
+	var ks = slices.Clone(keys)
+	slices.SortFunc(ks, order)
+	return slices.Compact(ks)
+}
+
+// lockAll : lock keys one by one, unlock the locked ones if failed
+func lockAll[T comparable](ctx context.Context, keys []T,
+	lockFn func(ctx context.Context, key T) error, unlockFn func(key T)) error {
+	for i, key := range keys {
+		var err = lockFn(ctx, key)
+		if err != nil {
+			unlockAll(keys[:i], unlockFn)
+			return err
+		}
+	}
+	return nil
+}
+
+// unlockAll : unlock keys in reverse order
+func unlockAll[T comparable](keys []T, unlockFn func(key T)) {
+	for i := len(keys) - 1; i >= 0; i-- {
+		unlockFn(keys[i])
+	}
+}
+
+// compareBasic : compare keys of integer or string type, keys of different types are ordered by type.
+// ok is false if both keys are not integer or string type.
+func compareBasic(a, b any) (c int, ok bool) {
+	var ra, rb = basicRank(a), basicRank(b)
+	if ra == 0 && rb == 0 {
+		return 0, false
+	}
+	if ra != rb {
+		return cmp.Compare(rb, ra), true
+	}
+	switch x := a.(type) {
+	case int:
+		// nolint : forcetypeassert // I know the type is exactly here
+		return cmp.Compare(x, b.(int)), true
+	case int32:
+		// nolint : forcetypeassert // I know the type is exactly here
+		return cmp.Compare(x, b.(int32)), true
+	case int64:
+		// nolint : forcetypeassert // I know the type is exactly here
+		return cmp.Compare(x, b.(int64)), true
+	case uint32:
+		// nolint : forcetypeassert // I know the type is exactly here
+		return cmp.Compare(x, b.(uint32)), true
+	case uint64:
+		// nolint : forcetypeassert // I know the type is exactly here
+		return cmp.Compare(x, b.(uint64)), true
+	default:
+		// nolint : forcetypeassert // I know the type is exactly here
+		return cmp.Compare(a.(string), b.(string)), true
+	}
+}
+
+// basicRank : rank of integer or string type, 0 for other types which are ordered after them
+func basicRank(a any) int {
+	switch a.(type) {
+	case int:
+		return 6
+	case int32:
+		return 5
+	case int64:
+		return 4
+	case uint32:
+		return 3
+	case uint64:
+		return 2
+	case string:
+		return 1
+	default:
+		return 0
+	}
+}
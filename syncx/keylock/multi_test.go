@@ -0,0 +1,88 @@
+package keylock
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashOrder(t *testing.T) {
+	var keys = []any{"b", 3, "a", int64(1), 1, struct{ x int }{1}, "b", 3}
+	var ks = sortKeys(keys, nil)
+	if len(ks) != 6 {
+		t.Fatalf("keys should be deduplicated, got %v", ks)
+	}
+	// basic types are compared directly, others are after them
+	var expected = []any{1, 3, int64(1), "a", "b", struct{ x int }{1}}
+	for i := range expected {
+		if ks[i] != expected[i] {
+			t.Fatalf("unexpected order %v", ks)
+		}
+	}
+}
+
+func TestLocker_LocksCtx(t *testing.T) {
+	for _, l := range []Locker{NewKeyLocker(), NewKeyLockeGrp(), NewXHashKeyLockeGrp()} {
+		var x = l.(ExtLocker[any])
+		x.Lock(3)
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+		if err := x.LocksCtx(ctx, []any{1, 2, 3}); err != context.DeadlineExceeded {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+		cancel()
+		// all or nothing
+		if !x.TryLock(1) || !x.TryLock(2) {
+			t.Error("locked keys should be unlocked when failed")
+		}
+		x.Unlocks([]any{1, 2, 3})
+
+		x.RLocks([]any{"a", "b", "a"})
+		if err := x.RLocksCtx(context.Background(), []any{"b", "c"}); err != nil {
+			t.Fatal(err)
+		}
+		if x.TryLock("b") {
+			t.Error("b should be read locked")
+		}
+		x.RUnlocks([]any{"a", "b", "a"})
+		x.RUnlocks([]any{"c", "b"})
+		if !x.TryLock("b") {
+			t.Error("b should be unlocked")
+		}
+		x.Unlock("b")
+	}
+}
+
+func TestLockKeys_Order(t *testing.T) {
+	var x = NewTKeyLockerInstance[string]()
+	var order = func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	}
+	var unlock, err = LockKeys[string](context.Background(), x, []string{"b", "A", "b"}, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.TryRLock("A") || x.TryRLock("b") {
+		t.Error("keys should be locked")
+	}
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err = RLockKeys[string](ctx, x, []string{"c", "b"}, order); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	unlock()
+	if len(x.lockMap) != 0 {
+		t.Errorf("all keys should be freed, got %d", len(x.lockMap))
+	}
+
+	// untyped locker
+	var y = NewKeyLockerInstance()
+	unlock, err = RLockKeys[any](context.Background(), y, []any{1, "a"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if y.TryLock(1) {
+		t.Error("1 should be read locked")
+	}
+	unlock()
+}
@@ -1,7 +1,9 @@
 package keylock
 
 import (
+	"context"
 	"slices"
+	"time"
 
 	"github.com/pinealctx/neptune/remap"
 )
@@ -38,46 +40,72 @@ func (w *TKeyLockerGrp[T]) RUnlock(key T) {
 	w.calculateKey(key).RUnlock(key)
 }
 
-// Locks write lock
+// TryLock try write lock without waiting, return true if locked
+func (w *TKeyLockerGrp[T]) TryLock(key T) bool {
+	return w.calculateKey(key).TryLock(key)
+}
+
+// TryRLock try read lock without waiting, return true if locked
+func (w *TKeyLockerGrp[T]) TryRLock(key T) bool {
+	return w.calculateKey(key).TryRLock(key)
+}
+
+// LockCtx write lock, return ctx error if ctx is done before locked
+func (w *TKeyLockerGrp[T]) LockCtx(ctx context.Context, key T) error {
+	return w.calculateKey(key).LockCtx(ctx, key)
+}
+
+// RLockCtx read lock, return ctx error if ctx is done before locked
+func (w *TKeyLockerGrp[T]) RLockCtx(ctx context.Context, key T) error {
+	return w.calculateKey(key).RLockCtx(ctx, key)
+}
+
+// LockTimeout write lock, return context.DeadlineExceeded if not locked in timeout
+func (w *TKeyLockerGrp[T]) LockTimeout(key T, timeout time.Duration) error {
+	return w.calculateKey(key).LockTimeout(key, timeout)
+}
+
+// RLockTimeout read lock, return context.DeadlineExceeded if not locked in timeout
+func (w *TKeyLockerGrp[T]) RLockTimeout(key T, timeout time.Duration) error {
+	return w.calculateKey(key).RLockTimeout(key, timeout)
+}
+
+// Locks write lock multi keys, keys are deduplicated and locked in HashOrder to avoid deadlock
 func (w *TKeyLockerGrp[T]) Locks(keys []T) {
-	var ms = w.calculateSortedMultiKeys(keys)
-	var ws = make([]*wrapLocker, 0, len(keys))
-	for _, ks := range ms {
-		ws = append(ws, w.ls[ks.index].getWriteLocks(ks.ks)...)
-	}
-	for _, wr := range ws {
-		wr.rwLocker.Lock()
-	}
+	_ = w.LocksCtx(context.Background(), keys)
 }
 
-// Unlocks write unlock
+// Unlocks write unlock multi keys
 func (w *TKeyLockerGrp[T]) Unlocks(keys []T) {
-	var m = w.calculateSortedMultiKeys(keys)
+	var m = w.calculateSortedMultiKeys(sortKeys(keys, HashOrder[T]))
 	for _, ks := range m {
-		w.ls[ks.index].Unlocks(ks.ks)
+		w.ls[ks.index].unlocks(ks.ks, true)
 	}
 }
 
-// RLocks read lock
+// RLocks read lock multi keys, keys are deduplicated and locked in HashOrder to avoid deadlock
 func (w *TKeyLockerGrp[T]) RLocks(keys []T) {
-	var ms = w.calculateSortedMultiKeys(keys)
-	var ws = make([]*wrapLocker, 0, len(keys))
-	for _, ks := range ms {
-		ws = append(ws, w.ls[ks.index].getReadLocks(ks.ks)...)
-	}
-	for _, wr := range ws {
-		wr.rwLocker.RLock()
-	}
+	_ = w.RLocksCtx(context.Background(), keys)
 }
 
-// RUnlocks read unlock
+// RUnlocks read unlock multi keys
 func (w *TKeyLockerGrp[T]) RUnlocks(keys []T) {
-	var m = w.calculateSortedMultiKeys(keys)
+	var m = w.calculateSortedMultiKeys(sortKeys(keys, HashOrder[T]))
 	for _, ks := range m {
-		w.ls[ks.index].RUnlocks(ks.ks)
+		w.ls[ks.index].unlocks(ks.ks, false)
 	}
 }
 
+// LocksCtx write lock multi keys like Locks, all or nothing, return ctx error if ctx is done before all locked
+func (w *TKeyLockerGrp[T]) LocksCtx(ctx context.Context, keys []T) error {
+	return lockAll(ctx, sortKeys(keys, HashOrder[T]), w.LockCtx, w.Unlock)
+}
+
+// RLocksCtx read lock multi keys like RLocks, all or nothing, return ctx error if ctx is done before all locked
+func (w *TKeyLockerGrp[T]) RLocksCtx(ctx context.Context, keys []T) error {
+	return lockAll(ctx, sortKeys(keys, HashOrder[T]), w.RLockCtx, w.RUnlock)
+}
+
 // calculate key
 func (w *TKeyLockerGrp[T]) calculateKey(key T) *TKeyLocker[T] {
 	var i = w.calKeyFn(key)
@@ -1,7 +1,9 @@
 package keylock
 
 import (
+	"context"
 	"sync"
+	"time"
 )
 
 // TKeyLocker global locker based on key
@@ -26,148 +28,140 @@ func NewTKeyLockerInstance[T comparable]() *TKeyLocker[T] {
 
 // Lock write lock
 func (d *TKeyLocker[T]) Lock(key T) {
-	var (
-		wrLocker *wrapLocker
-		ok       bool
-	)
-	d.locker.Lock()
-	wrLocker, ok = d.lockMap[key]
-	if !ok {
-		wrLocker = &wrapLocker{}
-		d.lockMap[key] = wrLocker
-	}
-	wrLocker.writeCount++
-	d.locker.Unlock()
-	wrLocker.rwLocker.Lock()
+	_ = d.lock(context.Background(), key, true, true)
 }
 
 // Unlock write unlock
 func (d *TKeyLocker[T]) Unlock(key T) {
-	var wrLocker *wrapLocker
-
 	d.locker.Lock()
-	wrLocker = d.lockMap[key]
-	wrLocker.rwLocker.Unlock()
-	wrLocker.writeCount--
-	d.tryFree(key, wrLocker)
+	d.unlock(key, true)
 	d.locker.Unlock()
 }
 
 // RLock read lock
 func (d *TKeyLocker[T]) RLock(key T) {
-	var (
-		wrLocker *wrapLocker
-		ok       bool
-	)
-	d.locker.Lock()
-	wrLocker, ok = d.lockMap[key]
-	if !ok {
-		wrLocker = &wrapLocker{}
-		d.lockMap[key] = wrLocker
-	}
-	wrLocker.readCount++
-	d.locker.Unlock()
-	wrLocker.rwLocker.RLock()
+	_ = d.lock(context.Background(), key, false, true)
 }
 
 // RUnlock read unlock
 func (d *TKeyLocker[T]) RUnlock(key T) {
-	var wrLocker *wrapLocker
-
 	d.locker.Lock()
-	wrLocker = d.lockMap[key]
-	wrLocker.rwLocker.RUnlock()
-	wrLocker.readCount--
-	d.tryFree(key, wrLocker)
+	d.unlock(key, false)
 	d.locker.Unlock()
 }
 
-// Locks write lock
+// TryLock try write lock without waiting, return true if locked
+func (d *TKeyLocker[T]) TryLock(key T) bool {
+	return d.lock(context.Background(), key, true, false) == nil
+}
+
+// TryRLock try read lock without waiting, return true if locked
+func (d *TKeyLocker[T]) TryRLock(key T) bool {
+	return d.lock(context.Background(), key, false, false) == nil
+}
+
+// LockCtx write lock, return ctx error if ctx is done before locked
+func (d *TKeyLocker[T]) LockCtx(ctx context.Context, key T) error {
+	return d.lock(ctx, key, true, true)
+}
+
+// RLockCtx read lock, return ctx error if ctx is done before locked
+func (d *TKeyLocker[T]) RLockCtx(ctx context.Context, key T) error {
+	return d.lock(ctx, key, false, true)
+}
+
+// LockTimeout write lock, return context.DeadlineExceeded if not locked in timeout
+func (d *TKeyLocker[T]) LockTimeout(key T, timeout time.Duration) error {
+	return lockTimeout(timeout, func(ctx context.Context) error {
+		return d.LockCtx(ctx, key)
+	})
+}
+
+// RLockTimeout read lock, return context.DeadlineExceeded if not locked in timeout
+func (d *TKeyLocker[T]) RLockTimeout(key T, timeout time.Duration) error {
+	return lockTimeout(timeout, func(ctx context.Context) error {
+		return d.RLockCtx(ctx, key)
+	})
+}
+
+// Locks write lock multi keys, keys are deduplicated and locked in HashOrder to avoid deadlock
 func (d *TKeyLocker[T]) Locks(keys []T) {
-	var ws = d.getWriteLocks(keys)
-	for _, wrLocker := range ws {
-		wrLocker.rwLocker.Lock()
-	}
+	_ = d.LocksCtx(context.Background(), keys)
 }
 
-// Unlocks write unlock
+// Unlocks write unlock multi keys
 func (d *TKeyLocker[T]) Unlocks(keys []T) {
-	var wrLocker *wrapLocker
-
-	d.locker.Lock()
-	for _, key := range keys {
-		wrLocker = d.lockMap[key]
-		wrLocker.rwLocker.Unlock()
-		wrLocker.writeCount--
-		d.tryFree(key, wrLocker)
-	}
-	d.locker.Unlock()
+	d.unlocks(sortKeys(keys, HashOrder[T]), true)
 }
 
-// RLocks read lock
+// RLocks read lock multi keys, keys are deduplicated and locked in HashOrder to avoid deadlock
 func (d *TKeyLocker[T]) RLocks(keys []T) {
-	var ws = d.getReadLocks(keys)
-	for _, wrLocker := range ws {
-		wrLocker.rwLocker.RLock()
-	}
+	_ = d.RLocksCtx(context.Background(), keys)
 }
 
-// RUnlocks read unlock
+// RUnlocks read unlock multi keys
 func (d *TKeyLocker[T]) RUnlocks(keys []T) {
-	var wrLocker *wrapLocker
+	d.unlocks(sortKeys(keys, HashOrder[T]), false)
+}
 
-	d.locker.Lock()
-	for _, key := range keys {
-		wrLocker = d.lockMap[key]
-		wrLocker.rwLocker.RUnlock()
-		wrLocker.readCount--
-		d.tryFree(key, wrLocker)
-	}
-	d.locker.Unlock()
+// LocksCtx write lock multi keys like Locks, all or nothing, return ctx error if ctx is done before all locked
+func (d *TKeyLocker[T]) LocksCtx(ctx context.Context, keys []T) error {
+	return lockAll(ctx, sortKeys(keys, HashOrder[T]), d.LockCtx, d.Unlock)
+}
+
+// RLocksCtx read lock multi keys like RLocks, all or nothing, return ctx error if ctx is done before all locked
+func (d *TKeyLocker[T]) RLocksCtx(ctx context.Context, keys []T) error {
+	return lockAll(ctx, sortKeys(keys, HashOrder[T]), d.RLockCtx, d.RUnlock)
 }
 
-// get write locks
-func (d *TKeyLocker[T]) getWriteLocks(keys []T) []*wrapLocker {
-	var (
-		wrLocker *wrapLocker
-		ok       bool
-		ws       []*wrapLocker
-	)
-	ws = make([]*wrapLocker, len(keys))
+// lock : get or create key locker then acquire it, key locker is freed if failed
+func (d *TKeyLocker[T]) lock(ctx context.Context, key T, write bool, wait bool) error {
 	d.locker.Lock()
-	for i, key := range keys {
-		wrLocker, ok = d.lockMap[key]
-		if !ok {
-			wrLocker = &wrapLocker{}
-			d.lockMap[key] = wrLocker
-		}
+	defer d.locker.Unlock()
+	var wrLocker, ok = d.lockMap[key]
+	if !ok {
+		wrLocker = &wrapLocker{}
+		d.lockMap[key] = wrLocker
+	}
+	if write {
 		wrLocker.writeCount++
-		ws[i] = wrLocker
+	} else {
+		wrLocker.readCount++
 	}
-	d.locker.Unlock()
-	return ws
+	var err = wrLocker.acquire(ctx, &d.locker, write, wait)
+	if err != nil {
+		d.done(key, wrLocker, write)
+	}
+	return err
 }
 
-// get read locks
-func (d *TKeyLocker[T]) getReadLocks(keys []T) []*wrapLocker {
-	var (
-		wrLocker *wrapLocker
-		ok       bool
-		ws       []*wrapLocker
-	)
-	ws = make([]*wrapLocker, len(keys))
+// unlocks : release deduplicated keys with locker held once
+func (d *TKeyLocker[T]) unlocks(keys []T, write bool) {
 	d.locker.Lock()
-	for i, key := range keys {
-		wrLocker, ok = d.lockMap[key]
-		if !ok {
-			wrLocker = &wrapLocker{}
-			d.lockMap[key] = wrLocker
-		}
-		wrLocker.readCount++
-		ws[i] = wrLocker
+	defer d.locker.Unlock()
+	for _, key := range keys {
+		d.unlock(key, write)
 	}
-	d.locker.Unlock()
-	return ws
+}
+
+// unlock : release key locker, must be called with locker held
+func (d *TKeyLocker[T]) unlock(key T, write bool) {
+	var wrLocker = d.lockMap[key]
+	if wrLocker == nil {
+		panic("keylock: unlock of unlocked key")
+	}
+	wrLocker.release(write)
+	d.done(key, wrLocker, write)
+}
+
+// done : a holder or waiter leaves, try to free key locker
+func (d *TKeyLocker[T]) done(key T, wrLocker *wrapLocker, write bool) {
+	if write {
+		wrLocker.writeCount--
+	} else {
+		wrLocker.readCount--
+	}
+	d.tryFree(key, wrLocker)
 }
 
 // try to free a key locker from map
@@ -1,6 +1,7 @@
 package keylock
 
 import (
+	"context"
 	"sort"
 	"sync"
 	"testing"
@@ -223,3 +224,31 @@ func testTLockerRandCrazy(t *testing.T, x TLocker[int], ks [][]int) {
 	var d = t2.Sub(t1)
 	t.Log("use time:", d, "average:", d/(200*time.Duration(count)))
 }
+
+func TestTKeyLocker_TryAndCtx(t *testing.T) {
+	for _, l := range []TLocker[string]{NewTKeyLocker[string](), NewTKeyLockeGrp[string](), NewTXHashTKeyLockeGrp[string]()} {
+		var x = l.(ExtLocker[string])
+		if !x.TryRLock("a") || !x.TryRLock("a") {
+			t.Fatal("readers should share lock")
+		}
+		if x.TryLock("a") {
+			t.Error("writer should not be locked")
+		}
+		if err := x.LockTimeout("a", 10*time.Millisecond); err != context.DeadlineExceeded {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+		x.RUnlock("a")
+		x.RUnlock("a")
+		if err := x.LockCtx(context.Background(), "a"); err != nil {
+			t.Fatal(err)
+		}
+		x.Unlock("a")
+	}
+	var x = NewTKeyLockerInstance[string]()
+	x.Lock("b")
+	_ = x.RLockTimeout("b", time.Millisecond)
+	x.Unlock("b")
+	if len(x.lockMap) != 0 {
+		t.Errorf("waiter state should be cleaned, got %d", len(x.lockMap))
+	}
+}
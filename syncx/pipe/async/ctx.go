@@ -3,6 +3,8 @@ package async
 import (
 	"context"
 	"reflect"
+
+	"github.com/pinealctx/neptune/errorx"
 )
 
 // Delegate : proc delegate function
@@ -16,10 +18,17 @@ type Proc interface {
 
 // ctx runner
 type ctxRunnerI interface {
-	//run
-	run()
+	//run, return true if context is done before running
+	//ctx -- context passed to function, it's the context of item or derived from it
+	run(ctx context.Context) (canceled bool)
+	//release waiter, it must be called after run
+	release()
 	//get result
 	r() (any, error)
+	//context of item
+	context() context.Context
+	//error of running, only valid after run
+	runErr() error
 }
 
 // callCtxT call function context
@@ -58,6 +67,16 @@ func newCallCtx(ctx context.Context, fn any, arg any) *callCtxT {
 	}
 }
 
+// context : context of item
+func (c *callCtxT) context() context.Context {
+	return c.ctx
+}
+
+// runErr : error of running
+func (c *callCtxT) runErr() error {
+	return c.err
+}
+
 // r : get result with wait
 func (c *callCtxT) r() (any, error) {
 	select {
@@ -68,21 +87,26 @@ func (c *callCtxT) r() (any, error) {
 	}
 }
 
+// release : release waiter
+func (c *callCtxT) release() {
+	close(c.wait)
+}
+
 // run
-func (c *callCtxT) run() {
+func (c *callCtxT) run(ctx context.Context) (canceled bool) {
 	var params [2]reflect.Value
 
-	defer close(c.wait)
+	defer errorx.Recover(&c.err)
 
 	select {
 	//if context done, return
 	case <-c.ctx.Done():
 		c.err = c.ctx.Err()
-		return
+		return true
 	default:
 	}
 
-	params[0] = reflect.ValueOf(c.ctx)
+	params[0] = reflect.ValueOf(ctx)
 	params[1] = reflect.ValueOf(c.arg)
 	var rets = c.functionValue.Call(params[:])
 	c.result = rets[0].Interface()
@@ -90,6 +114,7 @@ func (c *callCtxT) run() {
 		// nolint : forcetypeassert // I know the type is exactly here
 		c.err = rets[1].Interface().(error)
 	}
+	return false
 }
 
 // delegateCtxT : proc context, interface
@@ -115,6 +140,16 @@ func newDelegateCtx(ctx context.Context, delegate Delegate) *delegateCtxT {
 	}
 }
 
+// context : context of item
+func (c *delegateCtxT) context() context.Context {
+	return c.ctx
+}
+
+// runErr : error of running
+func (c *delegateCtxT) runErr() error {
+	return c.err
+}
+
 // r : get result with wait
 func (c *delegateCtxT) r() (any, error) {
 	select {
@@ -125,18 +160,24 @@ func (c *delegateCtxT) r() (any, error) {
 	}
 }
 
+// release : release waiter
+func (c *delegateCtxT) release() {
+	close(c.wait)
+}
+
 // run
-func (c *delegateCtxT) run() {
-	defer close(c.wait)
+func (c *delegateCtxT) run(ctx context.Context) (canceled bool) {
+	defer errorx.Recover(&c.err)
 
 	select {
 	//if context done, return
 	case <-c.ctx.Done():
 		c.err = c.ctx.Err()
-		return
+		return true
 	default:
 	}
-	c.result, c.err = c.delegate(c.ctx)
+	c.result, c.err = c.delegate(ctx)
+	return false
 }
 
 // procCtxT : proc context, interface
@@ -162,6 +203,16 @@ func newProcCtx(ctx context.Context, proc Proc) *procCtxT {
 	}
 }
 
+// context : context of item
+func (c *procCtxT) context() context.Context {
+	return c.ctx
+}
+
+// runErr : error of running
+func (c *procCtxT) runErr() error {
+	return c.err
+}
+
 // r : get result with wait
 func (c *procCtxT) r() (any, error) {
 	select {
@@ -172,16 +223,22 @@ func (c *procCtxT) r() (any, error) {
 	}
 }
 
+// release : release waiter
+func (c *procCtxT) release() {
+	close(c.wait)
+}
+
 // run
-func (c *procCtxT) run() {
-	defer close(c.wait)
+func (c *procCtxT) run(ctx context.Context) (canceled bool) {
+	defer errorx.Recover(&c.err)
 
 	select {
 	//if context done, return
 	case <-c.ctx.Done():
 		c.err = c.ctx.Err()
-		return
+		return true
 	default:
 	}
-	c.result, c.err = c.proc.Do(c.ctx)
+	c.result, c.err = c.proc.Do(ctx)
+	return false
 }
@@ -3,6 +3,8 @@ package async
 import (
 	"context"
 	"testing"
+
+	"github.com/pinealctx/neptune/errorx"
 )
 
 func TestCtxRun(t *testing.T) {
@@ -11,7 +13,8 @@ func TestCtxRun(t *testing.T) {
 	}
 	var aCtx = newCallCtx(context.Background(), f1, 1)
 	//should panic
-	aCtx.run()
+	aCtx.run(aCtx.ctx)
+	aCtx.release()
 	var r, err = aCtx.r()
 	if err != nil {
 		t.Error(err)
@@ -26,19 +29,18 @@ func TestCtxRun(t *testing.T) {
 }
 
 func TestCtxRunPanic(t *testing.T) {
-	defer func() {
-		var e = recover()
-		if e == nil {
-			t.Error("should panic")
-			t.Fail()
-		}
-		t.Log("panic catch:", e)
-	}()
 	var f1 = func(_ context.Context, _ int) (int, error) {
 		return 10, nil
 	}
 	var aCtx = newCallCtx(context.Background(), f1, "1")
-	//should panic
-	aCtx.run()
-	t.Log(aCtx.r())
+	//panic is returned as error
+	aCtx.run(aCtx.ctx)
+	aCtx.release()
+	var r, err = aCtx.r()
+	var pe *errorx.PanicError
+	if !errorx.As(err, &pe) {
+		t.Error("should return panic error")
+		return
+	}
+	t.Log("panic catch:", r, err)
 }
@@ -1,6 +1,10 @@
 package async
 
-import "sync"
+import (
+	"sync"
+
+	"github.com/pinealctx/neptune/syncx/pipe"
+)
 
 const (
 	//DefaultQSize default runner queue size
@@ -15,6 +19,8 @@ type optionT struct {
 	wg *sync.WaitGroup
 	//name
 	name string
+	//observer
+	observer pipe.Observer
 }
 
 // Option : only qSize option
@@ -41,3 +47,10 @@ func WithWaitGroup(wg *sync.WaitGroup) Option {
 		o.wg = wg
 	}
 }
+
+// WithObserver : setup observer, the runner name is used as point name
+func WithObserver(observer pipe.Observer) Option {
+	return func(o *optionT) {
+		o.observer = observer
+	}
+}
@@ -3,9 +3,11 @@ package async
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 
+	"github.com/pinealctx/neptune/errorx"
 	"github.com/pinealctx/neptune/ulog"
 )
 
@@ -45,17 +47,19 @@ func (c *procChanCtxT) r(stopChan <-chan struct{}) (any, error) {
 }
 
 // run
-func (c *procChanCtxT) run() {
+func (c *procChanCtxT) run() (canceled bool) {
 	defer close(c.wait)
+	defer errorx.Recover(&c.err)
 
 	select {
 	//if context done, return
 	case <-c.ctx.Done():
 		c.err = c.ctx.Err()
-		return
+		return true
 	default:
 	}
 	c.result, c.err = c.proc.Do(c.ctx)
+	return false
 }
 
 // ProcChan : async proc chan
@@ -79,6 +83,9 @@ type ProcChan struct {
 
 	//set a name
 	name string
+
+	//count of procs skipped because context is done before running
+	canceled atomic.Uint64
 }
 
 // NewProcChan : new async proc queue
@@ -104,6 +111,11 @@ func (c *ProcChan) Size() int {
 	return c.size
 }
 
+// Canceled : count of procs skipped because caller context is done before running
+func (c *ProcChan) Canceled() uint64 {
+	return c.canceled.Load()
+}
+
 // AsyncProc : async proc
 // ctx -- context.Context
 // proc -- proc interface
@@ -167,7 +179,9 @@ func (c *ProcChan) popLoop() {
 
 		select {
 		case cc = <-c.ch:
-			cc.run()
+			if cc.run() {
+				c.canceled.Add(1)
+			}
 		case <-c.stopChan:
 			ulog.Debug("quit.in.proc.handler",
 				zap.String("name", c.name),
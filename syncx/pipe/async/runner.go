@@ -3,9 +3,12 @@ package async
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/pinealctx/neptune/syncx/pipe"
 	"github.com/pinealctx/neptune/ulog"
 )
 
@@ -27,6 +30,21 @@ type RunnerQ struct {
 
 	//set a name
 	name string
+
+	//count of calls skipped because context is done before running
+	canceled atomic.Uint64
+
+	//observer, nil if not set
+	observer pipe.Observer
+	//observed point
+	at pipe.Point
+}
+
+// observedT : observed queue item
+type observedT struct {
+	ctxRunnerI
+	//enqueue time
+	enqAt time.Time
 }
 
 // NewRunnerQ : new async runner queue
@@ -42,6 +60,8 @@ func NewRunnerQ(opts ...Option) *RunnerQ {
 	c.wg = o.wg
 	c.stopChan = make(chan struct{})
 	c.name = o.name
+	c.observer = o.observer
+	c.at = pipe.Point{Name: o.name}
 	return c
 }
 
@@ -50,6 +70,13 @@ func (c *RunnerQ) Size() int {
 	return c.q.size
 }
 
+// Canceled : count of calls skipped because caller context is done before running.
+// The context passed to AsyncCall/AsyncDelegate/AsyncProc is passed to the function,
+// so deadline of caller is also visible in the function.
+func (c *RunnerQ) Canceled() uint64 {
+	return c.canceled.Load()
+}
+
 // AsyncCall : async call
 // ctx -- context.Context
 // callCtxT -- call context
@@ -107,25 +134,57 @@ func (c *RunnerQ) WaitStop() {
 
 // addCallCtx : add call context
 func (c *RunnerQ) addCallCtx(ctx context.Context, fn any, arg any) (*callCtxT, error) {
+	var enqAt time.Time
+	ctx, enqAt = pipe.ObserveEnqueue(c.observer, ctx, c.at)
 	var callCtx = newCallCtx(ctx, fn, arg)
-	var err = c.q.Add(callCtx)
+	var err = c.add(ctx, callCtx, enqAt)
 	return callCtx, err
 }
 
 // addDelegateCtx : add delegate context
 func (c *RunnerQ) addDelegateCtx(ctx context.Context, delegate Delegate) (*delegateCtxT, error) {
+	var enqAt time.Time
+	ctx, enqAt = pipe.ObserveEnqueue(c.observer, ctx, c.at)
 	var delegateCtx = newDelegateCtx(ctx, delegate)
-	var err = c.q.Add(delegateCtx)
+	var err = c.add(ctx, delegateCtx, enqAt)
 	return delegateCtx, err
 }
 
 // addProcCtx : add proc context
 func (c *RunnerQ) addProcCtx(ctx context.Context, proc Proc) (*procCtxT, error) {
+	var enqAt time.Time
+	ctx, enqAt = pipe.ObserveEnqueue(c.observer, ctx, c.at)
 	var procCtx = newProcCtx(ctx, proc)
-	var err = c.q.Add(procCtx)
+	var err = c.add(ctx, procCtx, enqAt)
 	return procCtx, err
 }
 
+// add : add item to queue, wrap it with enqueue time if observed
+func (c *RunnerQ) add(ctx context.Context, cc ctxRunnerI, enqAt time.Time) error {
+	if c.observer == nil {
+		return c.q.Add(cc)
+	}
+	var err = c.q.Add(&observedT{ctxRunnerI: cc, enqAt: enqAt})
+	if err != nil {
+		pipe.ObserveReject(c.observer, ctx, c.at, err)
+	}
+	return err
+}
+
+// runItem : run item, return true if context is done before running.
+// The waiter is released after observing done, so the caller returns after the observer sees it.
+func (c *RunnerQ) runItem(cc ctxRunnerI) bool {
+	defer cc.release()
+	var ob, ok = cc.(*observedT)
+	if !ok {
+		return cc.run(cc.context())
+	}
+	var ctx, deqAt = pipe.ObserveDequeue(c.observer, ob.context(), c.at, ob.enqAt)
+	var canceled = ob.run(ctx)
+	pipe.ObserveDone(c.observer, ctx, c.at, deqAt, ob.runErr())
+	return canceled
+}
+
 // pop call loop
 func (c *RunnerQ) popLoop() {
 	var (
@@ -158,6 +217,8 @@ func (c *RunnerQ) popLoop() {
 				zap.Reflect("context", item))
 			return
 		}
-		cc.run()
+		if c.runItem(cc) {
+			c.canceled.Add(1)
+		}
 	}
 }
@@ -2,8 +2,11 @@ package async
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
+
+	"github.com/pinealctx/neptune/syncx/pipe"
 )
 
 type _incT struct {
@@ -131,3 +134,68 @@ func TestRunnerQ_Proc(t *testing.T) {
 	dur = t2.Sub(t1)
 	t.Log("sync use time:", dur, "average:", dur/time.Duration(size))
 }
+
+func TestRunnerQ_SkipCanceled(t *testing.T) {
+	var runner = NewRunnerQ(WithName("test-canceled"))
+	runner.Run()
+	defer runner.Stop()
+
+	var block = make(chan struct{})
+	go func() {
+		_, _ = runner.AsyncDelegate(context.Background(), func(_ context.Context) (any, error) {
+			<-block
+			return nil, nil
+		})
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	var called bool
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var _, err = runner.AsyncDelegate(ctx, func(_ context.Context) (any, error) {
+		called = true
+		return nil, nil
+	})
+	if err == nil {
+		t.Error("expected deadline error")
+	}
+	close(block)
+
+	_, err = runner.AsyncDelegate(context.Background(), func(_ context.Context) (any, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if called {
+		t.Error("canceled delegate should be skipped")
+	}
+	if runner.Canceled() != 1 {
+		t.Errorf("expected 1 canceled, got %d", runner.Canceled())
+	}
+}
+
+func TestRunnerQ_Observer(t *testing.T) {
+	var stats = pipe.NewStatsObserver()
+	var runner = NewRunnerQ(WithName("test-observer"), WithObserver(stats))
+	runner.Run()
+	defer runner.Stop()
+
+	var inc = &_incT{}
+	for i := 0; i < 10; i++ {
+		if _, err := runner.AsyncCall(context.Background(), inc.add, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := runner.AsyncDelegate(context.Background(), func(_ context.Context) (any, error) {
+		return nil, errors.New("failed")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	var s = stats.Stats()
+	if s.Enqueued != 11 || s.Done != 11 || s.Failed != 1 || s.Depth != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
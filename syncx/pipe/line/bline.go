@@ -0,0 +1,233 @@
+package line
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pinealctx/neptune/errorx"
+	"github.com/pinealctx/neptune/syncx/pipe"
+	"github.com/pinealctx/neptune/syncx/pipe/q"
+	"github.com/pinealctx/neptune/ulog"
+)
+
+const (
+	//DefaultBatchSize default max request count in a batch
+	DefaultBatchSize = 64
+)
+
+// BatchR : result of a request in batch
+type BatchR[Rsp any] struct {
+	//Rsp : response
+	Rsp Rsp
+	//Err : error
+	Err error
+}
+
+// BatchFn : batch call function, handle a batch of requests.
+// It must return results in the same order of reqs, one for each request.
+// ctx -- it carries values of the first request context but not its cancellation.
+type BatchFn[Req, Rsp any] func(ctx context.Context, reqs []Req) []BatchR[Rsp]
+
+// BatchLine : batch async runner.
+// Requests are popped at most batch size per iteration and handled by the batch function in one go routine,
+// results are fanned back to each caller, it's useful to coalesce db writes or redis pipelines.
+type BatchLine[Req, Rsp any] struct {
+	//queue size
+	qSize int
+	//max request count in a batch
+	batchSize int
+	//max waiting time for more requests if a batch is not full
+	linger time.Duration
+	//timeout of batch function context
+	batchTimeout time.Duration
+
+	//batch call function
+	call BatchFn[Req, Rsp]
+
+	//queue
+	q *q.Q[*TAsyncCtx[Req, Rsp]]
+
+	//wait group
+	wg *sync.WaitGroup
+
+	//start once
+	startOnce sync.Once
+	//stop once
+	stopOnce sync.Once
+
+	//set a name
+	name string
+
+	//count of calls skipped because context is done before running
+	canceled atomic.Uint64
+
+	//observer, nil if not set
+	observer pipe.Observer
+	//observed point
+	at pipe.Point
+}
+
+// NewBatchLine : new batch async line
+// wg -- wait group, the pop go routine is added in it when running
+// call -- batch call function to handle requests
+func NewBatchLine[Req, Rsp any](wg *sync.WaitGroup, call BatchFn[Req, Rsp], opts ...Option) *BatchLine[Req, Rsp] {
+	var o = &_Option{
+		qSize:     pipe.DefaultQSize,
+		name:      "not-set",
+		batchSize: DefaultBatchSize,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.batchSize <= 0 {
+		o.batchSize = DefaultBatchSize
+	}
+	return &BatchLine[Req, Rsp]{
+		qSize:        o.qSize,
+		batchSize:    o.batchSize,
+		linger:       o.linger,
+		batchTimeout: o.batchTimeout,
+		call:         call,
+		q:            q.NewQ[*TAsyncCtx[Req, Rsp]](o.qSize),
+		wg:           wg,
+		name:         o.name,
+
+		observer: o.observer,
+		at:       pipe.Point{Name: o.name},
+	}
+}
+
+// QSize : get queue size
+func (c *BatchLine[Req, Rsp]) QSize() int {
+	return c.qSize
+}
+
+// BatchSize : get max request count in a batch
+func (c *BatchLine[Req, Rsp]) BatchSize() int {
+	return c.batchSize
+}
+
+// AsyncCall : push request then wait result
+func (c *BatchLine[Req, Rsp]) AsyncCall(ctx context.Context, req Req) (Rsp, error) {
+	var proc, err = c.Submit(ctx, req)
+	if err != nil {
+		var zero Rsp
+		return zero, err
+	}
+	return proc.R()
+}
+
+// Submit : push request without waiting, use R of the returned context to get result
+func (c *BatchLine[Req, Rsp]) Submit(ctx context.Context, req Req) (*TAsyncCtx[Req, Rsp], error) {
+	var enqAt time.Time
+	ctx, enqAt = pipe.ObserveEnqueue(c.observer, ctx, c.at)
+	var proc = newTAsyncCtx[Req, Rsp](ctx, req)
+	proc.enqAt = enqAt
+	var err = pipe.ConvertQueueErr(c.q.Push(proc))
+	if err != nil {
+		pipe.ObserveReject(c.observer, ctx, c.at, err)
+		return nil, err
+	}
+	return proc, nil
+}
+
+// Canceled : count of calls skipped because caller context is done before running.
+func (c *BatchLine[Req, Rsp]) Canceled() uint64 {
+	return c.canceled.Load()
+}
+
+// Run : run queue msg handler
+func (c *BatchLine[Req, Rsp]) Run() {
+	c.startOnce.Do(func() {
+		c.wg.Add(1)
+		go c.popLoop()
+	})
+}
+
+// Stop : stop
+func (c *BatchLine[Req, Rsp]) Stop() {
+	c.stopOnce.Do(func() {
+		c.q.Close()
+	})
+}
+
+// pop batch loop
+func (c *BatchLine[Req, Rsp]) popLoop() {
+	var (
+		err   error
+		batch []*TAsyncCtx[Req, Rsp]
+	)
+
+	defer c.wg.Done()
+	for {
+		batch, err = c.q.PopBatch(c.batchSize, c.linger)
+		if err != nil {
+			ulog.Debug("quit.in.batch.line.handler",
+				zap.String("name", c.name),
+				zap.Error(err))
+			return
+		}
+		c.handle(batch)
+	}
+}
+
+// handle : call batch function with requests whose caller is still waiting, then fan results back
+func (c *BatchLine[Req, Rsp]) handle(batch []*TAsyncCtx[Req, Rsp]) {
+	var (
+		zero   Rsp
+		live   = make([]*TAsyncCtx[Req, Rsp], 0, len(batch))
+		reqs   = make([]Req, 0, len(batch))
+		ctxs   = make([]context.Context, 0, len(batch))
+		deqAts = make([]time.Time, 0, len(batch))
+	)
+	for _, ac := range batch {
+		var ctx, deqAt = pipe.ObserveDequeue(c.observer, ac.ctx, c.at, ac.enqAt)
+		if err := ac.ctx.Err(); err != nil {
+			//caller gave up, skip it
+			c.canceled.Add(1)
+			pipe.ObserveDone(c.observer, ctx, c.at, deqAt, err)
+			ac.SetR(zero, err)
+			continue
+		}
+		live = append(live, ac)
+		reqs = append(reqs, ac.req)
+		ctxs = append(ctxs, ctx)
+		deqAts = append(deqAts, deqAt)
+	}
+	if len(live) == 0 {
+		return
+	}
+
+	var ctx = context.WithoutCancel(ctxs[0])
+	if c.batchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.batchTimeout)
+		defer cancel()
+	}
+	var rs, err = safeBatchCall(c.call, ctx, reqs)
+	if err == nil && len(rs) != len(reqs) {
+		ulog.Error("invalid.batch.line.result",
+			zap.String("name", c.name),
+			zap.Int("reqs", len(reqs)),
+			zap.Int("results", len(rs)))
+		err = pipe.ErrInvalidRsp
+	}
+	for i, ac := range live {
+		var r = BatchR[Rsp]{Err: err}
+		if err == nil {
+			r = rs[i]
+		}
+		pipe.ObserveDone(c.observer, ctxs[i], c.at, deqAts[i], r.Err)
+		ac.SetR(r.Rsp, r.Err)
+	}
+}
+
+// safeBatchCall : call batch function, a panic in call function is returned as error
+func safeBatchCall[Req, Rsp any](call BatchFn[Req, Rsp], ctx context.Context, reqs []Req) (rs []BatchR[Rsp], err error) {
+	defer errorx.Recover(&err)
+	return call(ctx, reqs), nil
+}
@@ -0,0 +1,145 @@
+package line
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pinealctx/neptune/errorx"
+	"github.com/pinealctx/neptune/syncx/pipe"
+)
+
+func TestBatchLine_FanOut(t *testing.T) {
+	var (
+		wg      sync.WaitGroup
+		sizes   []int
+		release = make(chan struct{})
+	)
+	var l = NewBatchLine(&wg, func(_ context.Context, reqs []int) []BatchR[int] {
+		// only one go routine handles batches, no lock needed
+		if reqs[0] == -1 {
+			<-release
+		}
+		sizes = append(sizes, len(reqs))
+		var rs = make([]BatchR[int], len(reqs))
+		for i, req := range reqs {
+			if req%10 == 3 {
+				rs[i].Err = errors.New("bad")
+				continue
+			}
+			rs[i].Rsp = req * 2
+		}
+		return rs
+	}, WithBatchSize(8), WithName("test-batch"))
+	l.Run()
+
+	// block the first batch, then requests pile up in queue
+	var first, err = l.Submit(context.Background(), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	var procs = make([]*TAsyncCtx[int, int], 0, 20)
+	for i := 0; i < 20; i++ {
+		var proc, e = l.Submit(context.Background(), i)
+		if e != nil {
+			t.Fatal(e)
+		}
+		procs = append(procs, proc)
+	}
+	close(release)
+	if _, err = first.R(); err != nil {
+		t.Fatal(err)
+	}
+	for i, proc := range procs {
+		var r, e = proc.R()
+		if i%10 == 3 {
+			if e == nil {
+				t.Errorf("expected error of %d", i)
+			}
+			continue
+		}
+		if e != nil || r != i*2 {
+			t.Errorf("unexpected result of %d: %d %v", i, r, e)
+		}
+	}
+
+	l.Stop()
+	wg.Wait()
+	var expected = []int{1, 8, 8, 4}
+	if len(sizes) != len(expected) {
+		t.Fatalf("unexpected batches %v", sizes)
+	}
+	for i := range expected {
+		if sizes[i] != expected[i] {
+			t.Fatalf("unexpected batches %v", sizes)
+		}
+	}
+}
+
+func TestBatchLine_Linger(t *testing.T) {
+	var (
+		wg    sync.WaitGroup
+		lock  sync.Mutex
+		sizes []int
+		stats = pipe.NewStatsObserver()
+	)
+	var l = NewBatchLine(&wg, func(_ context.Context, reqs []string) []BatchR[string] {
+		lock.Lock()
+		sizes = append(sizes, len(reqs))
+		lock.Unlock()
+		var rs = make([]BatchR[string], len(reqs))
+		for i, req := range reqs {
+			rs[i].Rsp = req
+		}
+		return rs
+	}, WithBatchSize(100), WithLinger(50*time.Millisecond), WithObserver(stats))
+	l.Run()
+	defer l.Stop()
+
+	var callers sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		callers.Add(1)
+		go func(i int) {
+			defer callers.Done()
+			time.Sleep(time.Duration(i) * time.Millisecond)
+			var req = string(rune('a' + i))
+			if r, err := l.AsyncCall(context.Background(), req); err != nil || r != req {
+				t.Errorf("unexpected %s %v", r, err)
+			}
+		}(i)
+	}
+	callers.Wait()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(sizes) != 1 || sizes[0] != 10 {
+		t.Errorf("requests should be coalesced in one batch, got %v", sizes)
+	}
+	if s := stats.Stats(); s.Done != 10 || s.Failed != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestBatchLine_InvalidResult(t *testing.T) {
+	var wg sync.WaitGroup
+	var l = NewBatchLine(&wg, func(_ context.Context, reqs []int) []BatchR[int] {
+		if reqs[0] < 0 {
+			panic("negative")
+		}
+		return nil
+	})
+	l.Run()
+	defer l.Stop()
+
+	if _, err := l.AsyncCall(context.Background(), 1); err != pipe.ErrInvalidRsp {
+		t.Errorf("expected ErrInvalidRsp, got %v", err)
+	}
+	var _, err = l.AsyncCall(context.Background(), -1)
+	var pe *errorx.PanicError
+	if !errorx.As(err, &pe) {
+		t.Errorf("expected panic error, got %v", err)
+	}
+}
@@ -1,6 +1,11 @@
 package line
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"github.com/pinealctx/neptune/errorx"
+)
 
 // CallFn : call function
 type CallFn func(ctx context.Context, req any) (rsp any, err error)
@@ -41,6 +46,8 @@ type AsyncCtx struct {
 	param any
 	//return chan
 	rChan chan AsyncR
+	//enqueue time, only set when observed
+	enqAt time.Time
 }
 
 // newAsyncCtx : new async call context
@@ -64,6 +71,12 @@ func (m *AsyncCtx) SetR(r any, err error) {
 	}
 }
 
+// safeCall : call function, a panic in call function is returned as error
+func (m *AsyncCtx) safeCall(ctx context.Context, param any) (r any, err error) {
+	defer errorx.Recover(&err)
+	return m.call(ctx, param)
+}
+
 // R : get response with wait
 func (m *AsyncCtx) R() (any, error) {
 	select {
@@ -3,6 +3,8 @@ package line
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -12,8 +14,14 @@ import (
 )
 
 type _Option struct {
-	qSize int
-	name  string
+	qSize    int
+	name     string
+	observer pipe.Observer
+
+	//batch line only
+	batchSize    int
+	linger       time.Duration
+	batchTimeout time.Duration
 }
 
 // Option : only qSize option
@@ -33,6 +41,36 @@ func WithName(name string) Option {
 	}
 }
 
+// WithObserver : setup observer, the line name is used as point name
+func WithObserver(observer pipe.Observer) Option {
+	return func(o *_Option) {
+		o.observer = observer
+	}
+}
+
+// WithBatchSize : setup max request count in a batch, only for BatchLine
+func WithBatchSize(batchSize int) Option {
+	return func(o *_Option) {
+		o.batchSize = batchSize
+	}
+}
+
+// WithLinger : setup max waiting time for more requests if a batch is not full, only for BatchLine
+// 0 means no waiting, handle the requests in queue at once.
+func WithLinger(linger time.Duration) Option {
+	return func(o *_Option) {
+		o.linger = linger
+	}
+}
+
+// WithBatchTimeout : setup timeout of batch function context, only for BatchLine
+// 0 means no timeout.
+func WithBatchTimeout(timeout time.Duration) Option {
+	return func(o *_Option) {
+		o.batchTimeout = timeout
+	}
+}
+
 // Line : async runner
 type Line struct {
 	//queue size
@@ -51,6 +89,14 @@ type Line struct {
 
 	//set a name
 	name string
+
+	//count of calls skipped because context is done before running
+	canceled atomic.Uint64
+
+	//observer, nil if not set
+	observer pipe.Observer
+	//observed point
+	at pipe.Point
 }
 
 // NewLine : new async line
@@ -62,13 +108,16 @@ func NewLine(wg *sync.WaitGroup, opts ...Option) *Line {
 	for _, opt := range opts {
 		opt(o)
 	}
-	return newLine(o.name, o.qSize, wg)
+	var c = newLine(o.name, o.qSize, wg)
+	c.observer = o.observer
+	return c
 }
 
 // newLine : new async line
 func newLine(name string, qSize int, wg *sync.WaitGroup) *Line {
 	var c = &Line{}
 	c.name = name
+	c.at = pipe.Point{Name: name}
 	c.qSize = qSize
 	c.q = q.NewQ[*AsyncCtx](qSize)
 	c.wg = wg
@@ -91,6 +140,12 @@ func (c *Line) AsyncCall(ctx context.Context, callCtx *CallCtx) (any, error) {
 	return proc.R()
 }
 
+// Canceled : count of calls skipped because caller context is done before running.
+// Caller context is passed to the call function, so its deadline is visible in the function.
+func (c *Line) Canceled() uint64 {
+	return c.canceled.Load()
+}
+
 // Run : run all queue msg handler
 func (c *Line) Run() {
 	c.startOnce.Do(func() {
@@ -108,17 +163,25 @@ func (c *Line) Stop() {
 
 // addCallCtx : add call context
 func (c *Line) addCallCtx(ctx context.Context, callCtx *CallCtx) (*AsyncCtx, error) {
+	var enqAt time.Time
+	ctx, enqAt = pipe.ObserveEnqueue(c.observer, ctx, c.at)
 	var proc = newAsyncCtx(ctx, callCtx.Call, callCtx.Param)
+	proc.enqAt = enqAt
 	var err = pipe.ConvertQueueErr(c.q.Push(proc))
+	if err != nil {
+		pipe.ObserveReject(c.observer, ctx, c.at, err)
+	}
 	return proc, err
 }
 
 // pop call loop
 func (c *Line) popLoop() {
 	var (
-		err error
-		ac  *AsyncCtx
-		r   any
+		err   error
+		ac    *AsyncCtx
+		r     any
+		ctx   context.Context
+		deqAt time.Time
 	)
 
 	defer c.wg.Done()
@@ -131,7 +194,16 @@ func (c *Line) popLoop() {
 			return
 		}
 
-		r, err = ac.call(ac.ctx, ac.param)
+		ctx, deqAt = pipe.ObserveDequeue(c.observer, ac.ctx, c.at, ac.enqAt)
+		if err = ac.ctx.Err(); err != nil {
+			//caller gave up, skip it
+			c.canceled.Add(1)
+			pipe.ObserveDone(c.observer, ctx, c.at, deqAt, err)
+			ac.SetR(nil, err)
+			continue
+		}
+		r, err = ac.safeCall(ctx, ac.param)
+		pipe.ObserveDone(c.observer, ctx, c.at, deqAt, err)
 		if err != nil {
 			ac.SetR(nil, err)
 		} else {
@@ -2,10 +2,15 @@ package line
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/pinealctx/neptune/errorx"
+	"github.com/pinealctx/neptune/syncx/pipe"
 )
 
 func TestLine_BasicFunctionality(t *testing.T) {
@@ -123,3 +128,153 @@ func TestLine_MultipleAsyncCalls(t *testing.T) {
 		}
 	}
 }
+
+func TestLine_PanicAsError(t *testing.T) {
+	var wg sync.WaitGroup
+
+	line := NewLine(&wg, WithQSize(10), WithName("test-line-panic"))
+	line.Run()
+	defer line.Stop()
+
+	callCtx := NewCallCtx(func(_ context.Context, _ any) (any, error) {
+		panic("boom")
+	}, nil)
+	_, err := line.AsyncCall(context.Background(), callCtx)
+	var pe *errorx.PanicError
+	if !errorx.As(err, &pe) {
+		t.Fatalf("Expected panic error, got %v", err)
+	}
+
+	// line is still alive after panic
+	callCtx = NewCallCtx(func(_ context.Context, req any) (any, error) {
+		return req, nil
+	}, 1)
+	result, err := line.AsyncCall(context.Background(), callCtx)
+	if err != nil || result != 1 {
+		t.Errorf("Expected 1, got %v, %v", result, err)
+	}
+}
+
+func TestLine_SkipCanceled(t *testing.T) {
+	var wg sync.WaitGroup
+	var l = NewLine(&wg, WithQSize(10))
+	l.Run()
+	defer l.Stop()
+
+	var block = make(chan struct{})
+	var blocking = NewCallCtx(func(_ context.Context, _ any) (any, error) {
+		<-block
+		return nil, nil
+	}, nil)
+	go func() {
+		_, _ = l.AsyncCall(context.Background(), blocking)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	var called bool
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var _, err = l.AsyncCall(ctx, NewCallCtx(func(_ context.Context, _ any) (any, error) {
+		called = true
+		return nil, nil
+	}, nil))
+	if err == nil {
+		t.Error("expected deadline error")
+	}
+	close(block)
+
+	// queued after the skipped one, make sure the skipped one has been popped
+	var r, _ = l.AsyncCall(context.Background(), NewCallCtx(func(ctx context.Context, _ any) (any, error) {
+		var _, ok = ctx.Deadline()
+		return ok, nil
+	}, nil))
+	if r != false {
+		t.Error("unexpected deadline")
+	}
+	if called {
+		t.Error("canceled call should be skipped")
+	}
+	if l.Canceled() != 1 {
+		t.Errorf("expected 1 canceled, got %d", l.Canceled())
+	}
+}
+
+// fakeSpan : record span name and state
+type fakeSpan struct {
+	name  string
+	ended atomic.Bool
+	err   error
+}
+
+func (s *fakeSpan) End() {
+	s.ended.Store(true)
+}
+
+func (s *fakeSpan) RecordError(err error) {
+	s.err = err
+}
+
+type fakeSpanKey struct{}
+
+// fakeTracer : record all spans, the current span is put in context
+type fakeTracer struct {
+	lock  sync.Mutex
+	spans []*fakeSpan
+}
+
+func (f *fakeTracer) Start(ctx context.Context, name string) (context.Context, pipe.Span) {
+	var s = &fakeSpan{name: name}
+	f.lock.Lock()
+	f.spans = append(f.spans, s)
+	f.lock.Unlock()
+	return context.WithValue(ctx, fakeSpanKey{}, s), s
+}
+
+func TestLine_Observer(t *testing.T) {
+	var (
+		wg     sync.WaitGroup
+		stats  = pipe.NewStatsObserver()
+		tracer = &fakeTracer{}
+	)
+	line := NewLine(&wg, WithName("obs"),
+		WithObserver(pipe.MultiObserver{stats, pipe.NewTraceObserver(tracer)}))
+	line.Run()
+	defer line.Stop()
+
+	var inHandler string
+	_, err := line.AsyncCall(context.Background(), NewCallCtx(func(ctx context.Context, _ any) (any, error) {
+		if s, ok := ctx.Value(fakeSpanKey{}).(*fakeSpan); ok {
+			inHandler = s.name
+		}
+		time.Sleep(5 * time.Millisecond)
+		return nil, errors.New("failed")
+	}, nil))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if inHandler != "obs.run" {
+		t.Errorf("handler should run in run span, got %q", inHandler)
+	}
+
+	var s = stats.Stats()
+	if s.Enqueued != 1 || s.Dequeued != 1 || s.Done != 1 || s.Failed != 1 || s.Depth != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	if s.TotalRun < 5*time.Millisecond {
+		t.Errorf("run duration too short %v", s.TotalRun)
+	}
+
+	tracer.lock.Lock()
+	defer tracer.lock.Unlock()
+	if len(tracer.spans) != 2 || tracer.spans[0].name != "obs.queue" || tracer.spans[1].name != "obs.run" {
+		t.Fatalf("unexpected spans %v", len(tracer.spans))
+	}
+	for _, span := range tracer.spans {
+		if !span.ended.Load() {
+			t.Errorf("span %s not ended", span.name)
+		}
+	}
+	if tracer.spans[1].err == nil {
+		t.Error("error should be recorded in run span")
+	}
+}
@@ -0,0 +1,67 @@
+package line
+
+import (
+	"context"
+	"time"
+
+	"github.com/pinealctx/neptune/errorx"
+)
+
+// TCallFn : typed call function
+type TCallFn[Req, Rsp any] func(ctx context.Context, req Req) (rsp Rsp, err error)
+
+// TAsyncR : typed async call result.
+type TAsyncR[Rsp any] struct {
+	//result
+	r Rsp
+	//error
+	err error
+}
+
+// TAsyncCtx : typed async call context
+type TAsyncCtx[Req, Rsp any] struct {
+	//context
+	ctx context.Context
+	//call param
+	req Req
+	//return chan
+	rChan chan TAsyncR[Rsp]
+	//enqueue time, only set when observed
+	enqAt time.Time
+}
+
+// newTAsyncCtx : new typed async call context
+// ctx -- context
+// req -- async call param
+func newTAsyncCtx[Req, Rsp any](ctx context.Context, req Req) *TAsyncCtx[Req, Rsp] {
+	return &TAsyncCtx[Req, Rsp]{
+		ctx:   ctx,
+		req:   req,
+		rChan: make(chan TAsyncR[Rsp], 1),
+	}
+}
+
+// SetR : set return
+func (m *TAsyncCtx[Req, Rsp]) SetR(r Rsp, err error) {
+	m.rChan <- TAsyncR[Rsp]{
+		r:   r,
+		err: err,
+	}
+}
+
+// R : get response with wait
+func (m *TAsyncCtx[Req, Rsp]) R() (Rsp, error) {
+	select {
+	case <-m.ctx.Done():
+		var zero Rsp
+		return zero, m.ctx.Err()
+	case rc := <-m.rChan:
+		return rc.r, rc.err
+	}
+}
+
+// safeTCall : call typed function, a panic in call function is returned as error
+func safeTCall[Req, Rsp any](call TCallFn[Req, Rsp], ctx context.Context, req Req) (r Rsp, err error) {
+	defer errorx.Recover(&err)
+	return call(ctx, req)
+}
@@ -0,0 +1,155 @@
+package line
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pinealctx/neptune/syncx/pipe"
+	"github.com/pinealctx/neptune/syncx/pipe/q"
+	"github.com/pinealctx/neptune/ulog"
+)
+
+// TLine : typed async runner.
+// All requests are handled by the same call function in one go routine, in the order they are pushed.
+// Compare with Line, no type assertion is needed for param or result.
+type TLine[Req, Rsp any] struct {
+	//queue size
+	qSize int
+
+	//call function
+	call TCallFn[Req, Rsp]
+
+	//queue
+	q *q.Q[*TAsyncCtx[Req, Rsp]]
+
+	//wait group
+	wg *sync.WaitGroup
+
+	//start once
+	startOnce sync.Once
+	//stop once
+	stopOnce sync.Once
+
+	//set a name
+	name string
+
+	//count of calls skipped because context is done before running
+	canceled atomic.Uint64
+
+	//observer, nil if not set
+	observer pipe.Observer
+	//observed point
+	at pipe.Point
+}
+
+// NewTLine : new typed async line
+// wg -- wait group, the pop go routine is added in it when running
+// call -- call function to handle each request
+func NewTLine[Req, Rsp any](wg *sync.WaitGroup, call TCallFn[Req, Rsp], opts ...Option) *TLine[Req, Rsp] {
+	var o = &_Option{
+		qSize: pipe.DefaultQSize,
+		name:  "not-set",
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &TLine[Req, Rsp]{
+		qSize: o.qSize,
+		call:  call,
+		q:     q.NewQ[*TAsyncCtx[Req, Rsp]](o.qSize),
+		wg:    wg,
+		name:  o.name,
+
+		observer: o.observer,
+		at:       pipe.Point{Name: o.name},
+	}
+}
+
+// QSize : get queue size
+func (c *TLine[Req, Rsp]) QSize() int {
+	return c.qSize
+}
+
+// AsyncCall : push request then wait result
+func (c *TLine[Req, Rsp]) AsyncCall(ctx context.Context, req Req) (Rsp, error) {
+	var proc, err = c.Submit(ctx, req)
+	if err != nil {
+		var zero Rsp
+		return zero, err
+	}
+	return proc.R()
+}
+
+// Submit : push request without waiting, use R of the returned context to get result
+func (c *TLine[Req, Rsp]) Submit(ctx context.Context, req Req) (*TAsyncCtx[Req, Rsp], error) {
+	var enqAt time.Time
+	ctx, enqAt = pipe.ObserveEnqueue(c.observer, ctx, c.at)
+	var proc = newTAsyncCtx[Req, Rsp](ctx, req)
+	proc.enqAt = enqAt
+	var err = pipe.ConvertQueueErr(c.q.Push(proc))
+	if err != nil {
+		pipe.ObserveReject(c.observer, ctx, c.at, err)
+		return nil, err
+	}
+	return proc, nil
+}
+
+// Canceled : count of calls skipped because caller context is done before running.
+// Caller context is passed to the call function, so its deadline is visible in the function.
+func (c *TLine[Req, Rsp]) Canceled() uint64 {
+	return c.canceled.Load()
+}
+
+// Run : run queue msg handler
+func (c *TLine[Req, Rsp]) Run() {
+	c.startOnce.Do(func() {
+		c.wg.Add(1)
+		go c.popLoop()
+	})
+}
+
+// Stop : stop
+func (c *TLine[Req, Rsp]) Stop() {
+	c.stopOnce.Do(func() {
+		c.q.Close()
+	})
+}
+
+// pop call loop
+func (c *TLine[Req, Rsp]) popLoop() {
+	var (
+		err   error
+		ac    *TAsyncCtx[Req, Rsp]
+		r     Rsp
+		ctx   context.Context
+		deqAt time.Time
+	)
+
+	defer c.wg.Done()
+	for {
+		ac, err = c.q.Pop()
+		if err != nil {
+			ulog.Debug("quit.in.tline.handler",
+				zap.String("name", c.name),
+				zap.Error(err))
+			return
+		}
+
+		ctx, deqAt = pipe.ObserveDequeue(c.observer, ac.ctx, c.at, ac.enqAt)
+		if err = ac.ctx.Err(); err != nil {
+			//caller gave up, skip it
+			c.canceled.Add(1)
+			pipe.ObserveDone(c.observer, ctx, c.at, deqAt, err)
+			var zero Rsp
+			ac.SetR(zero, err)
+			continue
+		}
+		r, err = safeTCall(c.call, ctx, ac.req)
+		pipe.ObserveDone(c.observer, ctx, c.at, deqAt, err)
+		ac.SetR(r, err)
+	}
+}
@@ -0,0 +1,80 @@
+package line
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/pinealctx/neptune/errorx"
+)
+
+func TestTLine_Order(t *testing.T) {
+	var (
+		wg  sync.WaitGroup
+		seq []int
+	)
+	var l = NewTLine(&wg, func(_ context.Context, req int) (string, error) {
+		// only one go routine handles requests, no lock needed
+		seq = append(seq, req)
+		if req < 0 {
+			return "", errors.New("negative")
+		}
+		return string(rune('a' + req)), nil
+	}, WithQSize(128), WithName("test-tline"))
+	l.Run()
+
+	var procs = make([]*TAsyncCtx[int, string], 0, 26)
+	for i := 0; i < 26; i++ {
+		var proc, err = l.Submit(context.Background(), i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		procs = append(procs, proc)
+	}
+	for i, proc := range procs {
+		var r, err = proc.R()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r != string(rune('a'+i)) {
+			t.Errorf("expected %c, got %s", 'a'+i, r)
+		}
+	}
+
+	var _, err = l.AsyncCall(context.Background(), -1)
+	if err == nil {
+		t.Error("expected error")
+	}
+
+	l.Stop()
+	wg.Wait()
+	for i := 0; i < 26; i++ {
+		if seq[i] != i {
+			t.Fatalf("out of order at %d: %d", i, seq[i])
+		}
+	}
+
+	_, err = l.AsyncCall(context.Background(), 1)
+	if err == nil {
+		t.Error("expected error after stop")
+	}
+}
+
+func TestTLine_Panic(t *testing.T) {
+	var wg sync.WaitGroup
+	var l = NewTLine(&wg, func(_ context.Context, _ *int) (int, error) {
+		panic("boom")
+	})
+	l.Run()
+	defer l.Stop()
+
+	var r, err = l.AsyncCall(context.Background(), nil)
+	var pe *errorx.PanicError
+	if !errorx.As(err, &pe) {
+		t.Fatalf("expected panic error, got %v", err)
+	}
+	if r != 0 {
+		t.Errorf("expected zero result, got %d", r)
+	}
+}
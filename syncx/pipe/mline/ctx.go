@@ -1,6 +1,11 @@
 package mline
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"github.com/pinealctx/neptune/errorx"
+)
 
 // CallFn : call function，回调函数
 // Input:
@@ -51,6 +56,8 @@ type AsyncCtx struct {
 	param any
 	//return chan
 	rChan chan AsyncR
+	//enqueue time, only set when observed
+	enqAt time.Time
 }
 
 // newAsyncCtx : new async call context
@@ -75,6 +82,12 @@ func (m *AsyncCtx) SetR(r any, err error) {
 	}
 }
 
+// safeCall : call function, a panic in call function is returned as error
+func (m *AsyncCtx) safeCall(ctx context.Context, sIndex int, param any) (r any, err error) {
+	defer errorx.Recover(&err)
+	return m.call(ctx, sIndex, param)
+}
+
 // R : get response with wait
 func (m *AsyncCtx) R() (any, error) {
 	select {
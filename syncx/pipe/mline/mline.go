@@ -3,6 +3,8 @@ package mline
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -28,6 +30,14 @@ type MultiLine struct {
 	exitChan chan struct{}
 	//stop once
 	stopOnce sync.Once
+
+	//count of calls skipped because context is done before running
+	canceled atomic.Uint64
+
+	//observer, nil if not set
+	observer pipe.Observer
+	//observed point name
+	name string
 }
 
 // NewMultiLine : new multi-queue group
@@ -35,7 +45,9 @@ func NewMultiLine(opts ...pipe.Option) *MultiLine {
 	//option
 	var slotSize, qSize = pipe.GetOption(opts...)
 	//new shunt then init
-	return newMux(slotSize, qSize)
+	var c = newMux(slotSize, qSize)
+	c.observer, c.name = pipe.GetObserver(opts...)
+	return c
 }
 
 // newMux : new cycle with size
@@ -83,6 +95,12 @@ func (c *MultiLine) AsyncCall(ctx context.Context, callCtx *CallCtx) (any, error
 	return proc.R()
 }
 
+// Canceled : count of calls skipped because caller context is done before running.
+// Caller context is passed to the call function, so its deadline is visible in the function.
+func (c *MultiLine) Canceled() uint64 {
+	return c.canceled.Load()
+}
+
 // Run : run all queue msg handler
 func (c *MultiLine) Run() {
 	for i := 0; i < c.slotSize; i++ {
@@ -108,19 +126,29 @@ func (c *MultiLine) WaitStop(ctx context.Context) error {
 // addCallCtx : add call context
 func (c *MultiLine) addCallCtx(ctx context.Context, callCtx *CallCtx) (*AsyncCtx, error) {
 	var slotIndex = pipe.NormalizeSlotIndex(callCtx.hashIndex, c.slotSize)
+	var at = pipe.Point{Name: c.name, Slot: slotIndex}
+	var enqAt time.Time
+	ctx, enqAt = pipe.ObserveEnqueue(c.observer, ctx, at)
 	var proc = newAsyncCtx(ctx, callCtx.call, callCtx.param)
+	proc.enqAt = enqAt
 	var err = pipe.ConvertQueueErr(c.qs[slotIndex].Push(proc))
+	if err != nil {
+		pipe.ObserveReject(c.observer, ctx, at, err)
+	}
 	return proc, err
 }
 
 // pop msg loop
 func (c *MultiLine) popLoop(index int) {
 	var (
-		err error
-		ac  *AsyncCtx
-		r   any
+		err   error
+		ac    *AsyncCtx
+		r     any
+		ctx   context.Context
+		deqAt time.Time
 
 		mq = c.qs[index]
+		at = pipe.Point{Name: c.name, Slot: index}
 	)
 
 	defer c.wg.Done()
@@ -133,7 +161,16 @@ func (c *MultiLine) popLoop(index int) {
 			return
 		}
 
-		r, err = ac.call(ac.ctx, index, ac.param)
+		ctx, deqAt = pipe.ObserveDequeue(c.observer, ac.ctx, at, ac.enqAt)
+		if err = ac.ctx.Err(); err != nil {
+			//caller gave up, skip it
+			c.canceled.Add(1)
+			pipe.ObserveDone(c.observer, ctx, at, deqAt, err)
+			ac.SetR(nil, err)
+			continue
+		}
+		r, err = ac.safeCall(ctx, index, ac.param)
+		pipe.ObserveDone(c.observer, ctx, at, deqAt, err)
 		if err != nil {
 			ac.SetR(nil, err)
 		} else {
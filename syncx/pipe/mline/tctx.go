@@ -0,0 +1,74 @@
+package mline
+
+import (
+	"context"
+	"time"
+
+	"github.com/pinealctx/neptune/errorx"
+)
+
+// TCallFn : typed call function，类型化的回调函数
+// Input:
+// ctx -- context
+// sIndex -- 表示在处理的go routine数组中对应的index，与CallFn中的sIndex含义相同。
+// req -- call param
+// Output:
+// rsp -- 回调函数调用后的返回值
+// err - 回调函数调用失败后返回error
+type TCallFn[Req, Rsp any] func(ctx context.Context, sIndex int, req Req) (rsp Rsp, err error)
+
+// TAsyncR : typed async call result.
+type TAsyncR[Rsp any] struct {
+	//result
+	r Rsp
+	//error
+	err error
+}
+
+// TAsyncCtx : typed async call context
+type TAsyncCtx[Req, Rsp any] struct {
+	//context
+	ctx context.Context
+	//call param
+	req Req
+	//return chan
+	rChan chan TAsyncR[Rsp]
+	//enqueue time, only set when observed
+	enqAt time.Time
+}
+
+// newTAsyncCtx : new typed async call context
+// ctx -- context
+// req -- async call param
+func newTAsyncCtx[Req, Rsp any](ctx context.Context, req Req) *TAsyncCtx[Req, Rsp] {
+	return &TAsyncCtx[Req, Rsp]{
+		ctx:   ctx,
+		req:   req,
+		rChan: make(chan TAsyncR[Rsp], 1),
+	}
+}
+
+// SetR : set return
+func (m *TAsyncCtx[Req, Rsp]) SetR(r Rsp, err error) {
+	m.rChan <- TAsyncR[Rsp]{
+		r:   r,
+		err: err,
+	}
+}
+
+// R : get response with wait
+func (m *TAsyncCtx[Req, Rsp]) R() (Rsp, error) {
+	select {
+	case <-m.ctx.Done():
+		var zero Rsp
+		return zero, m.ctx.Err()
+	case rc := <-m.rChan:
+		return rc.r, rc.err
+	}
+}
+
+// safeTCall : call typed function, a panic in call function is returned as error
+func safeTCall[Req, Rsp any](call TCallFn[Req, Rsp], ctx context.Context, sIndex int, req Req) (r Rsp, err error) {
+	defer errorx.Recover(&err)
+	return call(ctx, sIndex, req)
+}
@@ -0,0 +1,191 @@
+package mline
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pinealctx/neptune/syncx/pipe"
+	"github.com/pinealctx/neptune/syncx/pipe/q"
+	"github.com/pinealctx/neptune/ulog"
+)
+
+// TMultiLine : typed multi-queue handler.
+// 与MultiLine相同，相同hashIndex的请求总是在同一个go routine中按顺序处理，但请求与返回值都是确定的类型，不需要类型断言。
+type TMultiLine[Req, Rsp any] struct {
+	//slot size
+	slotSize int
+	//queue size in each slot
+	qSize int
+
+	//call function
+	call TCallFn[Req, Rsp]
+
+	//multi queues
+	qs []*q.Q[*TAsyncCtx[Req, Rsp]]
+
+	//wait group
+	wg *sync.WaitGroup
+
+	//go routine exit chan
+	exitChan chan struct{}
+	//stop once
+	stopOnce sync.Once
+
+	//count of calls skipped because context is done before running
+	canceled atomic.Uint64
+
+	//observer, nil if not set
+	observer pipe.Observer
+	//observed point name
+	name string
+}
+
+// NewTMultiLine : new typed multi-queue group
+// call -- call function to handle each request
+func NewTMultiLine[Req, Rsp any](call TCallFn[Req, Rsp], opts ...pipe.Option) *TMultiLine[Req, Rsp] {
+	var slotSize, qSize = pipe.GetOption(opts...)
+	var c = &TMultiLine[Req, Rsp]{
+		slotSize: slotSize,
+		qSize:    qSize,
+		call:     call,
+		wg:       &sync.WaitGroup{},
+		exitChan: make(chan struct{}, 1),
+	}
+	c.observer, c.name = pipe.GetObserver(opts...)
+	c.wg.Add(c.slotSize)
+
+	c.qs = make([]*q.Q[*TAsyncCtx[Req, Rsp]], c.slotSize)
+	for i := 0; i < c.slotSize; i++ {
+		c.qs[i] = q.NewQ[*TAsyncCtx[Req, Rsp]](c.qSize)
+	}
+	return c
+}
+
+// SlotSize : get slot size
+func (c *TMultiLine[Req, Rsp]) SlotSize() int {
+	return c.slotSize
+}
+
+// QSize : get queue size in each slot
+func (c *TMultiLine[Req, Rsp]) QSize() int {
+	return c.qSize
+}
+
+// IndexOf : get slot index, same as MultiLine.IndexOf
+func (c *TMultiLine[Req, Rsp]) IndexOf(i int) int {
+	return pipe.NormalizeSlotIndex(i, c.slotSize)
+}
+
+// AsyncCall : push request then wait result
+// ctx -- context.Context
+// hashIndex -- 与请求相关的散列值，参考NewCallCtx
+// req -- call param
+func (c *TMultiLine[Req, Rsp]) AsyncCall(ctx context.Context, hashIndex int, req Req) (Rsp, error) {
+	var proc, err = c.Submit(ctx, hashIndex, req)
+	if err != nil {
+		var zero Rsp
+		return zero, err
+	}
+	return proc.R()
+}
+
+// Submit : push request without waiting, use R of the returned context to get result
+func (c *TMultiLine[Req, Rsp]) Submit(ctx context.Context, hashIndex int, req Req) (*TAsyncCtx[Req, Rsp], error) {
+	var slotIndex = pipe.NormalizeSlotIndex(hashIndex, c.slotSize)
+	var at = pipe.Point{Name: c.name, Slot: slotIndex}
+	var enqAt time.Time
+	ctx, enqAt = pipe.ObserveEnqueue(c.observer, ctx, at)
+	var proc = newTAsyncCtx[Req, Rsp](ctx, req)
+	proc.enqAt = enqAt
+	var err = pipe.ConvertQueueErr(c.qs[slotIndex].Push(proc))
+	if err != nil {
+		pipe.ObserveReject(c.observer, ctx, at, err)
+		return nil, err
+	}
+	return proc, nil
+}
+
+// Canceled : count of calls skipped because caller context is done before running.
+// Caller context is passed to the call function, so its deadline is visible in the function.
+func (c *TMultiLine[Req, Rsp]) Canceled() uint64 {
+	return c.canceled.Load()
+}
+
+// Run : run all queue msg handler
+func (c *TMultiLine[Req, Rsp]) Run() {
+	for i := 0; i < c.slotSize; i++ {
+		go c.popLoop(i)
+	}
+}
+
+// Stop : stop
+func (c *TMultiLine[Req, Rsp]) Stop() {
+	c.stopOnce.Do(c.stop)
+}
+
+// WaitStop : wait stop
+func (c *TMultiLine[Req, Rsp]) WaitStop(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.exitChan:
+		return nil
+	}
+}
+
+// pop msg loop
+func (c *TMultiLine[Req, Rsp]) popLoop(index int) {
+	var (
+		err   error
+		ac    *TAsyncCtx[Req, Rsp]
+		r     Rsp
+		ctx   context.Context
+		deqAt time.Time
+
+		mq = c.qs[index]
+		at = pipe.Point{Name: c.name, Slot: index}
+	)
+
+	defer c.wg.Done()
+	for {
+		ac, err = mq.Pop()
+		if err != nil {
+			ulog.Debug("q.quit.in.typed.handler",
+				zap.Int("index", index),
+				zap.Error(err))
+			return
+		}
+
+		ctx, deqAt = pipe.ObserveDequeue(c.observer, ac.ctx, at, ac.enqAt)
+		if err = ac.ctx.Err(); err != nil {
+			//caller gave up, skip it
+			c.canceled.Add(1)
+			pipe.ObserveDone(c.observer, ctx, at, deqAt, err)
+			var zero Rsp
+			ac.SetR(zero, err)
+			continue
+		}
+		r, err = safeTCall(c.call, ctx, index, ac.req)
+		pipe.ObserveDone(c.observer, ctx, at, deqAt, err)
+		ac.SetR(r, err)
+	}
+}
+
+// stop work
+func (c *TMultiLine[Req, Rsp]) stop() {
+	for i := 0; i < c.slotSize; i++ {
+		c.qs[i].Close()
+	}
+	//a go routine to wait all children done then signal it.
+	go c.signalDone()
+}
+
+// signal all children go routine done
+func (c *TMultiLine[Req, Rsp]) signalDone() {
+	c.wg.Wait()
+	c.exitChan <- struct{}{}
+}
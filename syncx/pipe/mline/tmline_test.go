@@ -0,0 +1,108 @@
+package mline
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pinealctx/neptune/syncx/pipe"
+)
+
+type tReq struct {
+	key int
+	seq int
+}
+
+func TestTMultiLine_OrderPerKey(t *testing.T) {
+	var (
+		lock sync.Mutex
+		seen = make(map[int][]int)
+	)
+	var ml = NewTMultiLine(func(_ context.Context, sIndex int, req tReq) (int, error) {
+		lock.Lock()
+		seen[req.key] = append(seen[req.key], req.seq)
+		lock.Unlock()
+		return sIndex, nil
+	}, pipe.WithSlotSize(7), pipe.WithQSize(64))
+	ml.Run()
+
+	var wg sync.WaitGroup
+	for key := 0; key < 20; key++ {
+		wg.Add(1)
+		go func(key int) {
+			defer wg.Done()
+			for seq := 0; seq < 50; seq++ {
+				var sIndex, err = ml.AsyncCall(context.Background(), key, tReq{key: key, seq: seq})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if sIndex != ml.IndexOf(key) {
+					t.Errorf("key %d handled in slot %d, expected %d", key, sIndex, ml.IndexOf(key))
+					return
+				}
+			}
+		}(key)
+	}
+	wg.Wait()
+
+	for key, seqs := range seen {
+		for i, seq := range seqs {
+			if i != seq {
+				t.Fatalf("key %d out of order at %d: %d", key, i, seq)
+			}
+		}
+	}
+
+	ml.Stop()
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := ml.WaitStop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ml.AsyncCall(context.Background(), 1, tReq{}); err == nil {
+		t.Error("expected error after stop")
+	}
+}
+
+func TestTMultiLine_SkipCanceled(t *testing.T) {
+	var block = make(chan struct{})
+	var calls atomic.Int32
+	var ml = NewTMultiLine(func(ctx context.Context, _ int, req int) (int, error) {
+		calls.Add(1)
+		if req == 0 {
+			<-block
+		}
+		if dl, ok := ctx.Deadline(); ok {
+			return int(time.Until(dl) / time.Second), nil
+		}
+		return req, nil
+	}, pipe.WithSlotSize(1), pipe.WithQSize(8))
+	ml.Run()
+	defer ml.Stop()
+
+	go func() {
+		_, _ = ml.AsyncCall(context.Background(), 1, 0)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := ml.AsyncCall(ctx, 1, 1); err == nil {
+		t.Error("expected deadline error")
+	}
+	close(block)
+
+	// deadline of caller is visible in call function
+	var dlCtx, dlCancel = context.WithTimeout(context.Background(), time.Hour)
+	defer dlCancel()
+	var r, err = ml.AsyncCall(dlCtx, 1, 2)
+	if err != nil || r < 3500 {
+		t.Errorf("unexpected result %d %v", r, err)
+	}
+	if calls.Load() != 2 || ml.Canceled() != 1 {
+		t.Errorf("expected 2 calls and 1 canceled, got %d %d", calls.Load(), ml.Canceled())
+	}
+}
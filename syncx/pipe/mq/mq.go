@@ -6,6 +6,8 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	"github.com/pinealctx/neptune/syncx/pipe"
 )
 
 var (
@@ -25,6 +27,8 @@ var (
 type _Option struct {
 	ctrlMaxNum int
 	reqMaxNum  int
+	name       string
+	observer   pipe.Observer
 }
 
 // Option : option function
@@ -46,6 +50,21 @@ func WithQReqSize(num int) Option {
 	}
 }
 
+// WithName setup name, it's used as point name of observer
+func WithName(name string) Option {
+	return func(o *_Option) {
+		o.name = name
+	}
+}
+
+// WithObserver setup observer.
+// MQ does not know how an item is handled, so OnDone is called right after OnDequeue with zero duration.
+func WithObserver(observer pipe.Observer) Option {
+	return func(o *_Option) {
+		o.observer = observer
+	}
+}
+
 // MQ actor queue structure define
 type MQ struct {
 	//control queue list
@@ -72,6 +91,21 @@ type MQ struct {
 	lock sync.Mutex
 	//queue condition
 	cond sync.Cond
+
+	//observer, nil if not set
+	observer pipe.Observer
+	//observed point
+	at pipe.Point
+}
+
+// observedT : observed queue item
+type observedT struct {
+	//the item
+	v any
+	//context returned by observer
+	ctx context.Context
+	//enqueue time
+	enqAt time.Time
 }
 
 // NewMQ new queue
@@ -82,7 +116,9 @@ func NewMQ(options ...Option) *MQ {
 		stopChan:  make(chan struct{}),
 		clearChan: make(chan struct{}),
 	}
-	var option = &_Option{}
+	var option = &_Option{
+		name: "not-set",
+	}
 	for _, opt := range options {
 		opt(option)
 	}
@@ -92,6 +128,8 @@ func NewMQ(options ...Option) *MQ {
 	if option.reqMaxNum > 0 {
 		actorQ.reqMaxNum = option.reqMaxNum
 	}
+	actorQ.observer = option.observer
+	actorQ.at = pipe.Point{Name: option.name}
 	actorQ.cond.L = &actorQ.lock
 	return actorQ
 }
@@ -99,19 +137,16 @@ func NewMQ(options ...Option) *MQ {
 // AddCtrlAnyway dd control request to the control queue end place anyway
 // if queue full, sleep then try
 func (a *MQ) AddCtrlAnyway(cmd any, ts time.Duration) error {
-	var err error
-	for {
-		err = a.AddCtrl(cmd)
-		if err == ErrCtrlQFull {
-			time.Sleep(ts)
-		} else {
-			return err
-		}
-	}
+	return a.put(cmd, anyway(a.addCtrl, ErrCtrlQFull, ts))
 }
 
 // AddCtrl add control request to the control queue end place.
 func (a *MQ) AddCtrl(cmd any) error {
+	return a.put(cmd, a.addCtrl)
+}
+
+// addCtrl : AddCtrl without observing
+func (a *MQ) addCtrl(cmd any) error {
 	a.lock.Lock()
 	defer a.lock.Unlock()
 	if a.closed {
@@ -129,6 +164,11 @@ func (a *MQ) AddCtrl(cmd any) error {
 
 // AddPriorCtrl add control request to the control queue first place.
 func (a *MQ) AddPriorCtrl(cmd any) error {
+	return a.put(cmd, a.addPriorCtrl)
+}
+
+// addPriorCtrl : AddPriorCtrl without observing
+func (a *MQ) addPriorCtrl(cmd any) error {
 	a.lock.Lock()
 	defer a.lock.Unlock()
 	if a.closed {
@@ -142,19 +182,16 @@ func (a *MQ) AddPriorCtrl(cmd any) error {
 // AddReqAnyway add normal request to the normal queue end place anyway
 // if queue full, sleep then try
 func (a *MQ) AddReqAnyway(req any, ts time.Duration) error {
-	var err error
-	for {
-		err = a.AddReq(req)
-		if err == ErrReqQFull {
-			time.Sleep(ts)
-		} else {
-			return err
-		}
-	}
+	return a.put(req, anyway(a.addReq, ErrReqQFull, ts))
 }
 
 // AddReq add normal request to the normal queue end place.
 func (a *MQ) AddReq(req any) error {
+	return a.put(req, a.addReq)
+}
+
+// addReq : AddReq without observing
+func (a *MQ) addReq(req any) error {
 	a.lock.Lock()
 	defer a.lock.Unlock()
 	if a.closed {
@@ -172,6 +209,11 @@ func (a *MQ) AddReq(req any) error {
 
 // AddPriorReq add normal request to the normal queue first place.
 func (a *MQ) AddPriorReq(req any) error {
+	return a.put(req, a.addPriorReq)
+}
+
+// addPriorReq : AddPriorReq without observing
+func (a *MQ) addPriorReq(req any) error {
 	a.lock.Lock()
 	defer a.lock.Unlock()
 	if a.closed {
@@ -184,6 +226,58 @@ func (a *MQ) AddPriorReq(req any) error {
 
 // Pop consume an item, if list is empty, it's been blocked
 func (a *MQ) Pop() (any, error) {
+	return a.take(a.pop())
+}
+
+// PopAnyway consume an item like Pop, but it can consume even the queue is closed.
+func (a *MQ) PopAnyway() (any, error) {
+	return a.take(a.popAnyway())
+}
+
+// put : put item by add function, wrap it if observed
+func (a *MQ) put(v any, add func(v any) error) error {
+	if a.observer == nil {
+		return add(v)
+	}
+	var ob = &observedT{v: v}
+	ob.ctx, ob.enqAt = pipe.ObserveEnqueue(a.observer, context.Background(), a.at)
+	var err = add(ob)
+	if err != nil {
+		pipe.ObserveReject(a.observer, ob.ctx, a.at, err)
+	}
+	return err
+}
+
+// anyway : wrap add function, retry it after sleeping if queue is full.
+// The retries are inside one put, so the item is observed once.
+func anyway(add func(v any) error, full error, ts time.Duration) func(v any) error {
+	return func(v any) error {
+		for {
+			var err = add(v)
+			if err != full {
+				return err
+			}
+			time.Sleep(ts)
+		}
+	}
+}
+
+// take : unwrap observed item
+func (a *MQ) take(v any, err error) (any, error) {
+	if err != nil || a.observer == nil {
+		return v, err
+	}
+	var ob, ok = v.(*observedT)
+	if !ok {
+		return v, nil
+	}
+	var ctx, deqAt = pipe.ObserveDequeue(a.observer, ob.ctx, a.at, ob.enqAt)
+	pipe.ObserveDone(a.observer, ctx, a.at, deqAt, nil)
+	return ob.v, nil
+}
+
+// pop : pop without observing
+func (a *MQ) pop() (any, error) {
 	a.lock.Lock()
 	defer a.lock.Unlock()
 	for a.ctrlList.Len() == 0 && a.reqList.Len() == 0 {
@@ -209,8 +303,8 @@ func (a *MQ) Pop() (any, error) {
 	return nil, ErrSync
 }
 
-// PopAnyway consume an item like Pop, but it can consume even the queue is closed.
-func (a *MQ) PopAnyway() (any, error) {
+// popAnyway : popAnyway without observing
+func (a *MQ) popAnyway() (any, error) {
 	a.lock.Lock()
 	defer a.lock.Unlock()
 	for a.ctrlList.Len() == 0 && a.reqList.Len() == 0 {
@@ -7,6 +7,8 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/pinealctx/neptune/syncx/pipe"
 )
 
 type _Cond struct {
@@ -284,3 +286,57 @@ func testActorNotify(q *MQ) {
 
 	waitGrp.Wait()
 }
+
+func TestActorQ_Observer(t *testing.T) {
+	var stats = pipe.NewStatsObserver()
+	var q = NewMQ(WithQReqSize(2), WithName("test-mq"), WithObserver(stats))
+	for i := 0; i < 3; i++ {
+		_ = q.AddReq(i)
+	}
+	_ = q.AddCtrl("ctrl")
+
+	for _, expected := range []any{"ctrl", 0, 1} {
+		var v, err = q.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != expected {
+			t.Errorf("expected %v, got %v", expected, v)
+		}
+	}
+
+	var s = stats.Stats()
+	if s.Enqueued != 4 || s.Dequeued != 4 || s.Failed != 1 || s.Depth != 0 || s.MaxDepth != 3 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestActorQ_ObserverAnyway(t *testing.T) {
+	var stats = pipe.NewStatsObserver()
+	var q = NewMQ(WithQReqSize(1), WithName("test-mq"), WithObserver(stats))
+	_ = q.AddReq(0)
+
+	var done = make(chan error, 1)
+	go func() {
+		done <- q.AddReqAnyway(1, time.Millisecond)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	for _, expected := range []any{0, 1} {
+		var v, err = q.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != expected {
+			t.Errorf("expected %v, got %v", expected, v)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	//retries of a full queue are counted once
+	var s = stats.Stats()
+	if s.Enqueued != 2 || s.Dequeued != 2 || s.Failed != 0 || s.Depth != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
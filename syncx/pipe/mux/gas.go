@@ -2,6 +2,7 @@ package mux
 
 import (
 	"context"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -36,36 +37,52 @@ type R struct {
 
 // AsyncC : async cell
 type AsyncC struct {
-	//context
+	//context passed to handler, it may be replaced by observer in worker go routine
 	ctx context.Context
+	//context of caller, for waiting result
+	caller context.Context
 	//opcode
 	op OpCode
 	//return value, actually it's chan for async call
 	rChan chan R
+
+	//enqueue time, only set when observed
+	enqAt time.Time
+	//called with result error before result is set, only accessed in worker go routine
+	onDone func(err error)
+	//result is set or not, only accessed in worker go routine
+	set bool
 }
 
 // NewAsync : new async call
 func NewAsync(ctx context.Context, op OpCode) *AsyncC {
 	return &AsyncC{
-		ctx:   ctx,
-		op:    op,
-		rChan: make(chan R, 1),
+		ctx:    ctx,
+		caller: ctx,
+		op:     op,
+		rChan:  make(chan R, 1),
 	}
 }
 
 // SetR : set op result
+// The done hook is called before the result is sent, so the caller is released after it.
 func (a *AsyncC) SetR(r any, err error) {
+	if fn := a.onDone; fn != nil {
+		a.onDone = nil
+		fn(err)
+	}
 	a.rChan <- R{
 		r:   r,
 		err: err,
 	}
+	a.set = true
 }
 
 // R : get result
 func (a *AsyncC) R() (any, error) {
 	select {
-	case <-a.ctx.Done():
-		return nil, a.ctx.Err()
+	case <-a.caller.Done():
+		return nil, a.caller.Err()
 	case re := <-a.rChan:
 		return re.r, re.err
 	}
@@ -273,3 +290,10 @@ func NewMixUpsertThenRenewInCache(p UpdateDataFn, k any, data any) OpCode {
 func (o *OpMixUpsertThenRenewInCache) GetK() any {
 	return o.k
 }
+
+// opBarrier : a no-op command, its result is set after all commands queued before it are handled
+type opBarrier struct{}
+
+func (o *opBarrier) GetK() any {
+	return nil
+}
@@ -1,5 +1,11 @@
 package mux
 
+import (
+	"time"
+
+	"github.com/pinealctx/neptune/syncx/pipe"
+)
+
 const (
 	//DefaultMuxSize slot size
 	//素数
@@ -14,6 +20,18 @@ type _Option struct {
 	muxSize int
 	//queue deep size
 	deepSize int
+	//key router
+	router Router
+
+	//write behind
+	flushFn       BatchFlushFn
+	flushBatch    int
+	maxDirty      int
+	flushInterval time.Duration
+
+	//observer
+	name     string
+	observer pipe.Observer
 }
 
 // Option mux option function
@@ -32,3 +50,35 @@ func WithDeep(deepSize int) Option {
 		o.deepSize = deepSize
 	}
 }
+
+// WithRouter setup key router, default is ModRouter.
+// Use JumpRouter or RingRouter if the worker group will be resized.
+func WithRouter(router Router) Option {
+	return func(o *_Option) {
+		o.router = router
+	}
+}
+
+// WithWriteBehind enable write behind mode of all workers, see Worker.EnableWriteBehind
+func WithWriteBehind(flushFn BatchFlushFn, batchSize int, maxDirty int, interval time.Duration) Option {
+	return func(o *_Option) {
+		o.flushFn = flushFn
+		o.flushBatch = batchSize
+		o.maxDirty = maxDirty
+		o.flushInterval = interval
+	}
+}
+
+// WithName setup name, it's used as point name of observer
+func WithName(name string) Option {
+	return func(o *_Option) {
+		o.name = name
+	}
+}
+
+// WithObserver setup observer of all workers, worker index is used as point slot, see Worker.Observe
+func WithObserver(observer pipe.Observer) Option {
+	return func(o *_Option) {
+		o.observer = observer
+	}
+}
@@ -0,0 +1,145 @@
+package mux
+
+import (
+	"hash/maphash"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Router : route a hashed key to a worker index in [0, n)
+type Router interface {
+	//Route : return worker index of hash when there are n workers
+	Route(hash uint64, n int) int
+}
+
+// Hasher : hash a plain comparable key, the result must be stable in the process.
+type Hasher[K comparable] func(k K) uint64
+
+// MapHasher : a Hasher based on hash/maphash, works for any comparable key.
+// The hash value is different between processes, it is only used to route keys in memory.
+func MapHasher[K comparable]() Hasher[K] {
+	var seed = maphash.MakeSeed()
+	return func(k K) uint64 {
+		return maphash.Comparable(seed, k)
+	}
+}
+
+// ModRouter : route by hash modulo worker count, it's the default router.
+// Almost all keys move to another worker when worker count changes.
+type ModRouter struct{}
+
+// Route : hash % n
+func (ModRouter) Route(hash uint64, n int) int {
+	return int(hash % uint64(n))
+}
+
+// JumpRouter : route by jump consistent hash(https://arxiv.org/abs/1406.2294).
+// When worker count grows from n to n+1, only about 1/(n+1) keys move to the new worker,
+// and no key moves between old workers.
+type JumpRouter struct{}
+
+// Route : jump consistent hash
+func (JumpRouter) Route(hash uint64, n int) int {
+	return JumpHash(hash, n)
+}
+
+// JumpHash : jump consistent hash of key in n buckets
+func JumpHash(key uint64, n int) int {
+	var b, j int64 = -1, 0
+	for j < int64(n) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// ringT : hash ring for a fixed worker count
+type ringT struct {
+	n      int
+	points []uint64
+	owners []int
+}
+
+// RingRouter : route by hash ring with virtual nodes.
+// Keys move only from/to the added/removed workers when worker count changes.
+type RingRouter struct {
+	replicas int
+	ring     atomic.Pointer[ringT]
+}
+
+// NewRingRouter : new hash ring router, replicas is the virtual node count of each worker
+func NewRingRouter(replicas int) *RingRouter {
+	if replicas <= 0 {
+		replicas = 64
+	}
+	return &RingRouter{replicas: replicas}
+}
+
+// Route : find the first virtual node clockwise from hash
+func (r *RingRouter) Route(hash uint64, n int) int {
+	var ring = r.ring.Load()
+	if ring == nil || ring.n != n {
+		ring = r.build(n)
+		r.ring.Store(ring)
+	}
+	//mix hash in case of small or sequential integer keys
+	hash = mix64(hash)
+	var i = sort.Search(len(ring.points), func(i int) bool {
+		return ring.points[i] >= hash
+	})
+	if i == len(ring.points) {
+		i = 0
+	}
+	return ring.owners[i]
+}
+
+// build ring for n workers
+func (r *RingRouter) build(n int) *ringT {
+	var ring = &ringT{
+		n:      n,
+		points: make([]uint64, 0, n*r.replicas),
+	}
+	var owner = make(map[uint64]int, n*r.replicas)
+	for i := 0; i < n; i++ {
+		var prefix = strconv.Itoa(i) + "#"
+		for j := 0; j < r.replicas; j++ {
+			var p = xxhash.Sum64String(prefix + strconv.Itoa(j))
+			if _, ok := owner[p]; ok {
+				continue
+			}
+			owner[p] = i
+			ring.points = append(ring.points, p)
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool {
+		return ring.points[i] < ring.points[j]
+	})
+	ring.owners = make([]int, len(ring.points))
+	for i, p := range ring.points {
+		ring.owners[i] = owner[p]
+	}
+	return ring
+}
+
+// mix64 : splitmix64 finalizer
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// hashedKey : hash of Hashed2Int key, same as the original modulo routing for non-negative hash
+func hashedKey(k Hashed2Int) uint64 {
+	var hashNum = k.HashedInt()
+	if hashNum < 0 {
+		hashNum = -hashNum
+	}
+	return uint64(hashNum)
+}
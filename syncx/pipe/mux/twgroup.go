@@ -0,0 +1,91 @@
+package mux
+
+import (
+	"context"
+)
+
+// TWorkerGrp : worker group for plain comparable keys.
+// Keys are hashed by Hasher instead of implementing Hashed2Int, then routed by the Router of the group.
+type TWorkerGrp[K comparable] struct {
+	*WorkerGrp
+	hasher Hasher[K]
+}
+
+// NewTWorkGrp : new work group for comparable keys
+// hasher -- key hasher, MapHasher is used if nil
+func NewTWorkGrp[K comparable](cg CacheGen, hasher Hasher[K], opts ...Option) *TWorkerGrp[K] {
+	if hasher == nil {
+		hasher = MapHasher[K]()
+	}
+	return &TWorkerGrp[K]{
+		WorkerGrp: newWorkGrp(cg, func(k any) uint64 {
+			// nolint : forcetypeassert // I know the type is exactly here
+			return hasher(k.(K))
+		}, opts...),
+		hasher: hasher,
+	}
+}
+
+// NewTWorkGrpWithMapCache : new work group for comparable keys bind with map cache.
+func NewTWorkGrpWithMapCache[K comparable](hasher Hasher[K], opts ...Option) *TWorkerGrp[K] {
+	return NewTWorkGrp[K](NewFacadeMap, hasher, opts...)
+}
+
+// NewTWorkGrpWithLRU : new work group for comparable keys bind with lru cache.
+func NewTWorkGrpWithLRU[K comparable](lruCap int64, hasher Hasher[K], opts ...Option) *TWorkerGrp[K] {
+	return NewTWorkGrp[K](func() CacheFacade {
+		return NewFacadeLRU(lruCap)
+	}, hasher, opts...)
+}
+
+// DoGet : get from cache first if not load from db
+func (w *TWorkerGrp[K]) DoGet(ctx context.Context, loadFn RenewDataFn, k K) (any, error) {
+	return w.get(ctx, w.hasher(k), loadFn, k)
+}
+
+// DoAdd : add item
+func (w *TWorkerGrp[K]) DoAdd(ctx context.Context, addFn RenewDataFn, k K, data any) (any, error) {
+	return w.call(ctx, w.hasher(k), NewAdd(addFn, k, data))
+}
+
+// DoUpdate : update item
+func (w *TWorkerGrp[K]) DoUpdate(ctx context.Context,
+	loadFn RenewDataFn, updFn UpdateDataFn, k K, data any) (any, error) {
+	return w.call(ctx, w.hasher(k), NewUpdate(loadFn, updFn, k, data))
+}
+
+// DoDelete : delete item
+func (w *TWorkerGrp[K]) DoDelete(ctx context.Context, deleteFn DeleteFn, k K) (any, error) {
+	return w.call(ctx, w.hasher(k), NewDelete(deleteFn, k))
+}
+
+// DoUpdOrAddIfNull : same as WorkerGrp.DoUpdOrAddIfNull
+func (w *TWorkerGrp[K]) DoUpdOrAddIfNull(ctx context.Context,
+	loadFn RenewDataFn, updFn UpdateDataFn, addFn RenewDataFn, isNotFoundFn IsNotFoundFn,
+	k K, data any) (any, error) {
+	return w.call(ctx, w.hasher(k), NewMixUpdOrAddIfNull(loadFn, updFn, addFn, isNotFoundFn, k, data))
+}
+
+// DoUpsertThenLoad : same as WorkerGrp.DoUpsertThenLoad
+func (w *TWorkerGrp[K]) DoUpsertThenLoad(ctx context.Context,
+	upsertFn UpdateDataFn, loadFn RenewDataFn, k K, data any) (any, error) {
+	return w.call(ctx, w.hasher(k), NewMixUpsertThenLoad(upsertFn, loadFn, k, data))
+}
+
+// DoUpsertThenRenewInCache : same as WorkerGrp.DoUpsertThenRenewInCache
+func (w *TWorkerGrp[K]) DoUpsertThenRenewInCache(ctx context.Context,
+	upsertFn UpdateDataFn, k K, data any) (any, error) {
+	return w.call(ctx, w.hasher(k), NewMixUpsertThenRenewInCache(upsertFn, k, data))
+}
+
+// DoUpdateBehind : same as WorkerGrp.DoUpdateBehind
+func (w *TWorkerGrp[K]) DoUpdateBehind(ctx context.Context,
+	loadFn RenewDataFn, mergeFn UpdateDataFn, k K, data any) (any, error) {
+	return w.callBehind(ctx, w.hasher(k), NewUpdateBehind(loadFn, mergeFn, k, data))
+}
+
+// DoSetBehind : same as WorkerGrp.DoSetBehind
+func (w *TWorkerGrp[K]) DoSetBehind(ctx context.Context, k K, v any) error {
+	var _, err = w.callBehind(ctx, w.hasher(k), NewSetBehind(k, v))
+	return err
+}
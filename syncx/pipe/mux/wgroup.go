@@ -2,12 +2,25 @@ package mux
 
 import (
 	"context"
+	"errors"
 	"sync"
+	"time"
+
+	"github.com/pinealctx/neptune/errorx"
+	"github.com/pinealctx/neptune/syncx/pipe"
 )
 
 // CacheGen : cache facade generator
 type CacheGen func() CacheFacade
 
+// KeyLister : a cache facade which can list its keys.
+// When worker group is resized, keys routed to other workers are purged from the cache of a worker,
+// for cache facade not implementing it, the whole cache is replaced by a new one.
+type KeyLister interface {
+	//Keys : return all keys in cache
+	Keys() []any
+}
+
 // WorkerGrp : worker group
 type WorkerGrp struct {
 	//mux size
@@ -18,6 +31,28 @@ type WorkerGrp struct {
 	//multi workers
 	ws []*Worker
 
+	//cache generator
+	cg CacheGen
+	//write behind config
+	flushFn       BatchFlushFn
+	flushBatch    int
+	maxDirty      int
+	flushInterval time.Duration
+	//observer
+	name     string
+	observer pipe.Observer
+	//key router
+	router Router
+	//hash a cached key, used to purge moved keys when resizing
+	keyHash func(k any) uint64
+
+	//route lock, ops hold read lock until queued, resizing holds write lock to change workers
+	routeLock sync.RWMutex
+	//serialize resizing and stopping
+	resizeLock sync.Mutex
+	//stopped
+	stopped bool
+
 	//wait group
 	wg *sync.WaitGroup
 
@@ -29,51 +64,43 @@ type WorkerGrp struct {
 
 // NewWorkGrp : new work group
 func NewWorkGrp(cg CacheGen, opts ...Option) *WorkerGrp {
-	var w = buildWorkGrp(opts...)
-	for i := 0; i < w.muxSize; i++ {
-		var ca = cg()
-		w.ws[i] = NewWorker(w.deepSize, w.wg, ca)
-	}
-	return w
+	return newWorkGrp(cg, func(k any) uint64 {
+		// nolint : forcetypeassert // I know the type is exactly here
+		return hashedKey(k.(Hashed2Int))
+	}, opts...)
 }
 
 // NewWorkGrpWithMapCache : new work group bind with map cache.
 func NewWorkGrpWithMapCache(opts ...Option) *WorkerGrp {
-	var w = buildWorkGrp(opts...)
-	for i := 0; i < w.muxSize; i++ {
-		w.ws[i] = NewWorker(w.deepSize, w.wg, NewFacadeMap())
-	}
-	return w
+	return NewWorkGrp(NewFacadeMap, opts...)
 }
 
 // NewWorkGrpWithLRU : new work group bind with lru cache.
 func NewWorkGrpWithLRU(lruCap int64, opts ...Option) *WorkerGrp {
-	var w = buildWorkGrp(opts...)
-	for i := 0; i < w.muxSize; i++ {
-		w.ws[i] = NewWorker(w.deepSize, w.wg, NewFacadeLRU(lruCap))
-	}
-	return w
+	return NewWorkGrp(func() CacheFacade {
+		return NewFacadeLRU(lruCap)
+	}, opts...)
 }
 
 // DoGet : get from cache first if not load from db
 func (w *WorkerGrp) DoGet(ctx context.Context, loadFn RenewDataFn, k Hashed2Int) (any, error) {
-	return w.ws[w.locHash(k)].DoGet(ctx, loadFn, k)
+	return w.get(ctx, hashedKey(k), loadFn, k)
 }
 
 // DoAdd : add item
 func (w *WorkerGrp) DoAdd(ctx context.Context, addFn RenewDataFn, k Hashed2Int, data any) (any, error) {
-	return w.ws[w.locHash(k)].DoAdd(ctx, addFn, k, data)
+	return w.call(ctx, hashedKey(k), NewAdd(addFn, k, data))
 }
 
 // DoUpdate : update item
 func (w *WorkerGrp) DoUpdate(ctx context.Context,
 	loadFn RenewDataFn, updFn UpdateDataFn, k Hashed2Int, data any) (any, error) {
-	return w.ws[w.locHash(k)].DoUpdate(ctx, loadFn, updFn, k, data)
+	return w.call(ctx, hashedKey(k), NewUpdate(loadFn, updFn, k, data))
 }
 
 // DoDelete : delete item
 func (w *WorkerGrp) DoDelete(ctx context.Context, deleteFn DeleteFn, k Hashed2Int) (any, error) {
-	return w.ws[w.locHash(k)].DoDelete(ctx, deleteFn, k)
+	return w.call(ctx, hashedKey(k), NewDelete(deleteFn, k))
 }
 
 // DoUpdOrAddIfNull :
@@ -83,7 +110,7 @@ func (w *WorkerGrp) DoDelete(ctx context.Context, deleteFn DeleteFn, k Hashed2In
 func (w *WorkerGrp) DoUpdOrAddIfNull(ctx context.Context,
 	loadFn RenewDataFn, updFn UpdateDataFn, addFn RenewDataFn, isNotFoundFn IsNotFoundFn,
 	k Hashed2Int, data any) (any, error) {
-	return w.ws[w.locHash(k)].DoUpdOrAddIfNull(ctx, loadFn, updFn, addFn, isNotFoundFn, k, data)
+	return w.call(ctx, hashedKey(k), NewMixUpdOrAddIfNull(loadFn, updFn, addFn, isNotFoundFn, k, data))
 }
 
 // DoUpsertThenLoad :
@@ -92,7 +119,7 @@ func (w *WorkerGrp) DoUpdOrAddIfNull(ctx context.Context,
 // 3. load cache if cache miss.
 func (w *WorkerGrp) DoUpsertThenLoad(ctx context.Context,
 	upsertFn UpdateDataFn, loadFn RenewDataFn, k Hashed2Int, data any) (any, error) {
-	return w.ws[w.locHash(k)].DoUpsertThenLoad(ctx, upsertFn, loadFn, k, data)
+	return w.call(ctx, hashedKey(k), NewMixUpsertThenLoad(upsertFn, loadFn, k, data))
 }
 
 // DoUpsertThenRenewInCache :
@@ -100,11 +127,43 @@ func (w *WorkerGrp) DoUpsertThenLoad(ctx context.Context,
 // 2. update cache if cache hit.
 func (w *WorkerGrp) DoUpsertThenRenewInCache(ctx context.Context,
 	upsertFn UpdateDataFn, k Hashed2Int, data any) (any, error) {
-	return w.ws[w.locHash(k)].DoUpsertThenRenewInCache(ctx, upsertFn, k, data)
+	return w.call(ctx, hashedKey(k), NewMixUpsertThenRenewInCache(upsertFn, k, data))
+}
+
+// DoUpdateBehind : update item in cache, the store is written later in batch, see Worker.DoUpdateBehind
+func (w *WorkerGrp) DoUpdateBehind(ctx context.Context,
+	loadFn RenewDataFn, mergeFn UpdateDataFn, k Hashed2Int, data any) (any, error) {
+	return w.callBehind(ctx, hashedKey(k), NewUpdateBehind(loadFn, mergeFn, k, data))
+}
+
+// DoSetBehind : set item in cache, the store is written later in batch, see Worker.DoSetBehind
+func (w *WorkerGrp) DoSetBehind(ctx context.Context, k Hashed2Int, v any) error {
+	var _, err = w.callBehind(ctx, hashedKey(k), NewSetBehind(k, v))
+	return err
+}
+
+// Flush : write all dirty items of all workers to store
+func (w *WorkerGrp) Flush(ctx context.Context) error {
+	w.routeLock.RLock()
+	defer w.routeLock.RUnlock()
+	return w.flush(ctx)
+}
+
+// DirtyCount : count of dirty items in all workers
+func (w *WorkerGrp) DirtyCount() int {
+	w.routeLock.RLock()
+	defer w.routeLock.RUnlock()
+	var n int
+	for _, wk := range w.ws {
+		n += wk.DirtyCount()
+	}
+	return n
 }
 
 // MuxSize : get mux size
 func (w *WorkerGrp) MuxSize() int {
+	w.routeLock.RLock()
+	defer w.routeLock.RUnlock()
 	return w.muxSize
 }
 
@@ -115,11 +174,72 @@ func (w *WorkerGrp) DeepSize() int {
 
 // Start : start all work go routine
 func (w *WorkerGrp) Start() {
+	w.resizeLock.Lock()
+	defer w.resizeLock.Unlock()
 	for i := 0; i < w.muxSize; i++ {
 		w.ws[i].Start()
 	}
 }
 
+// Resize : change worker count at runtime, the group must be started.
+// Resizing blocks new ops and waits all queued ops done until the new workers are ready,
+// so ops of a moved key are never handled by old and new worker at the same time.
+// Ops only hold route lock while being queued, they don't block resizing when waiting results.
+// If ctx is done before queued ops are drained, nothing changes and ctx error is returned.
+// Keys routed to other workers are purged from the cache of remaining workers.
+// NOTE: calling group methods in op callbacks would dead lock with resizing.
+func (w *WorkerGrp) Resize(ctx context.Context, n int) error {
+	if n <= 0 {
+		return errors.New("mux.invalid.size")
+	}
+	w.resizeLock.Lock()
+	defer w.resizeLock.Unlock()
+
+	if w.stopped {
+		return ErrClosed
+	}
+	if n == w.muxSize {
+		return nil
+	}
+	var err = w.lockRoute(ctx)
+	if err != nil {
+		return err
+	}
+	defer w.routeLock.Unlock()
+
+	//callers may give up waiting by context, make sure their queued ops are handled too
+	for _, wk := range w.ws {
+		err = wk.barrier(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	//dirty items of moved keys must be in store before new worker loads them
+	err = w.flush(ctx)
+	if err != nil {
+		return err
+	}
+
+	var old = w.ws
+	var ws = make([]*Worker, n)
+	copy(ws, old)
+	for i := len(old); i < n; i++ {
+		w.wg.Add(1)
+		ws[i] = w.newWorker(i)
+		ws[i].Start()
+	}
+	for i := n; i < len(old); i++ {
+		old[i].Stop()
+	}
+	w.ws, w.muxSize = ws, n
+
+	//no op is running now, it's safe to touch cache of workers directly
+	for i := 0; i < n && i < len(old); i++ {
+		w.purge(i)
+	}
+	return nil
+}
+
 // Stop : stop
 func (w *WorkerGrp) Stop() {
 	w.stopOnce.Do(w.stop)
@@ -135,11 +255,112 @@ func (w *WorkerGrp) WaitStop(ctx context.Context) error {
 	}
 }
 
+// flush all workers, route lock must be held
+func (w *WorkerGrp) flush(ctx context.Context) error {
+	if w.flushFn == nil {
+		return nil
+	}
+	var errs errorx.Multi
+	for _, wk := range w.ws {
+		errs.Append(wk.Flush(ctx))
+	}
+	return errs.ErrorOrNil()
+}
+
+// new worker with group config
+func (w *WorkerGrp) newWorker(index int) *Worker {
+	var wk = NewWorker(w.deepSize, w.wg, w.cg())
+	if w.flushFn != nil {
+		wk.EnableWriteBehind(w.flushFn, w.flushBatch, w.maxDirty, w.flushInterval)
+	}
+	if w.observer != nil {
+		wk.Observe(w.observer, pipe.Point{Name: w.name, Slot: index})
+	}
+	return wk
+}
+
+// call : put op into the routed worker under route lock, then wait result after the lock released.
+// Resizing holds the write lock and drains queued ops by barriers before changing route,
+// so an op is always handled by the worker its key routed to when it was queued.
+func (w *WorkerGrp) call(ctx context.Context, hash uint64, op OpCode) (any, error) {
+	w.routeLock.RLock()
+	var c, err = w.ws[w.router.Route(hash, w.muxSize)].submit(ctx, op)
+	w.routeLock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return c.R()
+}
+
+// callBehind : call write behind op
+func (w *WorkerGrp) callBehind(ctx context.Context, hash uint64, op OpCode) (any, error) {
+	if w.flushFn == nil {
+		return nil, ErrWriteBehindOff
+	}
+	return w.call(ctx, hash, op)
+}
+
+// get : get from cache of the routed worker, put load op into it if cache miss
+func (w *WorkerGrp) get(ctx context.Context, hash uint64, loadFn RenewDataFn, k any) (any, error) {
+	w.routeLock.RLock()
+	var wk = w.ws[w.router.Route(hash, w.muxSize)]
+	var v, ok = wk.ca.Get(k)
+	if ok {
+		//hit in cache
+		w.routeLock.RUnlock()
+		return v, nil
+	}
+	var c, err = wk.submit(ctx, NewLoad(loadFn, k))
+	w.routeLock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return c.R()
+}
+
+// lockRoute : wait ops being queued and hold route lock
+func (w *WorkerGrp) lockRoute(ctx context.Context) error {
+	var locked = make(chan struct{})
+	go func() {
+		w.routeLock.Lock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		//give up, release the lock once it's acquired
+		go func() {
+			<-locked
+			w.routeLock.Unlock()
+		}()
+		return ctx.Err()
+	}
+}
+
+// purge keys not routed to worker i anymore
+func (w *WorkerGrp) purge(i int) {
+	var wk = w.ws[i]
+	var lister, ok = wk.ca.(KeyLister)
+	if !ok {
+		wk.ca = w.cg()
+		return
+	}
+	for _, k := range lister.Keys() {
+		if w.router.Route(w.keyHash(k), w.muxSize) != i {
+			wk.ca.Delete(k)
+		}
+	}
+}
+
 // stop all works
 func (w *WorkerGrp) stop() {
-	for i := 0; i < w.muxSize; i++ {
-		w.ws[i].Stop()
+	w.resizeLock.Lock()
+	w.stopped = true
+	for _, wk := range w.ws {
+		wk.Stop()
 	}
+	w.resizeLock.Unlock()
 	//a go routine to wait all children done then signal it.
 	go w.signalExit()
 }
@@ -150,32 +371,30 @@ func (w *WorkerGrp) signalExit() {
 	w.exitSignal <- struct{}{}
 }
 
-// localize hash to worker
-func (w *WorkerGrp) locHash(k Hashed2Int) int {
-	var hashNum = k.HashedInt()
-	if hashNum < 0 {
-		hashNum = -hashNum
-	}
-	hashNum %= w.muxSize
-	return hashNum
-}
-
-// build work group build
-func buildWorkGrp(opts ...Option) *WorkerGrp {
+// new work group
+func newWorkGrp(cg CacheGen, keyHash func(k any) uint64, opts ...Option) *WorkerGrp {
 	var o = &_Option{
 		muxSize:  DefaultMuxSize,
 		deepSize: DefaultDeepSize,
+		router:   ModRouter{},
+		name:     "not-set",
 	}
 	for _, opt := range opts {
 		opt(o)
 	}
 	var w = &WorkerGrp{}
 	w.muxSize, w.deepSize = o.muxSize, o.deepSize
+	w.cg, w.router, w.keyHash = cg, o.router, keyHash
+	w.flushFn, w.flushBatch, w.maxDirty, w.flushInterval = o.flushFn, o.flushBatch, o.maxDirty, o.flushInterval
+	w.name, w.observer = o.name, o.observer
 
 	w.wg = &sync.WaitGroup{}
 	w.exitSignal = make(chan struct{}, 1)
 	w.wg.Add(w.muxSize)
 
 	w.ws = make([]*Worker, w.muxSize)
+	for i := 0; i < w.muxSize; i++ {
+		w.ws[i] = w.newWorker(i)
+	}
 	return w
 }
@@ -0,0 +1,229 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pinealctx/neptune/syncx/pipe"
+)
+
+func TestJumpHash_Consistent(t *testing.T) {
+	const keys = 10000
+	var moved int
+	for k := uint64(0); k < keys; k++ {
+		var a, b = JumpHash(k, 10), JumpHash(k, 11)
+		if a < 0 || a >= 10 || b < 0 || b >= 11 {
+			t.Fatalf("out of range %d %d", a, b)
+		}
+		if a != b {
+			if b != 10 {
+				t.Fatalf("key %d moved between old buckets %d -> %d", k, a, b)
+			}
+			moved++
+		}
+	}
+	// about 1/11 keys move
+	if moved < keys/20 || moved > keys/6 {
+		t.Errorf("unexpected moved count %d", moved)
+	}
+}
+
+func TestRingRouter_Consistent(t *testing.T) {
+	var r = NewRingRouter(64)
+	for k := uint64(0); k < 10000; k++ {
+		var a = r.Route(k, 8)
+		var b = r.Route(k, 9)
+		if a != b && b != 8 {
+			t.Fatalf("key %d moved between old workers %d -> %d", k, a, b)
+		}
+	}
+}
+
+func TestWorkerGrp_ModRouterCompatible(t *testing.T) {
+	var w = NewWorkGrpWithMapCache(WithSize(7))
+	w.Start()
+	defer w.Stop()
+
+	var ctx = context.Background()
+	for _, k := range []Int{0, 5, 13, -20} {
+		var v, err = w.DoGet(ctx, func(_ context.Context, d any) (any, error) {
+			return d, nil
+		}, k)
+		if err != nil || v != k {
+			t.Fatalf("unexpected %v %v", v, err)
+		}
+		var i = int(k) % 7
+		if i < 0 {
+			i = -i
+		}
+		if _, ok := w.ws[i].ca.Get(k); !ok {
+			t.Errorf("key %d should be cached in worker %d", k, i)
+		}
+	}
+}
+
+func TestTWorkerGrp_Resize(t *testing.T) {
+	var w = NewTWorkGrpWithLRU[string](1024, nil, WithSize(4), WithRouter(JumpRouter{}))
+	w.Start()
+
+	var (
+		ctx   = context.Background()
+		lock  sync.Mutex
+		loads = make(map[string]int)
+		keys  = []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}
+	)
+	var load = func(_ context.Context, d any) (any, error) {
+		// nolint : forcetypeassert // I know the type is exactly here
+		var k = d.(string)
+		lock.Lock()
+		loads[k]++
+		lock.Unlock()
+		time.Sleep(time.Millisecond)
+		return k, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				for _, k := range keys {
+					if v, err := w.DoGet(ctx, load, k); err != nil || v != k {
+						t.Errorf("unexpected %v %v", v, err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	for _, n := range []int{8, 3, 5} {
+		time.Sleep(5 * time.Millisecond)
+		if err := w.Resize(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	if w.MuxSize() != 5 {
+		t.Errorf("expected 5 workers, got %d", w.MuxSize())
+	}
+	// each cached key must only stay in the worker it is routed to
+	for i, wk := range w.ws {
+		// nolint : forcetypeassert // I know the type is exactly here
+		for _, k := range wk.ca.(KeyLister).Keys() {
+			// nolint : forcetypeassert // I know the type is exactly here
+			if r := JumpHash(w.hasher(k.(string)), 5); r != i {
+				t.Errorf("key %v cached in worker %d but routed to %d", k, i, r)
+			}
+		}
+	}
+
+	w.Stop()
+	var stopCtx, cancel = context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := w.WaitStop(stopCtx); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Resize(ctx, 2); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestWorkerGrp_ResizeTimeout(t *testing.T) {
+	var w = NewWorkGrpWithMapCache(WithSize(2), WithRouter(NewRingRouter(16)))
+	w.Start()
+	defer w.Stop()
+
+	var release = make(chan struct{})
+	go func() {
+		_, _ = w.DoAdd(context.Background(), func(_ context.Context, d any) (any, error) {
+			<-release
+			return d, nil
+		}, Int(1), 1)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := w.Resize(ctx, 3); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	close(release)
+	if w.MuxSize() != 2 {
+		t.Errorf("size should not change, got %d", w.MuxSize())
+	}
+	if err := w.Resize(context.Background(), 3); err != nil {
+		t.Fatal(err)
+	}
+	if w.MuxSize() != 3 {
+		t.Errorf("expected 3, got %d", w.MuxSize())
+	}
+}
+
+func TestWorkerGrp_RouteLockReleased(t *testing.T) {
+	var w = NewWorkGrpWithMapCache(WithSize(2))
+	w.Start()
+	defer w.Stop()
+
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+		done    = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		_, _ = w.DoAdd(context.Background(), func(_ context.Context, d any) (any, error) {
+			close(started)
+			<-release
+			return d, nil
+		}, Int(1), 1)
+	}()
+	<-started
+	// route lock is not held while waiting op result
+	if !w.routeLock.TryLock() {
+		t.Error("route lock should be released after op queued")
+	} else {
+		w.routeLock.Unlock()
+	}
+	close(release)
+	<-done
+
+	if v, err := w.DoGet(context.Background(), nil, Int(1)); err != nil || v != 1 {
+		t.Errorf("unexpected %v %v", v, err)
+	}
+}
+
+func TestWorkerGrp_Observer(t *testing.T) {
+	var stats = pipe.NewStatsObserver()
+	var w = NewWorkGrpWithMapCache(WithSize(3), WithName("test-mux"), WithObserver(stats))
+	w.Start()
+	defer w.Stop()
+
+	var ctx = context.Background()
+	var load = func(_ context.Context, d any) (any, error) {
+		return d, nil
+	}
+	for i := 0; i < 6; i++ {
+		if _, err := w.DoGet(ctx, load, Int(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// cache hit, not observed
+	if _, err := w.DoGet(ctx, load, Int(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.DoDelete(ctx, func(_ context.Context, _ any) error {
+		return errors.New("failed")
+	}, Int(1)); err == nil {
+		t.Fatal("expected error")
+	}
+
+	var s = stats.Stats()
+	if s.Enqueued != 7 || s.Done != 7 || s.Failed != 1 || s.Depth != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
@@ -3,9 +3,12 @@ package mux
 import (
 	"context"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/pinealctx/neptune/errorx"
+	"github.com/pinealctx/neptune/syncx/pipe"
 	"github.com/pinealctx/neptune/ulog"
 )
 
@@ -14,6 +17,16 @@ type Worker struct {
 	workQ *Q
 	wg    *sync.WaitGroup
 	ca    CacheFacade
+
+	//write behind config, nil if disabled
+	wb *writeBehind
+	//dirty entries not flushed yet, only accessed in worker go routine
+	dirty map[any]dirtyEntry
+
+	//observer, nil if not set
+	observer pipe.Observer
+	//observed point
+	at pipe.Point
 }
 
 func NewWorker(qSize int, wg *sync.WaitGroup, ca CacheFacade) *Worker {
@@ -24,6 +37,13 @@ func NewWorker(qSize int, wg *sync.WaitGroup, ca CacheFacade) *Worker {
 	}
 }
 
+// Observe : setup observer, must be called before Start.
+// Only ops put into queue are observed, cache hit of DoGet is not.
+func (w *Worker) Observe(observer pipe.Observer, at pipe.Point) {
+	w.observer = observer
+	w.at = at
+}
+
 // DoGet : get from cache first if not load from db
 func (w *Worker) DoGet(ctx context.Context, loadFn RenewDataFn, k any) (any, error) {
 	var v, ok = w.ca.Get(k)
@@ -80,6 +100,9 @@ func (w *Worker) DoUpsertThenRenewInCache(ctx context.Context,
 // Start : start handler go routine
 func (w *Worker) Start() {
 	go w.runLoop()
+	if w.wb != nil && w.wb.interval > 0 {
+		go w.flushLoop()
+	}
 }
 
 // Stop : close queue, not accept input anymore.
@@ -87,14 +110,33 @@ func (w *Worker) Stop() {
 	w.workQ.Close()
 }
 
+// barrier : wait all queued commands handled
+func (w *Worker) barrier(ctx context.Context) error {
+	var _, err = w.asyncCall(ctx, &opBarrier{})
+	return err
+}
+
 // async call
 func (w *Worker) asyncCall(ctx context.Context, op OpCode) (any, error) {
+	var c, err = w.submit(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	return c.R()
+}
+
+// submit : put op into queue without waiting, the result is got by c.R()
+func (w *Worker) submit(ctx context.Context, op OpCode) (*AsyncC, error) {
+	var enqAt time.Time
+	ctx, enqAt = pipe.ObserveEnqueue(w.observer, ctx, w.at)
 	var c = NewAsync(ctx, op)
+	c.enqAt = enqAt
 	var err = w.workQ.AddReq(c)
 	if err != nil {
+		pipe.ObserveReject(w.observer, ctx, w.at, err)
 		return nil, err
 	}
-	return c.R()
+	return c, nil
 }
 
 // loop go routine to handle async call
@@ -111,16 +153,53 @@ func (w *Worker) runLoop() {
 		if err != nil {
 			ulog.Debug("work.module.quit",
 				zap.Error(err))
+			w.flushOnQuit()
 			return
 		}
 		// nolint : forcetypeassert // I know the type is exactly here
 		c = e.(*AsyncC)
+		w.observeHandle(c)
+	}
+}
+
+// observeHandle : handle async call with observing
+func (w *Worker) observeHandle(c *AsyncC) {
+	if w.observer == nil || c.enqAt.IsZero() {
+		w.safeHandleAsync(c)
+		return
+	}
+	var deqAt time.Time
+	c.ctx, deqAt = pipe.ObserveDequeue(w.observer, c.ctx, w.at, c.enqAt)
+	//observe done before the caller is released
+	c.onDone = func(err error) {
+		pipe.ObserveDone(w.observer, c.ctx, w.at, deqAt, err)
+	}
+	w.safeHandleAsync(c)
+}
+
+// safeHandleAsync : a panic in handler is set as result error.
+// If result is already set before panic, the panic is only logged, the result chan holds one result only.
+func (w *Worker) safeHandleAsync(c *AsyncC) {
+	var err = errorx.SafeRun(func() {
 		w.handleAsync(c)
+	})
+	if err == nil {
+		return
 	}
+	if c.set {
+		ulog.Error("work.module.panic.after.result", zap.Error(err))
+		return
+	}
+	c.SetR(nil, err)
 }
 
 // async handler entry
 func (w *Worker) handleAsync(c *AsyncC) {
+	var err = w.settle(c)
+	if err != nil {
+		c.SetR(nil, err)
+		return
+	}
 	switch op := c.op.(type) {
 	case *OpLoad:
 		w.handleLoad(c, op)
@@ -136,12 +215,30 @@ func (w *Worker) handleAsync(c *AsyncC) {
 		w.handleMixUpsertThenLoad(c, op)
 	case *OpMixUpsertThenRenewInCache:
 		w.handleMixUpsertThenRenewInCache(c, op)
+	case *OpUpdateBehind:
+		w.handleUpdateBehind(c, op)
+	case *OpSetBehind:
+		w.handleSetBehind(c, op)
+	case *opFlush:
+		c.SetR(nil, w.flushAll(c.ctx))
+	case *opFlushTick:
+		w.startFlush()
+		c.SetR(nil, nil)
+	case *opFlushed:
+		w.applyFlushed(op.t)
+		c.SetR(nil, nil)
+	case *opBarrier:
+		c.SetR(nil, nil)
 	}
 }
 
 // handle load
 func (w *Worker) handleLoad(c *AsyncC, op *OpLoad) {
 	var v, ok = w.ca.Get(op.k)
+	if !ok {
+		//evicted from cache but not flushed yet
+		v, ok = w.dirtyValue(op.k)
+	}
 	if ok {
 		//hit in cache
 		c.SetR(v, nil)
@@ -155,14 +252,14 @@ func (w *Worker) handleLoad(c *AsyncC, op *OpLoad) {
 		return
 	}
 	//renew cache
-	w.ca.Set(op.k, v)
+	w.renew(op.k, v)
 	//set result
 	c.SetR(v, nil)
 }
 
 // handle add
 func (w *Worker) handleAdd(c *AsyncC, op *OpAdd) {
-	var _, exist = w.ca.Peek(op.k)
+	var _, exist = w.peekLatest(op.k)
 	if exist {
 		//key duplicate
 		c.SetR(nil, ErrDupKey)
@@ -175,14 +272,14 @@ func (w *Worker) handleAdd(c *AsyncC, op *OpAdd) {
 		return
 	}
 	//renew cache
-	w.ca.Set(op.k, v)
+	w.renew(op.k, v)
 	//set result
 	c.SetR(v, nil)
 }
 
 // handle update
 func (w *Worker) handleUpdate(c *AsyncC, op *OpUpdate) {
-	var pre, ok = w.ca.Peek(op.k)
+	var pre, ok = w.peekLatest(op.k)
 	if ok {
 		var v, err = op.updFn(c.ctx, op.data, pre)
 		if err != nil {
@@ -191,7 +288,7 @@ func (w *Worker) handleUpdate(c *AsyncC, op *OpUpdate) {
 			return
 		}
 		//renew cache
-		w.ca.Set(op.k, v)
+		w.renew(op.k, v)
 		//set result
 		c.SetR(v, nil)
 		return
@@ -209,7 +306,7 @@ func (w *Worker) handleUpdate(c *AsyncC, op *OpUpdate) {
 		return
 	}
 	//renew cache
-	w.ca.Set(op.k, v)
+	w.renew(op.k, v)
 	//set result
 	c.SetR(v, nil)
 }
@@ -223,14 +320,14 @@ func (w *Worker) handleDelete(c *AsyncC, op *OpDelete) {
 		return
 	}
 	//renew cache
-	w.ca.Delete(op.k)
+	w.remove(op.k)
 	//set result
 	c.SetR(nil, nil)
 }
 
 // handle update if exist else add if not exist.
 func (w *Worker) handleMixUpdOrAddIfNull(c *AsyncC, op *OpMixUpdOrAddIfNull) {
-	var pre, ok = w.ca.Peek(op.k)
+	var pre, ok = w.peekLatest(op.k)
 	if ok {
 		var v, err = op.updFn(c.ctx, op.data, pre)
 		if err != nil {
@@ -239,7 +336,7 @@ func (w *Worker) handleMixUpdOrAddIfNull(c *AsyncC, op *OpMixUpdOrAddIfNull) {
 			return
 		}
 		//renew cache
-		w.ca.Set(op.k, v)
+		w.renew(op.k, v)
 		//set result
 		c.SetR(v, nil)
 		return
@@ -259,7 +356,7 @@ func (w *Worker) handleMixUpdOrAddIfNull(c *AsyncC, op *OpMixUpdOrAddIfNull) {
 			return
 		}
 		//renew cache
-		w.ca.Set(op.k, v)
+		w.renew(op.k, v)
 		//set result
 		c.SetR(v, nil)
 		return
@@ -271,14 +368,14 @@ func (w *Worker) handleMixUpdOrAddIfNull(c *AsyncC, op *OpMixUpdOrAddIfNull) {
 		return
 	}
 	//renew cache
-	w.ca.Set(op.k, v)
+	w.renew(op.k, v)
 	//set result
 	c.SetR(v, nil)
 }
 
 // handle upsert first the re-new cache
 func (w *Worker) handleMixUpsertThenLoad(c *AsyncC, op *OpMixUpsertThenLoad) {
-	var pre, ok = w.ca.Peek(op.k)
+	var pre, ok = w.peekLatest(op.k)
 	if ok {
 		var v, err = op.upsertFn(c.ctx, op.data, pre)
 		if err != nil {
@@ -287,7 +384,7 @@ func (w *Worker) handleMixUpsertThenLoad(c *AsyncC, op *OpMixUpsertThenLoad) {
 			return
 		}
 		//renew cache
-		w.ca.Set(op.k, v)
+		w.renew(op.k, v)
 		//set result
 		c.SetR(v, nil)
 		return
@@ -307,14 +404,14 @@ func (w *Worker) handleMixUpsertThenLoad(c *AsyncC, op *OpMixUpsertThenLoad) {
 		return
 	}
 	//renew cache
-	w.ca.Set(op.k, v)
+	w.renew(op.k, v)
 	//set result
 	c.SetR(v, nil)
 }
 
 // handle upsert first the re-new cache if cache hit
 func (w *Worker) handleMixUpsertThenRenewInCache(c *AsyncC, op *OpMixUpsertThenRenewInCache) {
-	var pre, ok = w.ca.Peek(op.k)
+	var pre, ok = w.peekLatest(op.k)
 	if ok {
 		var v, err = op.upsertFn(c.ctx, op.data, pre)
 		if err != nil {
@@ -323,7 +420,7 @@ func (w *Worker) handleMixUpsertThenRenewInCache(c *AsyncC, op *OpMixUpsertThenR
 			return
 		}
 		//renew cache
-		w.ca.Set(op.k, v)
+		w.renew(op.k, v)
 		//set result
 		c.SetR(v, nil)
 		return
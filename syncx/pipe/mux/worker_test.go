@@ -0,0 +1,45 @@
+package mux
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/pinealctx/neptune/errorx"
+)
+
+func TestWorker_HandlerPanic(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var w = NewWorker(16, &wg, NewFacadeMap())
+	w.Start()
+	defer w.Stop()
+
+	var ctx = context.Background()
+	var _, err = w.DoGet(ctx, func(_ context.Context, _ any) (any, error) {
+		panic("load crash")
+	}, "a")
+	var pe *errorx.PanicError
+	if !errorx.As(err, &pe) {
+		t.Fatalf("expected panic error, got %v", err)
+	}
+
+	//worker keeps working after panic
+	var v any
+	v, err = w.DoGet(ctx, func(_ context.Context, _ any) (any, error) {
+		return 1, nil
+	}, "a")
+	if err != nil || v != 1 {
+		t.Fatalf("unexpected result %v %v", v, err)
+	}
+
+	//panic after result is set is only logged, no more result is sent
+	var c = &AsyncC{ctx: ctx, caller: ctx, rChan: make(chan R, 1), set: true,
+		op: NewLoad(func(_ context.Context, _ any) (any, error) {
+			panic("load crash")
+		}, "b")}
+	w.safeHandleAsync(c)
+	if len(c.rChan) != 0 {
+		t.Fatal("result should not be set after panic")
+	}
+}
@@ -0,0 +1,467 @@
+package mux
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pinealctx/neptune/ulog"
+)
+
+var (
+	//ErrWriteBehindOff write behind op without write behind enabled
+	ErrWriteBehindOff = status.Error(codes.FailedPrecondition, "mux.write.behind.disabled")
+	//ErrDirtyFull dirty items reach the limit, ops changing other keys are rejected until flushed
+	ErrDirtyFull = status.Error(codes.ResourceExhausted, "mux.write.behind.dirty.full")
+)
+
+// DirtyItem : a changed item not written to store yet
+type DirtyItem struct {
+	//K : the key in cache
+	K any
+	//V : the latest value
+	V any
+}
+
+// BatchFlushFn : write dirty items to store in batch.
+// If it returns error, all items in the batch are kept dirty and flushed again later.
+type BatchFlushFn func(ctx context.Context, items []DirtyItem) error
+
+// dirtyEntry : dirty value with its change sequence
+type dirtyEntry struct {
+	//v : the latest value
+	v any
+	//seq : changed sequence, to know whether it's changed again while being flushed
+	seq uint64
+}
+
+// flushTask : a batch flushed in background go routine
+type flushTask struct {
+	//items in batch
+	items []DirtyItem
+	//change sequence of items
+	seqs []uint64
+	//keys in batch
+	keys map[any]struct{}
+	//flush error, only read after done closed
+	err error
+	//closed after flushed
+	done chan struct{}
+}
+
+// writeBehind : write behind config and state
+type writeBehind struct {
+	//flush function
+	flushFn BatchFlushFn
+	//flush when dirty count reaches batch size, also the max item count in a batch
+	batchSize int
+	//max dirty count, new dirty keys are rejected by ErrDirtyFull when reached
+	maxDirty int
+	//flush interval, 0 means no timed flush
+	interval time.Duration
+	//dirty count, for timed flush go routine to check
+	dirtyCount atomic.Int64
+
+	//below are only accessed in worker go routine
+	//change sequence
+	seq uint64
+	//batch being flushed in background, nil if none
+	task *flushTask
+	//last background flush failed, flush by batch size is paused until a flush succeeded
+	failed bool
+}
+
+// OpUpdateBehind : update in cache only, flush to store later
+type OpUpdateBehind struct {
+	//loadFn: load item if not in cache
+	loadFn RenewDataFn
+	//mergeFn: merge data to the existed item, should not write store
+	mergeFn UpdateDataFn
+	//k: the key in cache
+	k any
+	//data: input data
+	data any
+}
+
+func NewUpdateBehind(l RenewDataFn, m UpdateDataFn, k any, data any) OpCode {
+	return &OpUpdateBehind{
+		loadFn:  l,
+		mergeFn: m,
+		k:       k,
+		data:    data,
+	}
+}
+
+func (o *OpUpdateBehind) GetK() any {
+	return o.k
+}
+
+// OpSetBehind : set value in cache only, flush to store later
+type OpSetBehind struct {
+	//k: the key in cache
+	k any
+	//v: the value
+	v any
+}
+
+func NewSetBehind(k any, v any) OpCode {
+	return &OpSetBehind{
+		k: k,
+		v: v,
+	}
+}
+
+func (o *OpSetBehind) GetK() any {
+	return o.k
+}
+
+// opFlush : flush all dirty items
+type opFlush struct{}
+
+func (o *opFlush) GetK() any {
+	return nil
+}
+
+// opFlushTick : start a background flush by timer
+type opFlushTick struct{}
+
+func (o *opFlushTick) GetK() any {
+	return nil
+}
+
+// opFlushed : background flush done, apply the result in worker go routine
+type opFlushed struct {
+	t *flushTask
+}
+
+func (o *opFlushed) GetK() any {
+	return nil
+}
+
+// EnableWriteBehind : enable write behind mode, must be called before Start.
+// Items changed by DoUpdateBehind/DoSetBehind are kept in cache and marked dirty,
+// multiple changes of a key are coalesced, dirty items are written by flushFn in batches when:
+// 1. dirty count reaches batchSize, in a background go routine, one batch at a time.
+// 2. every interval, if interval > 0, in a background go routine too.
+// 3. Flush is called.
+// 4. worker quits after Stop, errors are only logged in this case, so call Flush before Stop.
+// Background flush errors are logged, after a failure flushing by batchSize is paused until a flush succeeded.
+// When dirty count reaches maxDirty, ops changing other keys fail with ErrDirtyFull and a background flush is retried.
+// maxDirty -- 16 * batchSize if <= 0, at least batchSize
+func (w *Worker) EnableWriteBehind(flushFn BatchFlushFn, batchSize int, maxDirty int, interval time.Duration) {
+	if batchSize <= 0 {
+		batchSize = 128
+	}
+	if maxDirty <= 0 {
+		maxDirty = 16 * batchSize
+	}
+	if maxDirty < batchSize {
+		maxDirty = batchSize
+	}
+	w.wb = &writeBehind{
+		flushFn:   flushFn,
+		batchSize: batchSize,
+		maxDirty:  maxDirty,
+		interval:  interval,
+	}
+	w.dirty = make(map[any]dirtyEntry)
+}
+
+// DoUpdateBehind : update item in cache, the store is written later in batch.
+// loadFn is called if the item is not in cache, mergeFn merges data into the existed item and returns the new one.
+func (w *Worker) DoUpdateBehind(ctx context.Context,
+	loadFn RenewDataFn, mergeFn UpdateDataFn, k any, data any) (any, error) {
+	if w.wb == nil {
+		return nil, ErrWriteBehindOff
+	}
+	return w.asyncCall(ctx, NewUpdateBehind(loadFn, mergeFn, k, data))
+}
+
+// DoSetBehind : set item in cache, the store is written later in batch.
+func (w *Worker) DoSetBehind(ctx context.Context, k any, v any) error {
+	if w.wb == nil {
+		return ErrWriteBehindOff
+	}
+	var _, err = w.asyncCall(ctx, NewSetBehind(k, v))
+	return err
+}
+
+// Flush : write all dirty items to store, queued ops before it are handled first.
+func (w *Worker) Flush(ctx context.Context) error {
+	if w.wb == nil {
+		return nil
+	}
+	var _, err = w.asyncCall(ctx, &opFlush{})
+	return err
+}
+
+// DirtyCount : count of dirty items
+func (w *Worker) DirtyCount() int {
+	if w.wb == nil {
+		return 0
+	}
+	return int(w.wb.dirtyCount.Load())
+}
+
+// handle update behind
+func (w *Worker) handleUpdateBehind(c *AsyncC, op *OpUpdateBehind) {
+	var err = w.admit(op.k)
+	if err != nil {
+		c.SetR(nil, err)
+		return
+	}
+	var pre, ok = w.peekLatest(op.k)
+	if !ok {
+		pre, err = op.loadFn(c.ctx, op.k)
+		if err != nil {
+			//load error
+			c.SetR(nil, err)
+			return
+		}
+	}
+	var v any
+	v, err = op.mergeFn(c.ctx, op.data, pre)
+	if err != nil {
+		//merge error
+		c.SetR(nil, err)
+		return
+	}
+	w.markDirty(op.k, v)
+	c.SetR(v, nil)
+}
+
+// handle set behind
+func (w *Worker) handleSetBehind(c *AsyncC, op *OpSetBehind) {
+	var err = w.admit(op.k)
+	if err != nil {
+		c.SetR(nil, err)
+		return
+	}
+	w.markDirty(op.k, op.v)
+	c.SetR(op.v, nil)
+}
+
+// admit : a new dirty key is rejected when dirty count reaches the limit,
+// retry flushing in background in this case.
+func (w *Worker) admit(k any) error {
+	if _, ok := w.dirty[k]; ok || len(w.dirty) < w.wb.maxDirty {
+		return nil
+	}
+	w.startFlush()
+	return ErrDirtyFull
+}
+
+// dirtyValue : get dirty value of key
+func (w *Worker) dirtyValue(k any) (any, bool) {
+	var e, ok = w.dirty[k]
+	return e.v, ok
+}
+
+// peekLatest : peek dirty items first, dirty item may be evicted from lru cache
+func (w *Worker) peekLatest(k any) (any, bool) {
+	if v, ok := w.dirtyValue(k); ok {
+		return v, true
+	}
+	return w.ca.Peek(k)
+}
+
+// renew : renew cache after store written, a dirty item keeps dirty with the new value,
+// because the store write may not contain all changes merged before.
+func (w *Worker) renew(k any, v any) {
+	w.ca.Set(k, v)
+	if _, ok := w.dirty[k]; ok {
+		w.setDirty(k, v)
+	}
+}
+
+// remove : remove from cache and dirty items after store deleted
+func (w *Worker) remove(k any) {
+	w.ca.Delete(k)
+	if _, ok := w.dirty[k]; ok {
+		delete(w.dirty, k)
+		w.wb.dirtyCount.Add(-1)
+	}
+}
+
+// markDirty : set cache then mark dirty, flush in background if dirty count reaches batch size
+func (w *Worker) markDirty(k any, v any) {
+	w.ca.Set(k, v)
+	if _, ok := w.dirty[k]; !ok {
+		w.wb.dirtyCount.Add(1)
+	}
+	w.setDirty(k, v)
+	if len(w.dirty) >= w.wb.batchSize && !w.wb.failed {
+		w.startFlush()
+	}
+}
+
+// setDirty : set dirty value with a new change sequence
+func (w *Worker) setDirty(k any, v any) {
+	w.wb.seq++
+	w.dirty[k] = dirtyEntry{v: v, seq: w.wb.seq}
+}
+
+// startFlush : flush a batch in background go routine if no batch is being flushed
+func (w *Worker) startFlush() {
+	if w.wb.task != nil || len(w.dirty) == 0 {
+		return
+	}
+	var n = len(w.dirty)
+	if n > w.wb.batchSize {
+		n = w.wb.batchSize
+	}
+	var t = &flushTask{
+		items: make([]DirtyItem, 0, n),
+		seqs:  make([]uint64, 0, n),
+		keys:  make(map[any]struct{}, n),
+		done:  make(chan struct{}),
+	}
+	for k, e := range w.dirty {
+		if len(t.items) == n {
+			break
+		}
+		t.items = append(t.items, DirtyItem{K: k, V: e.v})
+		t.seqs = append(t.seqs, e.seq)
+		t.keys[k] = struct{}{}
+	}
+	w.wb.task = t
+	go func() {
+		t.err = w.wb.flushFn(context.Background(), t.items)
+		close(t.done)
+		//if worker is stopped, the result is applied when it quits
+		_ = w.workQ.AddPriorReq(NewAsync(context.Background(), &opFlushed{t: t}))
+	}()
+}
+
+// applyFlushed : apply background flush result, items changed while flushing keep dirty
+func (w *Worker) applyFlushed(t *flushTask) {
+	if w.wb.task != t {
+		//already applied
+		return
+	}
+	w.wb.task = nil
+	if t.err != nil {
+		w.wb.failed = true
+		ulog.Error("mux.write.behind.flush",
+			zap.Int("dirty", len(w.dirty)),
+			zap.Error(t.err))
+		return
+	}
+	w.wb.failed = false
+	for i, item := range t.items {
+		if e, ok := w.dirty[item.K]; ok && e.seq == t.seqs[i] {
+			delete(w.dirty, item.K)
+			w.wb.dirtyCount.Add(-1)
+		}
+	}
+	if len(w.dirty) >= w.wb.batchSize {
+		w.startFlush()
+	}
+}
+
+// waitFlushing : wait the background flush done and apply its result
+func (w *Worker) waitFlushing(ctx context.Context) error {
+	var t = w.wb.task
+	if t == nil {
+		return nil
+	}
+	select {
+	case <-t.done:
+		w.applyFlushed(t)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// settle : an op writing store directly waits the background flush of its key done,
+// otherwise the store may be overwritten by the older value in the batch.
+func (w *Worker) settle(c *AsyncC) error {
+	if w.wb == nil || w.wb.task == nil {
+		return nil
+	}
+	switch c.op.(type) {
+	case *OpAdd, *OpUpdate, *OpDelete,
+		*OpMixUpdOrAddIfNull, *OpMixUpsertThenLoad, *OpMixUpsertThenRenewInCache:
+		if _, ok := w.wb.task.keys[c.op.GetK()]; ok {
+			return w.waitFlushing(c.ctx)
+		}
+	}
+	return nil
+}
+
+// flushAll : wait background flush then flush all dirty items in batches, stop at the first failed batch
+func (w *Worker) flushAll(ctx context.Context) error {
+	var err = w.waitFlushing(ctx)
+	if err != nil {
+		return err
+	}
+	for len(w.dirty) > 0 {
+		err = w.flushBatch(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	w.wb.failed = false
+	return nil
+}
+
+// flushBatch : flush at most batch size dirty items
+func (w *Worker) flushBatch(ctx context.Context) error {
+	var n = len(w.dirty)
+	if n > w.wb.batchSize {
+		n = w.wb.batchSize
+	}
+	var items = make([]DirtyItem, 0, n)
+	for k, e := range w.dirty {
+		if len(items) == n {
+			break
+		}
+		items = append(items, DirtyItem{K: k, V: e.v})
+	}
+	var err = w.wb.flushFn(ctx, items)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		delete(w.dirty, item.K)
+	}
+	w.wb.dirtyCount.Add(-int64(len(items)))
+	return nil
+}
+
+// flushOnQuit : flush dirty items when worker quits
+func (w *Worker) flushOnQuit() {
+	if w.wb == nil {
+		return
+	}
+	var err = w.flushAll(context.Background())
+	if err != nil {
+		ulog.Error("mux.write.behind.quit.flush",
+			zap.Int("dirty", len(w.dirty)),
+			zap.Error(err))
+	}
+}
+
+// flushLoop : put flush tick op into queue periodically
+func (w *Worker) flushLoop() {
+	var ticker = time.NewTicker(w.wb.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if w.wb.dirtyCount.Load() == 0 {
+			if w.workQ.IsClosed() {
+				return
+			}
+			continue
+		}
+		var err = w.workQ.AddReq(NewAsync(context.Background(), &opFlushTick{}))
+		if err == ErrClosed {
+			return
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type wbStore struct {
+	lock    sync.Mutex
+	data    map[any]int
+	batches int
+	fail    bool
+}
+
+func (s *wbStore) flush(_ context.Context, items []DirtyItem) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.fail {
+		return errors.New("store down")
+	}
+	s.batches++
+	for _, item := range items {
+		// nolint : forcetypeassert // I know the type is exactly here
+		s.data[item.K] = item.V.(int)
+	}
+	return nil
+}
+
+func (s *wbStore) load(_ context.Context, k any) (any, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.data[k], nil
+}
+
+func (s *wbStore) get(k any) (int, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var v, ok = s.data[k]
+	return v, ok
+}
+
+// add delta to the existed value
+func wbMerge(_ context.Context, d any, e any) (any, error) {
+	// nolint : forcetypeassert // I know the type is exactly here
+	return e.(int) + d.(int), nil
+}
+
+func TestWorkerGrp_WriteBehind(t *testing.T) {
+	var store = &wbStore{data: map[any]int{Int(1): 100}}
+	var w = NewWorkGrpWithLRU(2, WithSize(1), WithWriteBehind(store.flush, 3, 0, 0))
+	w.Start()
+
+	var ctx = context.Background()
+	for i := 0; i < 10; i++ {
+		var v, err = w.DoUpdateBehind(ctx, store.load, wbMerge, Int(1), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != 101+i {
+			t.Fatalf("expected %d, got %v", 101+i, v)
+		}
+	}
+	// coalesced in cache, store not written yet
+	if v, _ := store.get(Int(1)); v != 100 {
+		t.Errorf("store should not be written, got %d", v)
+	}
+	if w.DirtyCount() != 1 {
+		t.Errorf("expected 1 dirty, got %d", w.DirtyCount())
+	}
+
+	// more keys than lru capacity, dirty items must not be lost by eviction
+	for k := 2; k <= 3; k++ {
+		if err := w.DoSetBehind(ctx, Int(k), k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// batch size reached, all flushed in background
+	waitDirty(t, w.DirtyCount, 0)
+	if v, _ := store.get(Int(1)); v != 110 {
+		t.Errorf("expected 110 in store, got %d", v)
+	}
+
+	// flush failed, items are kept dirty
+	store.lock.Lock()
+	store.fail = true
+	store.lock.Unlock()
+	_, _ = w.DoUpdateBehind(ctx, store.load, wbMerge, Int(4), 4)
+	if err := w.Flush(ctx); err == nil {
+		t.Error("expected flush error")
+	}
+	if w.DirtyCount() != 1 {
+		t.Errorf("expected 1 dirty, got %d", w.DirtyCount())
+	}
+	store.lock.Lock()
+	store.fail = false
+	store.lock.Unlock()
+
+	// pending dirty items are flushed when worker quits
+	w.Stop()
+	var stopCtx, cancel = context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := w.WaitStop(stopCtx); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := store.get(Int(4)); !ok || v != 4 {
+		t.Errorf("expected 4 in store, got %d %v", v, ok)
+	}
+}
+
+func TestWorker_WriteBehindInterval(t *testing.T) {
+	var store = &wbStore{data: map[any]int{}}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var w = NewWorker(16, &wg, NewFacadeMap())
+	if err := w.DoSetBehind(context.Background(), "a", 1); err != ErrWriteBehindOff {
+		t.Errorf("expected ErrWriteBehindOff, got %v", err)
+	}
+	w.EnableWriteBehind(store.flush, 100, 0, 10*time.Millisecond)
+	w.Start()
+	defer w.Stop()
+
+	if err := w.DoSetBehind(context.Background(), "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	var deadline = time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := store.get("a"); ok {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("dirty item not flushed by interval")
+}
+
+func TestWorker_WriteBehindBackground(t *testing.T) {
+	var store = &wbStore{data: map[any]int{}}
+	var release = make(chan struct{})
+	var flush = func(ctx context.Context, items []DirtyItem) error {
+		<-release
+		return store.flush(ctx, items)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var w = NewWorker(16, &wg, NewFacadeMap())
+	w.EnableWriteBehind(flush, 1, 0, 0)
+	w.Start()
+	defer w.Stop()
+
+	var ctx = context.Background()
+	// flushing in background does not block ops
+	for i := 1; i <= 2; i++ {
+		var done = make(chan error, 1)
+		go func() {
+			done <- w.DoSetBehind(ctx, "a", i)
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("op blocked by flushing")
+		}
+	}
+	close(release)
+	// changed while flushing, keeps dirty and flushed again
+	waitDirty(t, w.DirtyCount, 0)
+	if v, _ := store.get("a"); v != 2 {
+		t.Errorf("expected 2 in store, got %d", v)
+	}
+}
+
+func TestWorker_WriteBehindFull(t *testing.T) {
+	var store = &wbStore{data: map[any]int{}, fail: true}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var w = NewWorker(16, &wg, NewFacadeMap())
+	w.EnableWriteBehind(store.flush, 2, 3, 0)
+	w.Start()
+	defer w.Stop()
+
+	var ctx = context.Background()
+	for k := 1; k <= 3; k++ {
+		if err := w.DoSetBehind(ctx, k, k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// dirty set is bounded, new keys are rejected
+	if err := w.DoSetBehind(ctx, 4, 4); err != ErrDirtyFull {
+		t.Errorf("expected ErrDirtyFull, got %v", err)
+	}
+	if _, err := w.DoUpdateBehind(ctx, store.load, wbMerge, 5, 5); err != ErrDirtyFull {
+		t.Errorf("expected ErrDirtyFull, got %v", err)
+	}
+	// dirty keys can still be changed
+	if err := w.DoSetBehind(ctx, 1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if w.DirtyCount() != 3 {
+		t.Errorf("expected 3 dirty, got %d", w.DirtyCount())
+	}
+
+	store.lock.Lock()
+	store.fail = false
+	store.lock.Unlock()
+	if err := w.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.DoSetBehind(ctx, 4, 4); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := store.get(1); v != 10 {
+		t.Errorf("expected 10 in store, got %d", v)
+	}
+}
+
+// wait dirty count becomes n
+func waitDirty(t *testing.T, count func() int, n int) {
+	t.Helper()
+	var deadline = time.Now().Add(time.Second)
+	for count() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d dirty, got %d", n, count())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
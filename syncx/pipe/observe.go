@@ -0,0 +1,205 @@
+package pipe
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Point : where an observed item is, name of pipe and slot index in pipe
+type Point struct {
+	//Name : pipe name
+	Name string
+	//Slot : slot index, such as index of MultiLine queue or WorkerGrp worker, 0 for single queue pipe
+	Slot int
+}
+
+// Observer : instrumentation hooks of pipes, plugged into MQ/Line/MultiLine/RunnerQ/WorkerGrp by option.
+// Hooks are called in producer or consumer go routine directly, they should be fast and thread safe.
+// For each item, hooks are called in order: OnEnqueue -> OnDequeue -> OnDone.
+type Observer interface {
+	// OnEnqueue : an item is about to put into queue.
+	// The returned context should derive from ctx, it's carried by the item and passed to OnDequeue.
+	OnEnqueue(ctx context.Context, at Point) context.Context
+	// OnDequeue : an item is popped by consumer, wait is the duration in queue.
+	// The returned context should derive from ctx, it's passed to the handler and OnDone.
+	// If the item is rejected by queue(full or closed), OnDequeue and OnDone are called at once
+	// with zero durations and the queue error.
+	OnDequeue(ctx context.Context, at Point, wait time.Duration) context.Context
+	// OnDone : an item is handled, run is duration of handling, err is handling error.
+	OnDone(ctx context.Context, at Point, run time.Duration, err error)
+}
+
+// ObserveEnqueue : call OnEnqueue if o is not nil, return the context carried by item and enqueue time.
+func ObserveEnqueue(o Observer, ctx context.Context, at Point) (context.Context, time.Time) {
+	if o == nil {
+		return ctx, time.Time{}
+	}
+	return o.OnEnqueue(ctx, at), time.Now()
+}
+
+// ObserveReject : call OnDequeue and OnDone if o is not nil, in case item is rejected by queue.
+func ObserveReject(o Observer, ctx context.Context, at Point, err error) {
+	if o == nil {
+		return
+	}
+	ctx = o.OnDequeue(ctx, at, 0)
+	o.OnDone(ctx, at, 0, err)
+}
+
+// ObserveDequeue : call OnDequeue if o is not nil, return the context passed to handler and dequeue time.
+// enqAt -- enqueue time returned by ObserveEnqueue
+func ObserveDequeue(o Observer, ctx context.Context, at Point, enqAt time.Time) (context.Context, time.Time) {
+	if o == nil {
+		return ctx, time.Time{}
+	}
+	var now = time.Now()
+	return o.OnDequeue(ctx, at, now.Sub(enqAt)), now
+}
+
+// ObserveDone : call OnDone if o is not nil.
+// deqAt -- dequeue time returned by ObserveDequeue
+func ObserveDone(o Observer, ctx context.Context, at Point, deqAt time.Time, err error) {
+	if o == nil {
+		return
+	}
+	o.OnDone(ctx, at, time.Since(deqAt), err)
+}
+
+// Stats : snapshot of StatsObserver
+type Stats struct {
+	//Enqueued : count of items put into queue, including rejected ones
+	Enqueued uint64
+	//Dequeued : count of items popped from queue, including rejected ones
+	Dequeued uint64
+	//Done : count of items handled
+	Done uint64
+	//Failed : count of items handled with error, including rejected ones
+	Failed uint64
+	//Depth : items in queue now
+	Depth int64
+	//MaxDepth : max items in queue
+	MaxDepth int64
+	//TotalWait : total duration in queue
+	TotalWait time.Duration
+	//MaxWait : max duration in queue
+	MaxWait time.Duration
+	//TotalRun : total duration of handling
+	TotalRun time.Duration
+	//MaxRun : max duration of handling
+	MaxRun time.Duration
+}
+
+// AvgWait : average duration in queue
+func (s Stats) AvgWait() time.Duration {
+	if s.Dequeued == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(s.Dequeued)
+}
+
+// AvgRun : average duration of handling
+func (s Stats) AvgRun() time.Duration {
+	if s.Done == 0 {
+		return 0
+	}
+	return s.TotalRun / time.Duration(s.Done)
+}
+
+// StatsObserver : observer to count items and durations, it's lock free.
+// Use one StatsObserver for each pipe, all slots of a pipe are counted together.
+type StatsObserver struct {
+	enqueued  atomic.Uint64
+	dequeued  atomic.Uint64
+	done      atomic.Uint64
+	failed    atomic.Uint64
+	depth     atomic.Int64
+	maxDepth  atomic.Int64
+	totalWait atomic.Int64
+	maxWait   atomic.Int64
+	totalRun  atomic.Int64
+	maxRun    atomic.Int64
+}
+
+// NewStatsObserver : new stats observer
+func NewStatsObserver() *StatsObserver {
+	return &StatsObserver{}
+}
+
+// OnEnqueue : implement Observer
+func (s *StatsObserver) OnEnqueue(ctx context.Context, _ Point) context.Context {
+	s.enqueued.Add(1)
+	storeMax(&s.maxDepth, s.depth.Add(1))
+	return ctx
+}
+
+// OnDequeue : implement Observer
+func (s *StatsObserver) OnDequeue(ctx context.Context, _ Point, wait time.Duration) context.Context {
+	s.dequeued.Add(1)
+	s.depth.Add(-1)
+	s.totalWait.Add(int64(wait))
+	storeMax(&s.maxWait, int64(wait))
+	return ctx
+}
+
+// OnDone : implement Observer
+func (s *StatsObserver) OnDone(_ context.Context, _ Point, run time.Duration, err error) {
+	if err != nil {
+		s.failed.Add(1)
+	}
+	s.done.Add(1)
+	s.totalRun.Add(int64(run))
+	storeMax(&s.maxRun, int64(run))
+}
+
+// Stats : snapshot of stats
+func (s *StatsObserver) Stats() Stats {
+	return Stats{
+		Enqueued:  s.enqueued.Load(),
+		Dequeued:  s.dequeued.Load(),
+		Done:      s.done.Load(),
+		Failed:    s.failed.Load(),
+		Depth:     s.depth.Load(),
+		MaxDepth:  s.maxDepth.Load(),
+		TotalWait: time.Duration(s.totalWait.Load()),
+		MaxWait:   time.Duration(s.maxWait.Load()),
+		TotalRun:  time.Duration(s.totalRun.Load()),
+		MaxRun:    time.Duration(s.maxRun.Load()),
+	}
+}
+
+// MultiObserver : combine observers, hooks are called in order
+type MultiObserver []Observer
+
+// OnEnqueue : implement Observer
+func (m MultiObserver) OnEnqueue(ctx context.Context, at Point) context.Context {
+	for _, o := range m {
+		ctx = o.OnEnqueue(ctx, at)
+	}
+	return ctx
+}
+
+// OnDequeue : implement Observer
+func (m MultiObserver) OnDequeue(ctx context.Context, at Point, wait time.Duration) context.Context {
+	for _, o := range m {
+		ctx = o.OnDequeue(ctx, at, wait)
+	}
+	return ctx
+}
+
+// OnDone : implement Observer
+func (m MultiObserver) OnDone(ctx context.Context, at Point, run time.Duration, err error) {
+	for _, o := range m {
+		o.OnDone(ctx, at, run, err)
+	}
+}
+
+// storeMax : store v if it's larger than the existed value
+func storeMax(m *atomic.Int64, v int64) {
+	for {
+		var old = m.Load()
+		if v <= old || m.CompareAndSwap(old, v) {
+			return
+		}
+	}
+}
@@ -14,6 +14,10 @@ type _Option struct {
 	slotSize int
 	//queue size in each slot
 	qSize int
+	//name, used as observed point name
+	name string
+	//observer
+	observer Observer
 }
 
 // Option shunt option function
@@ -33,6 +37,20 @@ func WithQSize(qSize int) Option {
 	}
 }
 
+// WithName setup name, it's used as point name of observer
+func WithName(name string) Option {
+	return func(o *_Option) {
+		o.name = name
+	}
+}
+
+// WithObserver setup observer
+func WithObserver(observer Observer) Option {
+	return func(o *_Option) {
+		o.observer = observer
+	}
+}
+
 // GetOption : return slot size and q size
 func GetOption(opts ...Option) (slotSize int, qSize int) {
 	var o = &_Option{
@@ -44,3 +62,14 @@ func GetOption(opts ...Option) (slotSize int, qSize int) {
 	}
 	return o.slotSize, o.qSize
 }
+
+// GetObserver : return observer and name, observer is nil if not set
+func GetObserver(opts ...Option) (observer Observer, name string) {
+	var o = &_Option{
+		name: "not-set",
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o.observer, o.name
+}
@@ -0,0 +1,471 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pinealctx/neptune/errorx"
+)
+
+const (
+	//DefaultOrderWindow default max items in flight of ordered pipeline
+	DefaultOrderWindow = 1024
+)
+
+var (
+	//ErrPipelineClosed -- push to a closed pipeline
+	ErrPipelineClosed = status.Error(codes.Unavailable, "pipeline.closed")
+	//ErrPipelineNotStarted -- push to a pipeline not started
+	ErrPipelineNotStarted = status.Error(codes.FailedPrecondition, "pipeline.not.started")
+
+	//ErrDrop -- returned by stage function to drop an item, it's not an error of pipeline
+	ErrDrop = errors.New("pipeline.drop.item")
+)
+
+// StageFn : stage function, handle an item and return the output to next stage.
+// Return ErrDrop to drop the item, other error stops the whole pipeline.
+// ctx -- pipeline context, it's done when pipeline fails or parent context is done.
+type StageFn func(ctx context.Context, in any) (out any, err error)
+
+// SinkFn : sink function, consume output of the last stage, error stops the whole pipeline.
+type SinkFn func(ctx context.Context, out any) error
+
+// StageOf : wrap typed stage function, input of other type stops pipeline with ErrInvalidParam.
+// Use TPipeline to check types between stages at compile time.
+func StageOf[In, Out any](fn func(ctx context.Context, in In) (Out, error)) StageFn {
+	return func(ctx context.Context, in any) (any, error) {
+		var v, ok = in.(In)
+		if !ok {
+			return nil, ErrInvalidParam
+		}
+		return fn(ctx, v)
+	}
+}
+
+// SinkOf : wrap typed sink function, output of other type stops pipeline with ErrInvalidParam
+func SinkOf[T any](fn func(ctx context.Context, out T) error) SinkFn {
+	return func(ctx context.Context, out any) error {
+		var v, ok = out.(T)
+		if !ok {
+			return ErrInvalidParam
+		}
+		return fn(ctx, v)
+	}
+}
+
+// pipeline option
+type pipelineOption struct {
+	//channel buffer size between stages
+	buffer int
+	//ordered output
+	ordered bool
+	//max items in flight of ordered pipeline
+	window int
+}
+
+// PipelineOption : pipeline option function
+type PipelineOption func(o *pipelineOption)
+
+// WithStageBuffer : setup channel buffer size between stages, default is 0(unbuffered).
+func WithStageBuffer(buffer int) PipelineOption {
+	return func(o *pipelineOption) {
+		o.buffer = buffer
+	}
+}
+
+// WithOrdered : sink consumes items in the order they are pushed, even if stages run in parallel.
+// window -- max items in flight, Push blocks when it's reached, DefaultOrderWindow is used if window <= 0.
+func WithOrdered(window int) PipelineOption {
+	return func(o *pipelineOption) {
+		o.ordered = true
+		o.window = window
+	}
+}
+
+// stage : stage function and worker count
+type stage struct {
+	fn      StageFn
+	workers int
+}
+
+// pipeItem : item in pipeline
+type pipeItem struct {
+	//sequence number, only for ordered pipeline
+	seq uint64
+	//value
+	v any
+	//dropped by a stage, only passed through in ordered pipeline to keep sequence
+	dropped bool
+}
+
+// Pipeline : multi-stage pipeline, each stage runs in its own workers and is connected by bounded channels.
+// Build it with Stage and Sink, then Start, Push items, Close to drain and Wait for the result:
+//
+//	var p = pipe.NewPipeline().Stage(parse, 4).Stage(enrich, 8).Sink(save)
+//	_ = p.Start(ctx)
+//	for _, line := range lines {
+//		_ = p.Push(ctx, line)
+//	}
+//	p.Close()
+//	var err = p.Wait()
+//
+// The first error of any stage or sink cancels the pipeline, Wait returns it.
+// If it's closed before Start, Start returns ErrPipelineClosed and Wait returns nil.
+type Pipeline struct {
+	stages []stage
+	sink   SinkFn
+
+	//options
+	buffer  int
+	ordered bool
+	window  int
+
+	//pipeline context
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	//input channel
+	in chan pipeItem
+	//in flight tokens of ordered pipeline
+	tokens chan struct{}
+	//push lock, guards seq/closed and serializes pushing
+	pushLock sync.Mutex
+	//next sequence number
+	seq uint64
+	//closed
+	closed bool
+
+	//all stage workers and sink
+	wg sync.WaitGroup
+	//sink drained all items
+	drained bool
+	//final error
+	err error
+	//done signal
+	done chan struct{}
+
+	closeOnce sync.Once
+}
+
+// NewPipeline : new pipeline
+func NewPipeline(opts ...PipelineOption) *Pipeline {
+	var o = &pipelineOption{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.buffer < 0 {
+		o.buffer = 0
+	}
+	if o.window <= 0 {
+		o.window = DefaultOrderWindow
+	}
+	return &Pipeline{
+		buffer:  o.buffer,
+		ordered: o.ordered,
+		window:  o.window,
+		done:    make(chan struct{}),
+	}
+}
+
+// Stage : append a stage with worker count, must be called before Start
+func (p *Pipeline) Stage(fn StageFn, workers int) *Pipeline {
+	if workers <= 0 {
+		workers = 1
+	}
+	p.stages = append(p.stages, stage{fn: fn, workers: workers})
+	return p
+}
+
+// Sink : setup sink, must be called before Start.
+// Sink runs in one go routine, outputs of the last stage are discarded if it's not set.
+func (p *Pipeline) Sink(fn SinkFn) *Pipeline {
+	p.sink = fn
+	return p
+}
+
+// Start : start all stages and sink, it returns ErrPipelineClosed if pipeline is closed before started.
+// Start again is ignored.
+// ctx -- parent context, pipeline is canceled when it's done.
+func (p *Pipeline) Start(ctx context.Context) error {
+	p.pushLock.Lock()
+	defer p.pushLock.Unlock()
+	if p.closed {
+		return ErrPipelineClosed
+	}
+	if p.in != nil {
+		return nil
+	}
+	p.ctx, p.cancel = context.WithCancelCause(ctx)
+	if p.ordered {
+		p.tokens = make(chan struct{}, p.window)
+	}
+	p.in = make(chan pipeItem, p.buffer)
+
+	var in = p.in
+	for _, s := range p.stages {
+		var out = make(chan pipeItem, p.buffer)
+		p.runStage(s, in, out)
+		in = out
+	}
+	p.wg.Add(1)
+	go p.runSink(in)
+	go p.signalDone()
+	return nil
+}
+
+// Push : push an item into the first stage, it blocks if the first stage is busy.
+// ctx -- context for waiting, it does not affect the item after pushed.
+func (p *Pipeline) Push(ctx context.Context, v any) error {
+	p.pushLock.Lock()
+	defer p.pushLock.Unlock()
+	if p.closed {
+		return ErrPipelineClosed
+	}
+	if p.in == nil {
+		return ErrPipelineNotStarted
+	}
+	if p.ordered {
+		select {
+		case p.tokens <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.ctx.Done():
+			return context.Cause(p.ctx)
+		}
+	}
+	select {
+	case p.in <- pipeItem{seq: p.seq, v: v}:
+		p.seq++
+		return nil
+	case <-ctx.Done():
+		p.releaseToken()
+		return ctx.Err()
+	case <-p.ctx.Done():
+		p.releaseToken()
+		return context.Cause(p.ctx)
+	}
+}
+
+// Close : stop accepting input, items pushed are drained through all stages and sink gracefully.
+// If it's not started, it's done at once and can not be started.
+func (p *Pipeline) Close() {
+	p.closeOnce.Do(func() {
+		p.pushLock.Lock()
+		defer p.pushLock.Unlock()
+		p.closed = true
+		if p.in != nil {
+			close(p.in)
+		} else {
+			close(p.done)
+		}
+	})
+}
+
+// Wait : wait all stages and sink exit.
+// It returns nil if all items are drained after Close, otherwise the first error of stages or sink,
+// or the error of parent context.
+func (p *Pipeline) Wait() error {
+	<-p.done
+	return p.err
+}
+
+// Done : return a channel which is closed after all stages and sink exit
+func (p *Pipeline) Done() <-chan struct{} {
+	return p.done
+}
+
+// runStage : start stage workers, out is closed after all workers exit
+func (p *Pipeline) runStage(s stage, in <-chan pipeItem, out chan<- pipeItem) {
+	var wg sync.WaitGroup
+	wg.Add(s.workers)
+	p.wg.Add(s.workers)
+	for i := 0; i < s.workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			defer wg.Done()
+			p.stageLoop(s.fn, in, out)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+}
+
+// stageLoop : stage worker loop
+func (p *Pipeline) stageLoop(fn StageFn, in <-chan pipeItem, out chan<- pipeItem) {
+	for {
+		var it pipeItem
+		var ok bool
+		select {
+		case <-p.ctx.Done():
+			return
+		case it, ok = <-in:
+			if !ok {
+				return
+			}
+		}
+		if !it.dropped {
+			var v, err = safeStage(fn, p.ctx, it.v)
+			if err != nil {
+				if !errors.Is(err, ErrDrop) {
+					p.cancel(err)
+					return
+				}
+				if !p.ordered {
+					continue
+				}
+				//pass it through to keep sequence
+				it.dropped, it.v = true, nil
+			} else {
+				it.v = v
+			}
+		}
+		select {
+		case <-p.ctx.Done():
+			return
+		case out <- it:
+		}
+	}
+}
+
+// runSink : sink loop, items are reordered in ordered pipeline
+func (p *Pipeline) runSink(in <-chan pipeItem) {
+	defer p.wg.Done()
+	var (
+		next    uint64
+		pending = make(map[uint64]pipeItem)
+	)
+	for {
+		var it pipeItem
+		var ok bool
+		select {
+		case <-p.ctx.Done():
+			return
+		case it, ok = <-in:
+			if !ok {
+				//all workers exit because their input is closed, not canceled
+				p.drained = p.ctx.Err() == nil
+				return
+			}
+		}
+		if !p.ordered {
+			if !p.consume(it) {
+				return
+			}
+			continue
+		}
+		pending[it.seq] = it
+		for {
+			var x, exist = pending[next]
+			if !exist {
+				break
+			}
+			delete(pending, next)
+			next++
+			if !p.consume(x) {
+				return
+			}
+			p.releaseToken()
+		}
+	}
+}
+
+// consume : call sink, return false if sink failed
+func (p *Pipeline) consume(it pipeItem) bool {
+	if p.sink == nil || it.dropped {
+		return true
+	}
+	var err = errorx.SafeRun(func() {
+		if e := p.sink(p.ctx, it.v); e != nil {
+			p.cancel(e)
+		}
+	})
+	if err != nil {
+		p.cancel(err)
+	}
+	return p.ctx.Err() == nil
+}
+
+// releaseToken : release an in flight token of ordered pipeline
+func (p *Pipeline) releaseToken() {
+	if p.ordered {
+		<-p.tokens
+	}
+}
+
+// signalDone : wait all stages and sink exit, then set result
+func (p *Pipeline) signalDone() {
+	p.wg.Wait()
+	if !p.drained {
+		p.err = context.Cause(p.ctx)
+	}
+	p.cancel(nil)
+	close(p.done)
+}
+
+// safeStage : call stage function, a panic in stage function is returned as error
+func safeStage(fn StageFn, ctx context.Context, in any) (out any, err error) {
+	defer errorx.Recover(&err)
+	return fn(ctx, in)
+}
+
+// TPipeline : typed pipeline, In is input type of the first stage, Out is output type of the last stage.
+// Stages are chained by Then, so types between stages are checked at compile time:
+//
+//	var p = pipe.Then(pipe.NewTPipeline(parse, 4), enrich, 8).Sink(save)
+//	_ = p.Start(ctx)
+//	_ = p.Push(ctx, line)
+//
+// It works like Pipeline, see Pipeline.
+type TPipeline[In, Out any] struct {
+	p *Pipeline
+}
+
+// NewTPipeline : new typed pipeline with the first stage and its worker count
+func NewTPipeline[In, Out any](fn func(ctx context.Context, in In) (Out, error), workers int,
+	opts ...PipelineOption) *TPipeline[In, Out] {
+	return &TPipeline[In, Out]{p: NewPipeline(opts...).Stage(StageOf(fn), workers)}
+}
+
+// Then : append a stage to typed pipeline, input type of the stage is output type of the pipeline.
+// It must be called before Start, the pipeline passed in should not be used any more.
+func Then[In, Mid, Out any](tp *TPipeline[In, Mid], fn func(ctx context.Context, in Mid) (Out, error),
+	workers int) *TPipeline[In, Out] {
+	return &TPipeline[In, Out]{p: tp.p.Stage(StageOf(fn), workers)}
+}
+
+// Sink : setup sink, must be called before Start
+func (tp *TPipeline[In, Out]) Sink(fn func(ctx context.Context, out Out) error) *TPipeline[In, Out] {
+	tp.p.Sink(SinkOf(fn))
+	return tp
+}
+
+// Start : start all stages and sink, see Pipeline.Start
+func (tp *TPipeline[In, Out]) Start(ctx context.Context) error {
+	return tp.p.Start(ctx)
+}
+
+// Push : push an item into the first stage, see Pipeline.Push
+func (tp *TPipeline[In, Out]) Push(ctx context.Context, v In) error {
+	return tp.p.Push(ctx, v)
+}
+
+// Close : stop accepting input, see Pipeline.Close
+func (tp *TPipeline[In, Out]) Close() {
+	tp.p.Close()
+}
+
+// Wait : wait all stages and sink exit, see Pipeline.Wait
+func (tp *TPipeline[In, Out]) Wait() error {
+	return tp.p.Wait()
+}
+
+// Done : return a channel which is closed after all stages and sink exit
+func (tp *TPipeline[In, Out]) Done() <-chan struct{} {
+	return tp.p.Done()
+}
@@ -0,0 +1,235 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPipeline_Ordered(t *testing.T) {
+	var out []string
+	var p = NewPipeline(WithStageBuffer(4), WithOrdered(16)).
+		Stage(StageOf(func(_ context.Context, in int) (int, error) {
+			time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+			if in%3 == 0 {
+				return 0, ErrDrop
+			}
+			return in * 10, nil
+		}), 8).
+		Stage(StageOf(func(_ context.Context, in int) (string, error) {
+			time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+			return strconv.Itoa(in), nil
+		}), 4).
+		Sink(SinkOf(func(_ context.Context, s string) error {
+			out = append(out, s)
+			return nil
+		}))
+
+	var ctx = context.Background()
+	if err := p.Push(ctx, 1); err != ErrPipelineNotStarted {
+		t.Errorf("expected ErrPipelineNotStarted, got %v", err)
+	}
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 300; i++ {
+		if err := p.Push(ctx, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Close()
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Push(ctx, 1); err != ErrPipelineClosed {
+		t.Errorf("expected ErrPipelineClosed, got %v", err)
+	}
+
+	var j int
+	for i := 0; i < 300; i++ {
+		if i%3 == 0 {
+			continue
+		}
+		if j >= len(out) || out[j] != strconv.Itoa(i*10) {
+			t.Fatalf("unexpected output at %d: %v", j, out)
+		}
+		j++
+	}
+	if j != len(out) {
+		t.Errorf("expected %d outputs, got %d", j, len(out))
+	}
+}
+
+func TestPipeline_Unordered(t *testing.T) {
+	var (
+		lock sync.Mutex
+		sum  int
+		n    int
+	)
+	var p = NewPipeline().
+		Stage(func(_ context.Context, in any) (any, error) {
+			// nolint : forcetypeassert // I know the type is exactly here
+			return in.(int) * 2, nil
+		}, 4).
+		Sink(func(_ context.Context, out any) error {
+			lock.Lock()
+			defer lock.Unlock()
+			// nolint : forcetypeassert // I know the type is exactly here
+			sum += out.(int)
+			n++
+			return nil
+		})
+	_ = p.Start(context.Background())
+	for i := 1; i <= 100; i++ {
+		_ = p.Push(context.Background(), i)
+	}
+	p.Close()
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 100 || sum != 10100 {
+		t.Errorf("unexpected result %d %d", n, sum)
+	}
+}
+
+func TestPipeline_Error(t *testing.T) {
+	var errBad = errors.New("bad")
+	var p = NewPipeline(WithOrdered(4)).
+		Stage(func(_ context.Context, in any) (any, error) {
+			if in == 5 {
+				return nil, errBad
+			}
+			return in, nil
+		}, 2).
+		Stage(func(_ context.Context, in any) (any, error) {
+			if in == 7 {
+				panic("crazy")
+			}
+			return in, nil
+		}, 2)
+	_ = p.Start(context.Background())
+
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		err = p.Push(context.Background(), i)
+	}
+	if err != errBad {
+		t.Errorf("push should fail with stage error, got %v", err)
+	}
+	if err = p.Wait(); err != errBad {
+		t.Errorf("expected errBad, got %v", err)
+	}
+
+	// sink error
+	p = NewPipeline().Sink(func(_ context.Context, _ any) error {
+		return errBad
+	})
+	_ = p.Start(context.Background())
+	_ = p.Push(context.Background(), 1)
+	if err = p.Wait(); err != errBad {
+		t.Errorf("expected errBad, got %v", err)
+	}
+
+	// type mismatch
+	p = NewPipeline().Stage(StageOf(func(_ context.Context, in int) (int, error) {
+		return in, nil
+	}), 1)
+	_ = p.Start(context.Background())
+	_ = p.Push(context.Background(), "1")
+	if err = p.Wait(); err != ErrInvalidParam {
+		t.Errorf("expected ErrInvalidParam, got %v", err)
+	}
+}
+
+func TestPipeline_Cancel(t *testing.T) {
+	var ctx, cancel = context.WithCancel(context.Background())
+	var p = NewPipeline().Stage(func(ctx context.Context, in any) (any, error) {
+		<-ctx.Done()
+		return in, nil
+	}, 1)
+	_ = p.Start(ctx)
+	_ = p.Push(context.Background(), 1)
+
+	// first stage is busy, push waits until its context is done
+	var pushCtx, pushCancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer pushCancel()
+	if err := p.Push(pushCtx, 2); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	cancel()
+	select {
+	case <-p.Done():
+	case <-time.After(time.Second):
+		t.Fatal("pipeline should exit after canceled")
+	}
+	if err := p.Wait(); err != context.Canceled {
+		t.Errorf("expected canceled, got %v", err)
+	}
+}
+
+func TestPipeline_CloseBeforeStart(t *testing.T) {
+	var p = NewPipeline().Sink(func(_ context.Context, _ any) error {
+		return nil
+	})
+	p.Close()
+	if err := p.Start(context.Background()); err != ErrPipelineClosed {
+		t.Errorf("expected ErrPipelineClosed, got %v", err)
+	}
+	if err := p.Push(context.Background(), 1); err != ErrPipelineClosed {
+		t.Errorf("expected ErrPipelineClosed, got %v", err)
+	}
+	select {
+	case <-p.Done():
+	case <-time.After(time.Second):
+		t.Fatal("closed pipeline should be done")
+	}
+	if err := p.Wait(); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestTPipeline(t *testing.T) {
+	var sum int
+	var p = Then(NewTPipeline(func(_ context.Context, in string) (int, error) {
+		return strconv.Atoi(in)
+	}, 4, WithOrdered(0)), func(_ context.Context, in int) (int, error) {
+		return in * 2, nil
+	}, 2).Sink(func(_ context.Context, out int) error {
+		sum += out
+		return nil
+	})
+
+	var ctx = context.Background()
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 10; i++ {
+		if err := p.Push(ctx, strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Close()
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if sum != 110 {
+		t.Errorf("expected 110, got %d", sum)
+	}
+
+	// error of stage stops pipeline
+	p = Then(NewTPipeline(func(_ context.Context, in string) (int, error) {
+		return strconv.Atoi(in)
+	}, 1), func(_ context.Context, in int) (int, error) {
+		return in, nil
+	}, 1)
+	_ = p.Start(ctx)
+	_ = p.Push(ctx, "x")
+	if err := p.Wait(); err == nil {
+		t.Error("expected parse error")
+	}
+}
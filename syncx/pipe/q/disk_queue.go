@@ -0,0 +1,953 @@
+package q
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultSegmentSize is the default max size of a segment file
+	DefaultSegmentSize = 64 * 1024 * 1024
+
+	// segmentExt is the extension of segment files, file name is the first sequence in it
+	segmentExt = ".seg"
+	// checkpointFile stores the acked sequence and its record position
+	checkpointFile = "checkpoint"
+	// checkpointSize : acked sequence(uint64) + segment(uint64) + offset(uint64) + crc32(uint32)
+	checkpointSize = 28
+	// legacyCheckpointSize : acked sequence(uint64) + crc32(uint32), without record position
+	legacyCheckpointSize = 12
+	// lockFile is locked exclusively while the queue is open
+	lockFile = "LOCK"
+	// recordHeaderSize : payload length(uint32) + payload crc32(uint32)
+	recordHeaderSize = 8
+)
+
+var (
+	// ErrInvalidAck is returned when acking a sequence which is not delivered or already acked
+	ErrInvalidAck = errors.New("pipe.q.invalid.ack")
+
+	// ErrCorrupted is returned when a record in segment file is broken
+	ErrCorrupted = errors.New("pipe.q.corrupted")
+
+	// ErrDirLocked is returned when the queue dir is opened by another DiskQ
+	ErrDirLocked = errors.New("pipe.q.dir.locked")
+)
+
+// Codec encodes/decodes items of DiskQ
+type Codec[T any] interface {
+	// Marshal encodes item
+	Marshal(item T) ([]byte, error)
+	// Unmarshal decodes item
+	Unmarshal(data []byte) (T, error)
+}
+
+// JSONCodec encodes items as json, it is the default codec of DiskQ
+type JSONCodec[T any] struct{}
+
+// Marshal encodes item as json
+func (JSONCodec[T]) Marshal(item T) ([]byte, error) {
+	return json.Marshal(item)
+}
+
+// Unmarshal decodes json to item
+func (JSONCodec[T]) Unmarshal(data []byte) (T, error) {
+	var item T
+	var err = json.Unmarshal(data, &item)
+	return item, err
+}
+
+// diskQOption holds DiskQ configuration
+type diskQOption struct {
+	segmentSize     int64
+	capacity        int
+	syncWrite       bool
+	autoAck         bool
+	checkpointEvery int
+}
+
+// DiskQOption configures a DiskQ
+type DiskQOption func(o *diskQOption)
+
+// WithSegmentSize sets the max size of a segment file, a new segment is created when it is exceeded
+func WithSegmentSize(size int64) DiskQOption {
+	return func(o *diskQOption) {
+		o.segmentSize = size
+	}
+}
+
+// WithDiskQCap sets the max number of undelivered items, 0 means unlimited
+func WithDiskQCap(capacity int) DiskQOption {
+	return func(o *diskQOption) {
+		o.capacity = capacity
+	}
+}
+
+// WithSyncWrite makes each push fsync the segment file before returning
+func WithSyncWrite(sync bool) DiskQOption {
+	return func(o *diskQOption) {
+		o.syncWrite = sync
+	}
+}
+
+// WithAutoAck acks items as soon as they are popped by PopSeq, delivery becomes at-most-once.
+// Pop always acks, it does not need this option.
+func WithAutoAck() DiskQOption {
+	return func(o *diskQOption) {
+		o.autoAck = true
+	}
+}
+
+// WithCheckpointEvery writes checkpoint every n acked sequences instead of every time,
+// at most n-1 acked items are delivered again after crash. Close always writes checkpoint.
+func WithCheckpointEvery(n int) DiskQOption {
+	return func(o *diskQOption) {
+		o.checkpointEvery = n
+	}
+}
+
+// segment is a segment file
+type segment struct {
+	// first sequence in the segment
+	first uint64
+	path  string
+	// size of a sealed segment, writeSize is used for the last one
+	size int64
+}
+
+// recordPos is the position of a record
+type recordPos struct {
+	// first sequence of the segment
+	first uint64
+	// offset in the segment
+	off int64
+}
+
+// DiskQ represents a persistent queue backed by append-only segment files in a directory.
+//
+// Items are encoded by codec and appended to the last segment file, each record is
+// [length uint32][crc32 uint32][payload]. Items are delivered in push order, a delivered item
+// must be acknowledged by Ack with the sequence returned by PopSeq; acked sequences are
+// checkpointed to disk and segments with all items acked are removed.
+// When the queue is opened again, all unacked items are delivered again (at-least-once).
+// Records broken by crash at the tail of the last segment are truncated when opening.
+// If a broken record is met when popping, the rest of its segment is dropped and ErrCorrupted is returned once.
+//
+// Pop from Queue interface does not return sequence, it acks the item when it's delivered (at-most-once),
+// so the queue can be consumed through Queue interface only, e.g. by SimpleTaskProcessor.
+// The dir is locked while the queue is open, ErrDirLocked is returned if it's opened again.
+// Len, IsFull and capacity count undelivered items only.
+type DiskQ[T any] struct {
+	dir   string
+	codec Codec[T]
+	opt   diskQOption
+
+	// lock file of dir
+	dirLock *os.File
+
+	// segments sorted by first sequence, the last one is being written
+	segments []segment
+	// writer is the last segment file
+	writer *os.File
+	// writeSize is size of the last segment file
+	writeSize int64
+	// writeSeq is the next sequence to push
+	writeSeq uint64
+
+	// reader is the segment file being read, readIdx is its index in segments
+	reader  *os.File
+	readIdx int
+	// readOff is offset of next record in reader
+	readOff int64
+	// readSeq is the next sequence to deliver
+	readSeq uint64
+
+	// ackSeq : all sequences before it are acked
+	ackSeq uint64
+	// acked holds acked sequences after ackSeq
+	acked map[uint64]struct{}
+	// pos holds record positions of delivered but unacked sequences, to checkpoint position of ackSeq
+	pos map[uint64]recordPos
+	// checkpointSeq is the acked sequence in checkpoint file
+	checkpointSeq uint64
+
+	// closed indicates if the queue is closed
+	closed bool
+
+	// lock protects all queue operations
+	lock sync.Mutex
+	// condSub is used to signal waiting Pop() operations
+	condSub sync.Cond
+	// condPub is used to signal waiting PushBlocking() operations
+	condPub sync.Cond
+}
+
+// OpenDiskQ opens or creates a persistent queue in dir.
+// codec -- item codec, JSONCodec is used if nil
+func OpenDiskQ[T any](dir string, codec Codec[T], opts ...DiskQOption) (*DiskQ[T], error) {
+	var o = diskQOption{
+		segmentSize:     DefaultSegmentSize,
+		checkpointEvery: 1,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.capacity < 0 {
+		return nil, errors.New("queue capacity must be non-negative")
+	}
+	if o.checkpointEvery <= 0 {
+		o.checkpointEvery = 1
+	}
+	if codec == nil {
+		codec = JSONCodec[T]{}
+	}
+
+	var q = &DiskQ[T]{
+		dir:   dir,
+		codec: codec,
+		opt:   o,
+		acked: make(map[uint64]struct{}),
+		pos:   make(map[uint64]recordPos),
+	}
+	q.condSub.L = &q.lock
+	q.condPub.L = &q.lock
+
+	var err = q.open()
+	if err != nil {
+		q.closeFiles()
+		return nil, err
+	}
+	return q, nil
+}
+
+// Push appends an item to the queue
+// Returns ErrQueueFull if undelivered items reach capacity (when capacity > 0)
+func (q *DiskQ[T]) Push(item T) error {
+	var data, err = q.codec.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+	if q.opt.capacity > 0 && q.lenLocked() >= q.opt.capacity {
+		return ErrQueueFull
+	}
+	return q.appendLocked(data)
+}
+
+// PushBlocking appends an item to the queue
+// Blocks if queue is at capacity until space is available or queue is closed
+func (q *DiskQ[T]) PushBlocking(item T) error {
+	var data, err = q.codec.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for q.opt.capacity > 0 && q.lenLocked() >= q.opt.capacity && !q.closed {
+		q.condPub.Wait()
+	}
+	if q.closed {
+		return ErrClosed
+	}
+	return q.appendLocked(data)
+}
+
+// Pop removes, acks and returns the next item, see PopSeq
+func (q *DiskQ[T]) Pop() (T, error) {
+	var item, _, err = q.pop(true)
+	return item, err
+}
+
+// PopSeq removes and returns the next item with its sequence for Ack
+// Blocks if queue is empty until an item is available or queue is closed
+// If the item can not be decoded, it is acked and the error is returned.
+// If the record is broken, the rest of its segment is dropped and ErrCorrupted is returned, the next call goes on.
+func (q *DiskQ[T]) PopSeq() (T, uint64, error) {
+	return q.pop(q.opt.autoAck)
+}
+
+// pop removes and returns the next item, acks it if ack is true
+func (q *DiskQ[T]) pop(ack bool) (T, uint64, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var zero T
+	for q.readSeq == q.writeSeq && !q.closed {
+		q.condSub.Wait()
+	}
+	if q.closed {
+		return zero, 0, ErrClosed
+	}
+
+	var data, size, err = q.readLocked()
+	if errors.Is(err, ErrCorrupted) {
+		return zero, 0, q.skipCorruptedLocked(err)
+	}
+	if err != nil {
+		return zero, 0, err
+	}
+	var seq = q.readSeq
+	q.pos[seq] = recordPos{first: q.segments[q.readIdx].first, off: q.readOff}
+	q.readSeq++
+	q.readOff += size
+	q.condPub.Signal()
+
+	item, err := q.codec.Unmarshal(data)
+	if err != nil || ack {
+		var ackErr = q.ackLocked(seq)
+		if err == nil {
+			err = ackErr
+		}
+	}
+	if err != nil {
+		return zero, seq, err
+	}
+	return item, seq, nil
+}
+
+// Ack acknowledges a delivered item, sequences can be acked in any order
+func (q *DiskQ[T]) Ack(seq uint64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+	return q.ackLocked(seq)
+}
+
+// AckTo acknowledges all delivered items before seq
+func (q *DiskQ[T]) AckTo(seq uint64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+	if seq > q.readSeq {
+		return ErrInvalidAck
+	}
+	return q.ackToLocked(seq)
+}
+
+// InFlight returns the number of delivered but unacked items
+func (q *DiskQ[T]) InFlight() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return int(q.readSeq-q.ackSeq) - len(q.acked)
+}
+
+// Peek returns the next item without removing it
+// Returns zero value if the queue is empty or the item can not be decoded
+func (q *DiskQ[T]) Peek() T {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var zero T
+	if q.closed || q.readSeq == q.writeSeq {
+		return zero
+	}
+	var data, _, err = q.readLocked()
+	if err != nil {
+		return zero
+	}
+	item, err := q.codec.Unmarshal(data)
+	if err != nil {
+		return zero
+	}
+	return item
+}
+
+// Close writes checkpoint and closes files, unacked items are delivered again when reopened.
+// All subsequent operations will return ErrClosed
+func (q *DiskQ[T]) Close() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed {
+		return
+	}
+	q.closed = true
+	if q.checkpointSeq != q.ackSeq {
+		_ = q.writeCheckpoint()
+	}
+	q.closeFiles()
+	q.condSub.Broadcast()
+	q.condPub.Broadcast()
+}
+
+// Len returns the number of undelivered items
+func (q *DiskQ[T]) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.lenLocked()
+}
+
+// Cap returns the maximum number of undelivered items (0 means unlimited)
+func (q *DiskQ[T]) Cap() int {
+	return q.opt.capacity
+}
+
+// IsUnlimited returns true if the queue has unlimited capacity
+func (q *DiskQ[T]) IsUnlimited() bool {
+	return q.opt.capacity == 0
+}
+
+// IsClosed returns true if the queue is closed
+func (q *DiskQ[T]) IsClosed() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.closed
+}
+
+// IsFull returns true if the queue is at capacity (always false for unlimited capacity)
+func (q *DiskQ[T]) IsFull() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.opt.capacity == 0 {
+		return false
+	}
+	return q.lenLocked() >= q.opt.capacity
+}
+
+// IsEmpty returns true if there is no undelivered item
+func (q *DiskQ[T]) IsEmpty() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.lenLocked() == 0
+}
+
+// Reset drops all undelivered items and acks all in-flight items
+func (q *DiskQ[T]) Reset() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed {
+		return
+	}
+	if q.readSeq == q.writeSeq && q.ackSeq == q.readSeq {
+		return
+	}
+	// move reader to the end of the last segment
+	if q.switchReader(len(q.segments)-1) == nil {
+		q.readOff = q.writeSize
+		q.readSeq = q.writeSeq
+		_ = q.ackToLocked(q.readSeq)
+	}
+	q.condPub.Broadcast()
+}
+
+// ackToLocked acks all sequences before seq, lock must be held
+func (q *DiskQ[T]) ackToLocked(seq uint64) error {
+	for s := q.ackSeq; s < seq; s++ {
+		q.acked[s] = struct{}{}
+	}
+	return q.advanceLocked()
+}
+
+// lenLocked returns undelivered count, lock must be held
+func (q *DiskQ[T]) lenLocked() int {
+	return int(q.writeSeq - q.readSeq)
+}
+
+// open locks dir, loads checkpoint and segments, recovers the last segment then positions reader
+func (q *DiskQ[T]) open() error {
+	var err = os.MkdirAll(q.dir, 0o755)
+	if err != nil {
+		return err
+	}
+	q.dirLock, err = lockDir(q.dir)
+	if err != nil {
+		return err
+	}
+	var pos *recordPos
+	q.ackSeq, pos, err = q.readCheckpoint()
+	if err != nil {
+		return err
+	}
+	q.checkpointSeq = q.ackSeq
+	q.segments, err = q.listSegments()
+	if err != nil {
+		return err
+	}
+
+	if len(q.segments) == 0 {
+		q.writeSeq = q.ackSeq
+		return q.openReader(q.ackSeq, nil)
+	}
+
+	var last = q.segments[len(q.segments)-1]
+	count, size, err := recoverSegment(last.path)
+	if err != nil {
+		return err
+	}
+	q.writeSeq = last.first + count
+	q.writer, err = os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	q.writeSize = size
+
+	if q.ackSeq < q.segments[0].first {
+		q.ackSeq, pos = q.segments[0].first, nil
+	}
+	if q.ackSeq > q.writeSeq {
+		q.ackSeq, pos = q.writeSeq, nil
+	}
+	err = q.openReader(q.ackSeq, pos)
+	if err != nil {
+		return err
+	}
+	return q.removeAckedSegments()
+}
+
+// openReader positions reader at seq, it seeks to the checkpointed position if it's valid,
+// otherwise scans the segment of seq from its beginning
+func (q *DiskQ[T]) openReader(seq uint64, pos *recordPos) error {
+	if q.writer == nil {
+		var err = q.newSegment(seq)
+		if err != nil {
+			return err
+		}
+	}
+	if pos != nil && q.seekReader(seq, *pos) {
+		return nil
+	}
+
+	var idx = sort.Search(len(q.segments), func(i int) bool {
+		return q.segments[i].first > seq
+	}) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	var err = q.switchReader(idx)
+	if err != nil {
+		return err
+	}
+	q.readSeq = q.segments[idx].first
+	for q.readSeq < seq {
+		var _, size, rErr = q.readLocked()
+		if rErr != nil {
+			return rErr
+		}
+		q.readSeq++
+		q.readOff += size
+	}
+	return nil
+}
+
+// seekReader positions reader at the record position of seq, returns false if the position is not valid
+func (q *DiskQ[T]) seekReader(seq uint64, pos recordPos) bool {
+	var idx = sort.Search(len(q.segments), func(i int) bool {
+		return q.segments[i].first >= pos.first
+	})
+	if idx == len(q.segments) || q.segments[idx].first != pos.first || seq < pos.first ||
+		(seq == pos.first && pos.off != 0) || pos.off < 0 || pos.off > q.segmentSizeLocked(idx) ||
+		(idx+1 < len(q.segments) && seq > q.segments[idx+1].first) {
+		return false
+	}
+	if q.switchReader(idx) != nil {
+		return false
+	}
+	q.readSeq, q.readOff = seq, pos.off
+	if seq == q.writeSeq {
+		return true
+	}
+	// the record at position must be valid
+	var _, _, err = q.readLocked()
+	return err == nil
+}
+
+// switchReader opens segment idx for reading from its beginning
+func (q *DiskQ[T]) switchReader(idx int) error {
+	if q.reader != nil {
+		_ = q.reader.Close()
+		q.reader = nil
+	}
+	var f, err = os.Open(q.segments[idx].path)
+	if err != nil {
+		return err
+	}
+	q.reader, q.readIdx, q.readOff = f, idx, 0
+	return nil
+}
+
+// readLocked reads the record at read position without moving it, lock must be held
+// Returns payload and record size.
+func (q *DiskQ[T]) readLocked() ([]byte, int64, error) {
+	// move to next segment if current one is exhausted
+	for q.readIdx+1 < len(q.segments) && q.readSeq >= q.segments[q.readIdx+1].first {
+		var err = q.switchReader(q.readIdx + 1)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var header [recordHeaderSize]byte
+	var _, err = q.reader.ReadAt(header[:], q.readOff)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+	var size = binary.LittleEndian.Uint32(header[:4])
+	// length is not covered by crc, check it before allocating
+	if int64(size) > q.segmentSizeLocked(q.readIdx)-q.readOff-recordHeaderSize {
+		return nil, 0, fmt.Errorf("%w: record length %d exceeds segment", ErrCorrupted, size)
+	}
+	var data = make([]byte, size)
+	_, err = q.reader.ReadAt(data, q.readOff+recordHeaderSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+	if crc32.ChecksumIEEE(data) != binary.LittleEndian.Uint32(header[4:]) {
+		return nil, 0, ErrCorrupted
+	}
+	return data, recordHeaderSize + int64(size), nil
+}
+
+// skipCorruptedLocked drops the rest of the segment being read from the broken record, lock must be held.
+// Dropped items of a sealed segment are acked, the last segment is truncated to the broken record.
+func (q *DiskQ[T]) skipCorruptedLocked(cause error) error {
+	var from, dropped = q.readSeq, uint64(0)
+	if q.readIdx+1 < len(q.segments) {
+		var next = q.segments[q.readIdx+1].first
+		dropped = next - q.readSeq
+		for ; q.readSeq < next; q.readSeq++ {
+			q.acked[q.readSeq] = struct{}{}
+		}
+		// read position is checkpointed, so move reader to the next segment
+		var err = q.switchReader(q.readIdx + 1)
+		if err == nil {
+			err = q.advanceLocked()
+		}
+		if err != nil {
+			return errors.Join(cause, err)
+		}
+	} else {
+		var err = q.writer.Truncate(q.readOff)
+		if err != nil {
+			return errors.Join(cause, err)
+		}
+		dropped = q.writeSeq - q.readSeq
+		q.writeSize, q.writeSeq = q.readOff, q.readSeq
+	}
+	q.condPub.Broadcast()
+	return fmt.Errorf("%w, %d items from sequence %d are dropped", cause, dropped, from)
+}
+
+// appendLocked writes a record to the last segment, lock must be held
+func (q *DiskQ[T]) appendLocked(data []byte) error {
+	if q.writeSize >= q.opt.segmentSize && q.writeSize > 0 {
+		var err = q.newSegment(q.writeSeq)
+		if err != nil {
+			return err
+		}
+	}
+
+	var buf = make([]byte, recordHeaderSize+len(data))
+	binary.LittleEndian.PutUint32(buf[:4], uint32(len(data)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(data))
+	copy(buf[recordHeaderSize:], data)
+
+	var n, err = q.writer.Write(buf)
+	if err == nil && q.opt.syncWrite {
+		err = q.writer.Sync()
+	}
+	if err != nil {
+		if n > 0 {
+			return errors.Join(err, q.dropTailLocked())
+		}
+		return err
+	}
+	q.writeSize += int64(n)
+	q.writeSeq++
+	q.condSub.Signal()
+	return nil
+}
+
+// dropTailLocked removes the bytes written by a failed append, lock must be held.
+// If the segment can not be truncated, a new segment is created so the following records are aligned,
+// the broken tail of the sealed segment is never read.
+func (q *DiskQ[T]) dropTailLocked() error {
+	var err = q.writer.Truncate(q.writeSize)
+	if err == nil {
+		return nil
+	}
+	return errors.Join(err, q.newSegment(q.writeSeq))
+}
+
+// newSegment closes current writer and creates a segment starting at first
+func (q *DiskQ[T]) newSegment(first uint64) error {
+	if q.writer != nil {
+		var err = q.writer.Sync()
+		if err != nil {
+			return err
+		}
+		_ = q.writer.Close()
+		q.writer = nil
+		q.segments[len(q.segments)-1].size = q.writeSize
+	}
+	var path = filepath.Join(q.dir, fmt.Sprintf("%020d%s", first, segmentExt))
+	var f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	q.writer, q.writeSize = f, 0
+	q.segments = append(q.segments, segment{first: first, path: path})
+	return syncDir(q.dir)
+}
+
+// ackLocked marks seq as acked, lock must be held
+func (q *DiskQ[T]) ackLocked(seq uint64) error {
+	if seq < q.ackSeq || seq >= q.readSeq {
+		return ErrInvalidAck
+	}
+	if _, ok := q.acked[seq]; ok {
+		return ErrInvalidAck
+	}
+	q.acked[seq] = struct{}{}
+	return q.advanceLocked()
+}
+
+// advanceLocked moves ackSeq forward, writes checkpoint and removes acked segments
+func (q *DiskQ[T]) advanceLocked() error {
+	var advanced bool
+	for {
+		if _, ok := q.acked[q.ackSeq]; !ok {
+			break
+		}
+		delete(q.acked, q.ackSeq)
+		delete(q.pos, q.ackSeq)
+		q.ackSeq++
+		advanced = true
+	}
+	if !advanced || q.ackSeq-q.checkpointSeq < uint64(q.opt.checkpointEvery) {
+		return nil
+	}
+	var err = q.writeCheckpoint()
+	if err != nil {
+		return err
+	}
+	return q.removeAckedSegments()
+}
+
+// removeAckedSegments removes segments before reader whose items are all acked
+func (q *DiskQ[T]) removeAckedSegments() error {
+	var n int
+	for n+1 < len(q.segments) && q.segments[n+1].first <= q.ackSeq && n < q.readIdx {
+		var err = os.Remove(q.segments[n].path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		n++
+	}
+	if n > 0 {
+		q.segments = q.segments[n:]
+		q.readIdx -= n
+	}
+	return nil
+}
+
+// readCheckpoint reads acked sequence and its record position from checkpoint file,
+// position is nil if checkpoint is written by old version
+func (q *DiskQ[T]) readCheckpoint() (uint64, *recordPos, error) {
+	var data, err = os.ReadFile(filepath.Join(q.dir, checkpointFile))
+	if os.IsNotExist(err) {
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	var n = len(data) - 4
+	if (len(data) != checkpointSize && len(data) != legacyCheckpointSize) ||
+		crc32.ChecksumIEEE(data[:n]) != binary.LittleEndian.Uint32(data[n:]) {
+		return 0, nil, fmt.Errorf("%w: checkpoint", ErrCorrupted)
+	}
+	var seq = binary.LittleEndian.Uint64(data[:8])
+	if len(data) == legacyCheckpointSize {
+		return seq, nil, nil
+	}
+	return seq, &recordPos{
+		first: binary.LittleEndian.Uint64(data[8:16]),
+		off:   int64(binary.LittleEndian.Uint64(data[16:24])),
+	}, nil
+}
+
+// writeCheckpoint writes acked sequence and its record position to checkpoint file atomically
+func (q *DiskQ[T]) writeCheckpoint() error {
+	var pos = q.ackPosLocked()
+	var data [checkpointSize]byte
+	binary.LittleEndian.PutUint64(data[:8], q.ackSeq)
+	binary.LittleEndian.PutUint64(data[8:16], pos.first)
+	binary.LittleEndian.PutUint64(data[16:24], uint64(pos.off))
+	binary.LittleEndian.PutUint32(data[24:], crc32.ChecksumIEEE(data[:24]))
+
+	var path = filepath.Join(q.dir, checkpointFile)
+	var tmp = path + ".tmp"
+	var f, err = os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data[:])
+	if err == nil {
+		err = f.Sync()
+	}
+	var cErr = f.Close()
+	if err == nil {
+		err = cErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return err
+	}
+	q.checkpointSeq = q.ackSeq
+	return nil
+}
+
+// ackPosLocked returns record position of ackSeq, it's delivered or it's the read position
+func (q *DiskQ[T]) ackPosLocked() recordPos {
+	if pos, ok := q.pos[q.ackSeq]; ok {
+		return pos
+	}
+	return recordPos{first: q.segments[q.readIdx].first, off: q.readOff}
+}
+
+// segmentSizeLocked returns size of segment idx, lock must be held
+func (q *DiskQ[T]) segmentSizeLocked(idx int) int64 {
+	if idx == len(q.segments)-1 {
+		return q.writeSize
+	}
+	return q.segments[idx].size
+}
+
+// listSegments lists segment files sorted by first sequence
+func (q *DiskQ[T]) listSegments() ([]segment, error) {
+	var entries, err = os.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []segment
+	for _, entry := range entries {
+		var name = entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		first, pErr := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if pErr != nil {
+			continue
+		}
+		info, iErr := entry.Info()
+		if iErr != nil {
+			return nil, iErr
+		}
+		segments = append(segments, segment{first: first, path: filepath.Join(q.dir, name), size: info.Size()})
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].first < segments[j].first
+	})
+	return segments, nil
+}
+
+// closeFiles closes reader and writer, then unlocks dir
+func (q *DiskQ[T]) closeFiles() {
+	if q.reader != nil {
+		_ = q.reader.Close()
+		q.reader = nil
+	}
+	if q.writer != nil {
+		_ = q.writer.Sync()
+		_ = q.writer.Close()
+		q.writer = nil
+	}
+	if q.dirLock != nil {
+		_ = q.dirLock.Close()
+		q.dirLock = nil
+	}
+}
+
+// recoverSegment counts valid records and truncates broken tail of a segment file
+func recoverSegment(path string) (count uint64, size int64, err error) {
+	var f *os.File
+	f, err = os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		var cErr = f.Close()
+		if err == nil {
+			err = cErr
+		}
+	}()
+
+	var info os.FileInfo
+	info, err = f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var header [recordHeaderSize]byte
+	for {
+		_, err = f.ReadAt(header[:], size)
+		if err != nil {
+			break
+		}
+		var n = binary.LittleEndian.Uint32(header[:4])
+		if size+recordHeaderSize+int64(n) > info.Size() {
+			break
+		}
+		var data = make([]byte, n)
+		_, err = f.ReadAt(data, size+recordHeaderSize)
+		if err != nil || crc32.ChecksumIEEE(data) != binary.LittleEndian.Uint32(header[4:]) {
+			break
+		}
+		size += recordHeaderSize + int64(n)
+		count++
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, 0, err
+	}
+	err = nil
+
+	if info.Size() != size {
+		err = f.Truncate(size)
+		if err != nil {
+			return 0, 0, err
+		}
+		err = f.Sync()
+	}
+	return count, size, err
+}
+
+// syncDir makes file creation durable
+func syncDir(dir string) error {
+	var d, err = os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	var cErr = d.Close()
+	if err == nil {
+		err = cErr
+	}
+	return err
+}
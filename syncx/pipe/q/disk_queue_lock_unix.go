@@ -0,0 +1,27 @@
+//go:build unix
+
+package q
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockDir locks the lock file of dir exclusively, the lock is released when the file is closed
+func lockDir(dir string) (*os.File, error) {
+	var f, err = os.OpenFile(filepath.Join(dir, lockFile), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		_ = f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrDirLocked
+		}
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,375 @@
+package q
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type diskItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func openTestDiskQ(t *testing.T, dir string, opts ...DiskQOption) *DiskQ[diskItem] {
+	t.Helper()
+	var dq, err = OpenDiskQ[diskItem](dir, nil, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dq
+}
+
+func TestDiskQ_AckAndRedeliver(t *testing.T) {
+	var dir = t.TempDir()
+	var dq = openTestDiskQ(t, dir)
+	for i := 0; i < 5; i++ {
+		if err := dq.Push(diskItem{ID: i, Name: "item"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dq.Len() != 5 || dq.Peek().ID != 0 {
+		t.Fatalf("unexpected len %d or peek %+v", dq.Len(), dq.Peek())
+	}
+
+	// pop 0,1,2 ack 0 and 2
+	var seqs []uint64
+	for i := 0; i < 3; i++ {
+		var item, seq, err = dq.PopSeq()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.ID != i {
+			t.Fatalf("expected %d, got %d", i, item.ID)
+		}
+		seqs = append(seqs, seq)
+	}
+	if err := dq.Ack(seqs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := dq.Ack(seqs[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := dq.Ack(seqs[2]); !errors.Is(err, ErrInvalidAck) {
+		t.Errorf("expected ErrInvalidAck, got %v", err)
+	}
+	if err := dq.Ack(100); !errors.Is(err, ErrInvalidAck) {
+		t.Errorf("expected ErrInvalidAck, got %v", err)
+	}
+	if dq.InFlight() != 1 {
+		t.Errorf("expected 1 in flight, got %d", dq.InFlight())
+	}
+	dq.Close()
+	if _, err := dq.Pop(); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+
+	// 1 is not acked, delivered again, 2 is acked but after 1, delivered again too
+	dq = openTestDiskQ(t, dir)
+	defer dq.Close()
+	if dq.Len() != 4 {
+		t.Fatalf("expected 4 items after reopen, got %d", dq.Len())
+	}
+	for _, expected := range []int{1, 2, 3, 4} {
+		var item, err = dq.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.ID != expected {
+			t.Errorf("expected %d, got %d", expected, item.ID)
+		}
+	}
+}
+
+func TestDiskQ_SegmentsAndRecovery(t *testing.T) {
+	var dir = t.TempDir()
+	var dq = openTestDiskQ(t, dir, WithSegmentSize(64))
+	for i := 0; i < 20; i++ {
+		if err := dq.PushBlocking(diskItem{ID: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var segments, _ = filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if len(segments) < 3 {
+		t.Fatalf("expected multiple segments, got %d", len(segments))
+	}
+	for i := 0; i < 10; i++ {
+		var item, seq, err = dq.PopSeq()
+		if err != nil || item.ID != i {
+			t.Fatalf("unexpected pop %+v %v", item, err)
+		}
+		if err = dq.Ack(seq); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var left, _ = filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if len(left) >= len(segments) {
+		t.Errorf("acked segments should be removed, before %d after %d", len(segments), len(left))
+	}
+	dq.Close()
+
+	// simulate a crash in the middle of writing a record
+	var last = left[len(left)-1]
+	var f, err = os.OpenFile(last, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write([]byte{100, 0, 0, 0, 1, 2})
+	_ = f.Close()
+
+	dq = openTestDiskQ(t, dir, WithSegmentSize(64), WithAutoAck())
+	defer dq.Close()
+	if dq.Len() != 10 {
+		t.Fatalf("expected 10 items, got %d", dq.Len())
+	}
+	_ = dq.Push(diskItem{ID: 20})
+	for i := 10; i <= 20; i++ {
+		var item, pErr = dq.Pop()
+		if pErr != nil || item.ID != i {
+			t.Fatalf("unexpected pop %+v %v", item, pErr)
+		}
+	}
+	if dq.InFlight() != 0 {
+		t.Errorf("auto ack should leave nothing in flight, got %d", dq.InFlight())
+	}
+}
+
+func TestDiskQ_BlockingPop(t *testing.T) {
+	var dq = openTestDiskQ(t, t.TempDir(), WithDiskQCap(1))
+	var got = make(chan diskItem, 1)
+	go func() {
+		var item, _ = dq.Pop()
+		got <- item
+	}()
+	time.Sleep(10 * time.Millisecond)
+	_ = dq.Push(diskItem{ID: 7})
+	select {
+	case item := <-got:
+		if item.ID != 7 {
+			t.Errorf("expected 7, got %d", item.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pop not woken up")
+	}
+
+	_ = dq.Push(diskItem{ID: 8})
+	if err := dq.Push(diskItem{ID: 9}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+	dq.Reset()
+	if !dq.IsEmpty() || dq.InFlight() != 0 {
+		t.Errorf("expected empty queue after reset")
+	}
+	dq.Close()
+	if err := dq.Push(diskItem{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestDiskQ_PopAcks(t *testing.T) {
+	var dir = t.TempDir()
+	var dq = openTestDiskQ(t, dir, WithSegmentSize(64))
+	for i := 0; i < 10; i++ {
+		_ = dq.Push(diskItem{ID: i})
+	}
+	var segments, _ = filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	for i := 0; i < 8; i++ {
+		if item, err := dq.Pop(); err != nil || item.ID != i {
+			t.Fatalf("unexpected pop %+v %v", item, err)
+		}
+	}
+	if dq.InFlight() != 0 {
+		t.Errorf("pop should ack, got %d in flight", dq.InFlight())
+	}
+	var left, _ = filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if len(left) >= len(segments) {
+		t.Errorf("acked segments should be removed, before %d after %d", len(segments), len(left))
+	}
+
+	// the dir is locked while the queue is open
+	if _, err := OpenDiskQ[diskItem](dir, nil); !errors.Is(err, ErrDirLocked) {
+		t.Errorf("expected ErrDirLocked, got %v", err)
+	}
+	dq.Close()
+
+	dq = openTestDiskQ(t, dir)
+	defer dq.Close()
+	if item, err := dq.Pop(); err != nil || item.ID != 8 {
+		t.Fatalf("popped items should not be delivered again, got %+v %v", item, err)
+	}
+}
+
+func TestDiskQ_SkipCorrupted(t *testing.T) {
+	var dir = t.TempDir()
+	var dq = openTestDiskQ(t, dir, WithSegmentSize(64))
+	for i := 0; i < 10; i++ {
+		_ = dq.Push(diskItem{ID: i})
+	}
+	dq.Close()
+
+	// break the payload of the first record of a sealed segment, the rest of the segment is dropped
+	var segments, _ = filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if len(segments) < 3 {
+		t.Fatalf("expected multiple segments, got %d", len(segments))
+	}
+	corruptAt(t, segments[0], recordHeaderSize)
+	var second = dq.segments[1].first
+
+	dq = openTestDiskQ(t, dir, WithSegmentSize(64))
+	defer dq.Close()
+	if _, _, err := dq.PopSeq(); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected ErrCorrupted, got %v", err)
+	}
+	var item, seq, err = dq.PopSeq()
+	if err != nil || item.ID != int(second) {
+		t.Fatalf("expected %d after corrupted segment, got %+v %v", second, item, err)
+	}
+	if err = dq.Ack(seq); err != nil {
+		t.Fatal(err)
+	}
+	if dq.InFlight() != 0 {
+		t.Errorf("dropped items should be acked, got %d in flight", dq.InFlight())
+	}
+
+	// break a record of the last segment, it's truncated and new items go on
+	for dq.Len() > 0 {
+		if _, err = dq.Pop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = dq.Push(diskItem{ID: 10})
+	_ = dq.Push(diskItem{ID: 11})
+	var data, _ = json.Marshal(diskItem{ID: 10})
+	corruptAt(t, dq.segments[len(dq.segments)-1].path, dq.readOff+recordHeaderSize+int64(len(data))+recordHeaderSize)
+	if item, err = dq.Pop(); err != nil || item.ID != 10 {
+		t.Fatalf("unexpected pop %+v %v", item, err)
+	}
+	if _, err = dq.Pop(); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected ErrCorrupted, got %v", err)
+	}
+	if !dq.IsEmpty() {
+		t.Fatalf("expected empty queue, got %d", dq.Len())
+	}
+	_ = dq.Push(diskItem{ID: 12})
+	if item, err = dq.Pop(); err != nil || item.ID != 12 {
+		t.Fatalf("unexpected pop %+v %v", item, err)
+	}
+}
+
+func TestDiskQ_CorruptedLength(t *testing.T) {
+	var dir = t.TempDir()
+	var dq = openTestDiskQ(t, dir, WithSegmentSize(64))
+	for i := 0; i < 10; i++ {
+		_ = dq.Push(diskItem{ID: i})
+	}
+	dq.Close()
+
+	// break the highest byte of length of the first record in a sealed segment, it must not be allocated
+	var segments, _ = filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	corruptAt(t, segments[0], 3)
+	var second = dq.segments[1].first
+
+	dq = openTestDiskQ(t, dir, WithSegmentSize(64))
+	defer dq.Close()
+	if _, _, err := dq.PopSeq(); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected ErrCorrupted, got %v", err)
+	}
+	if item, err := dq.Pop(); err != nil || item.ID != int(second) {
+		t.Fatalf("expected %d after corrupted segment, got %+v %v", second, item, err)
+	}
+}
+
+func TestDiskQ_CheckpointPosition(t *testing.T) {
+	var dir = t.TempDir()
+	var dq = openTestDiskQ(t, dir, WithSegmentSize(64))
+	for i := 0; i < 10; i++ {
+		_ = dq.Push(diskItem{ID: i})
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := dq.Pop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// item 5 is in a sealed segment after its first record
+	var seg = dq.segments[dq.readIdx]
+	if dq.readIdx == len(dq.segments)-1 || seg.first == 5 {
+		t.Fatalf("unexpected segment %+v", seg)
+	}
+	dq.Close()
+
+	// records before checkpointed position are not read again, break the first one
+	corruptAt(t, seg.path, recordHeaderSize)
+	dq = openTestDiskQ(t, dir, WithSegmentSize(64))
+	if item, err := dq.Pop(); err != nil || item.ID != 5 {
+		t.Fatalf("expected 5, got %+v %v", item, err)
+	}
+	dq.Close()
+
+	// checkpoint without position, the segment is scanned from its first record
+	var legacy [legacyCheckpointSize]byte
+	binary.LittleEndian.PutUint64(legacy[:8], 5)
+	binary.LittleEndian.PutUint32(legacy[8:], crc32.ChecksumIEEE(legacy[:8]))
+	if err := os.WriteFile(filepath.Join(dir, checkpointFile), legacy[:], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenDiskQ[diskItem](dir, nil, WithSegmentSize(64)); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected ErrCorrupted when scanning, got %v", err)
+	}
+	corruptAt(t, seg.path, recordHeaderSize)
+	dq = openTestDiskQ(t, dir, WithSegmentSize(64))
+	defer dq.Close()
+	if item, err := dq.Pop(); err != nil || item.ID != 5 {
+		t.Fatalf("expected 5, got %+v %v", item, err)
+	}
+}
+
+func TestDiskQ_DropFailedTail(t *testing.T) {
+	var dq = openTestDiskQ(t, t.TempDir())
+	defer dq.Close()
+	_ = dq.Push(diskItem{ID: 1})
+
+	// simulate a partial write
+	dq.lock.Lock()
+	_, _ = dq.writer.Write([]byte{100, 0, 0})
+	var err = dq.dropTailLocked()
+	dq.lock.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = dq.Push(diskItem{ID: 2})
+	for _, expected := range []int{1, 2} {
+		var item, pErr = dq.Pop()
+		if pErr != nil || item.ID != expected {
+			t.Fatalf("unexpected pop %+v %v", item, pErr)
+		}
+	}
+}
+
+// corruptAt flips a byte of file at offset
+func corruptAt(t *testing.T, path string, off int64) {
+	t.Helper()
+	var f, err = os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var b [1]byte
+	if _, err = f.ReadAt(b[:], off); err != nil {
+		t.Fatal(err)
+	}
+	b[0] ^= 0xff
+	if _, err = f.WriteAt(b[:], off); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// make sure DiskQ implements Queue
+var _ Queue[int] = (*DiskQ[int])(nil)
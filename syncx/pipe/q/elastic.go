@@ -0,0 +1,487 @@
+package q
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pinealctx/neptune/errorx"
+	"github.com/pinealctx/neptune/ulog"
+)
+
+const (
+	// DefaultElasticIdleTimeout is how long the pool keeps more than min workers idle before retiring one
+	DefaultElasticIdleTimeout = 30 * time.Second
+)
+
+// elasticOption holds the configuration of ElasticTaskProcessor
+type elasticOption struct {
+	minWorkers  int
+	maxWorkers  int
+	queueCap    int
+	idleTimeout time.Duration
+	taskTimeout time.Duration
+}
+
+// ElasticOption configures an ElasticTaskProcessor
+type ElasticOption func(o *elasticOption)
+
+// WithMinWorkers sets the number of workers kept alive even when there is nothing to do. Default is 0.
+func WithMinWorkers(n int) ElasticOption {
+	return func(o *elasticOption) {
+		o.minWorkers = n
+	}
+}
+
+// WithMaxWorkers sets the upper bound of workers. Default is 8.
+func WithMaxWorkers(n int) ElasticOption {
+	return func(o *elasticOption) {
+		o.maxWorkers = n
+	}
+}
+
+// WithQueueCap sets the task queue capacity, 0 means unlimited. Default is 0.
+func WithQueueCap(n int) ElasticOption {
+	return func(o *elasticOption) {
+		o.queueCap = n
+	}
+}
+
+// WithIdleTimeout sets how long workers above min may stay idle before being retired one by one.
+func WithIdleTimeout(d time.Duration) ElasticOption {
+	return func(o *elasticOption) {
+		o.idleTimeout = d
+	}
+}
+
+// WithTaskTimeout sets a timeout for each task, the task context is cancelled once it expires.
+// 0 means no timeout other than the deadline of the submitting context.
+func WithTaskTimeout(d time.Duration) ElasticOption {
+	return func(o *elasticOption) {
+		o.taskTimeout = d
+	}
+}
+
+// Future is the pending result of a task submitted by SubmitFunc.
+type Future[R any] struct {
+	done chan struct{}
+	r    R
+	err  error
+}
+
+// newFuture creates an unresolved future
+func newFuture[R any]() *Future[R] {
+	return &Future[R]{done: make(chan struct{})}
+}
+
+// set resolves the future, must be called only once
+func (f *Future[R]) set(r R, err error) {
+	f.r, f.err = r, err
+	close(f.done)
+}
+
+// Done returns a channel closed when the result is ready
+func (f *Future[R]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result waits until the task is done then returns its result
+func (f *Future[R]) Result() (R, error) {
+	<-f.done
+	return f.r, f.err
+}
+
+// Wait waits until the task is done or ctx is done.
+// When ctx is done first the task is not cancelled, only the waiting is abandoned.
+func (f *Future[R]) Wait(ctx context.Context) (R, error) {
+	select {
+	case <-f.done:
+		return f.r, f.err
+	case <-ctx.Done():
+		var zero R
+		return zero, ctx.Err()
+	}
+}
+
+// ElasticStats is a snapshot of ElasticTaskProcessor metrics.
+type ElasticStats struct {
+	// Workers is the number of live workers
+	Workers int
+	// IdleWorkers is the number of workers waiting for tasks
+	IdleWorkers int
+	// QueueLen is the number of tasks waiting in queue
+	QueueLen int
+
+	// Submitted is the number of accepted tasks
+	Submitted uint64
+	// Completed is the number of tasks run without error
+	Completed uint64
+	// Failed is the number of tasks returned error or panicked
+	Failed uint64
+	// Canceled is the number of tasks whose context was done before running, they are not run
+	Canceled uint64
+
+	// TotalWait is the accumulated time tasks spent in queue
+	TotalWait time.Duration
+	// MaxWait is the longest time a task spent in queue
+	MaxWait time.Duration
+	// TotalRun is the accumulated running time of tasks
+	TotalRun time.Duration
+}
+
+// AvgWait returns average queue latency of dequeued tasks
+func (s ElasticStats) AvgWait() time.Duration {
+	var n = s.Completed + s.Failed + s.Canceled
+	if n == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(n)
+}
+
+// AvgRun returns average running time of run tasks
+func (s ElasticStats) AvgRun() time.Duration {
+	var n = s.Completed + s.Failed
+	if n == 0 {
+		return 0
+	}
+	return s.TotalRun / time.Duration(n)
+}
+
+// elasticTask is a queued task
+type elasticTask struct {
+	ctx       context.Context
+	enqueueAt time.Time
+	// do runs the task and resolves its future
+	do func(ctx context.Context) error
+	// abort resolves the future without running
+	abort func(err error)
+}
+
+// ElasticTaskProcessor is a task processor whose worker count grows and shrinks with load.
+//
+// A new worker is started when a task is submitted while no worker is idle, up to max workers.
+// When the queue stays empty and workers stay idle longer than idle timeout, workers above min
+// are retired one by one.
+//
+// Compare with SimpleTaskProcessor:
+//   - Tasks can return result and error through Future (see SubmitFunc)
+//   - Each task runs with a context, which carries the submitting context deadline and task timeout
+//   - Tasks whose context is done before running are skipped
+//   - Shutdown drains queued tasks before workers exit
+//
+// Example usage:
+//
+//	processor := NewElasticTaskProcessor(WithMinWorkers(1), WithMaxWorkers(16))
+//	future, err := SubmitFunc(processor, ctx, func(ctx context.Context) (int, error) {
+//		return 1, nil
+//	})
+//	r, err := future.Wait(ctx)
+//	processor.Shutdown()
+type ElasticTaskProcessor struct {
+	opt elasticOption
+
+	// lock protects fields below
+	lock sync.Mutex
+	cond sync.Cond
+	// items holds queued *elasticTask
+	items *list.List
+	// workers is the number of live workers
+	workers int
+	// idle is the number of workers waiting in cond
+	idle int
+	// retire is the number of workers asked to quit
+	retire int
+	// idleSince records when the pool became idle with more than min workers
+	idleSince time.Time
+	closed    bool
+	// done is closed when shutdown and all workers exit
+	done chan struct{}
+
+	submitted atomic.Uint64
+	completed atomic.Uint64
+	failed    atomic.Uint64
+	canceled  atomic.Uint64
+	totalWait atomic.Int64
+	maxWait   atomic.Int64
+	totalRun  atomic.Int64
+}
+
+// NewElasticTaskProcessor creates and starts an elastic task processor.
+// min workers are started immediately.
+func NewElasticTaskProcessor(opts ...ElasticOption) *ElasticTaskProcessor {
+	var o = elasticOption{
+		maxWorkers:  8,
+		idleTimeout: DefaultElasticIdleTimeout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.minWorkers < 0 {
+		o.minWorkers = 0
+	}
+	if o.maxWorkers < o.minWorkers {
+		o.maxWorkers = o.minWorkers
+	}
+	if o.maxWorkers == 0 {
+		o.maxWorkers = 1
+	}
+	if o.idleTimeout <= 0 {
+		o.idleTimeout = DefaultElasticIdleTimeout
+	}
+
+	var x = &ElasticTaskProcessor{
+		opt:   o,
+		items: list.New(),
+		done:  make(chan struct{}),
+	}
+	x.cond.L = &x.lock
+
+	x.lock.Lock()
+	for i := 0; i < o.minWorkers; i++ {
+		x.spawnLocked()
+	}
+	x.lock.Unlock()
+
+	go x.scaleLoop()
+	return x
+}
+
+// Submit adds an ITaskItem, it is run with panic recovery like SimpleTaskProcessor.
+// Returns ErrClosed if the processor has been shut down, ErrQueueFull if queue is at capacity.
+func (x *ElasticTaskProcessor) Submit(task ITaskItem) error {
+	return x.push(&elasticTask{
+		ctx: context.Background(),
+		do: func(_ context.Context) error {
+			return errorx.SafeRun(task.Do)
+		},
+		abort: func(error) {},
+	})
+}
+
+// SubmitFunc submits a function to the processor and returns a future of its result.
+// fn is called with a context derived from ctx and the task timeout,
+// if ctx is done before the task is run, fn is not called and the future is resolved with ctx error.
+// A panic in fn is returned as *errorx.PanicError.
+func SubmitFunc[R any](x *ElasticTaskProcessor, ctx context.Context,
+	fn func(ctx context.Context) (R, error)) (*Future[R], error) {
+	var f = newFuture[R]()
+	var err = x.push(&elasticTask{
+		ctx: ctx,
+		do: func(ctx context.Context) (err error) {
+			var r R
+			defer func() {
+				f.set(r, err)
+			}()
+			defer errorx.Recover(&err)
+			r, err = fn(ctx)
+			return err
+		},
+		abort: func(err error) {
+			var zero R
+			f.set(zero, err)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Shutdown stops accepting new tasks. Queued tasks are still run, workers exit after the queue is drained.
+// It is safe to call Shutdown multiple times.
+func (x *ElasticTaskProcessor) Shutdown() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.closed {
+		return
+	}
+	x.closed = true
+	x.cond.Broadcast()
+	if x.workers == 0 {
+		close(x.done)
+	}
+}
+
+// Wait waits until all workers exit after Shutdown
+func (x *ElasticTaskProcessor) Wait(ctx context.Context) error {
+	select {
+	case <-x.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of metrics
+func (x *ElasticTaskProcessor) Stats() ElasticStats {
+	x.lock.Lock()
+	var s = ElasticStats{
+		Workers:     x.workers,
+		IdleWorkers: x.idle,
+		QueueLen:    x.items.Len(),
+	}
+	x.lock.Unlock()
+
+	s.Submitted = x.submitted.Load()
+	s.Completed = x.completed.Load()
+	s.Failed = x.failed.Load()
+	s.Canceled = x.canceled.Load()
+	s.TotalWait = time.Duration(x.totalWait.Load())
+	s.MaxWait = time.Duration(x.maxWait.Load())
+	s.TotalRun = time.Duration(x.totalRun.Load())
+	return s
+}
+
+// push adds a task then wakes up or starts a worker
+func (x *ElasticTaskProcessor) push(t *elasticTask) error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	if x.closed {
+		return ErrClosed
+	}
+	if x.opt.queueCap > 0 && x.items.Len() >= x.opt.queueCap {
+		return ErrQueueFull
+	}
+	t.enqueueAt = time.Now()
+	x.items.PushBack(t)
+	x.submitted.Add(1)
+
+	if x.idle > 0 {
+		x.cond.Signal()
+	}
+	//a signaled worker is counted as idle until it gets the lock back, so scale up by backlog
+	if x.items.Len() > x.idle && x.workers < x.opt.maxWorkers {
+		x.spawnLocked()
+	}
+	return nil
+}
+
+// spawnLocked starts a worker, lock must be held
+func (x *ElasticTaskProcessor) spawnLocked() {
+	x.workers++
+	go x.runWorker()
+}
+
+// runWorker pops and runs tasks until it is retired or the processor is shut down and drained
+func (x *ElasticTaskProcessor) runWorker() {
+	for {
+		var t, ok = x.pop()
+		if !ok {
+			return
+		}
+		x.runTask(t)
+	}
+}
+
+// pop waits for a task, returns false if the worker should exit
+func (x *ElasticTaskProcessor) pop() (*elasticTask, bool) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	for x.items.Len() == 0 {
+		if x.closed || (x.retire > 0 && x.workers > x.opt.minWorkers) {
+			if x.retire > 0 {
+				x.retire--
+			}
+			x.workers--
+			if x.closed && x.workers == 0 {
+				close(x.done)
+			}
+			return nil, false
+		}
+		x.idle++
+		x.cond.Wait()
+		x.idle--
+	}
+
+	var front = x.items.Front()
+	x.items.Remove(front)
+	// nolint : forcetypeassert // I know the type is exactly here
+	return front.Value.(*elasticTask), true
+}
+
+// runTask runs a task with its context and records metrics
+func (x *ElasticTaskProcessor) runTask(t *elasticTask) {
+	var start = time.Now()
+	var wait = start.Sub(t.enqueueAt)
+	x.totalWait.Add(int64(wait))
+	for {
+		var old = x.maxWait.Load()
+		if int64(wait) <= old || x.maxWait.CompareAndSwap(old, int64(wait)) {
+			break
+		}
+	}
+
+	if err := t.ctx.Err(); err != nil {
+		x.canceled.Add(1)
+		t.abort(err)
+		return
+	}
+
+	var ctx, cancel = t.ctx, context.CancelFunc(nil)
+	if x.opt.taskTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, x.opt.taskTimeout)
+	}
+	var err = t.do(ctx)
+	if cancel != nil {
+		cancel()
+	}
+
+	x.totalRun.Add(int64(time.Since(start)))
+	if err != nil {
+		x.failed.Add(1)
+		var pe *errorx.PanicError
+		if errorx.As(err, &pe) {
+			ulog.Error("ElasticTaskProcessor worker panic", ulog.ErrorObj(err))
+		}
+		return
+	}
+	x.completed.Add(1)
+}
+
+// scaleLoop retires idle workers above min periodically until shutdown
+func (x *ElasticTaskProcessor) scaleLoop() {
+	var interval = x.opt.idleTimeout / 2
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-x.done:
+			return
+		case now := <-ticker.C:
+			if !x.scaleDown(now) {
+				return
+			}
+		}
+	}
+}
+
+// scaleDown asks one idle worker to quit if the pool has been idle long enough.
+// Returns false if the processor is closed.
+func (x *ElasticTaskProcessor) scaleDown(now time.Time) bool {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	if x.closed {
+		return false
+	}
+	if x.items.Len() > 0 || x.idle == 0 || x.workers <= x.opt.minWorkers {
+		x.idleSince = time.Time{}
+		x.retire = 0
+		return true
+	}
+	if x.idleSince.IsZero() {
+		x.idleSince = now
+		return true
+	}
+	if now.Sub(x.idleSince) >= x.opt.idleTimeout {
+		x.retire++
+		x.idleSince = now
+		x.cond.Signal()
+	}
+	return true
+}
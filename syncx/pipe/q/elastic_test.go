@@ -0,0 +1,182 @@
+package q
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pinealctx/neptune/errorx"
+)
+
+func TestElasticTaskProcessor_Future(t *testing.T) {
+	var p = NewElasticTaskProcessor(WithMaxWorkers(2))
+	defer p.Shutdown()
+
+	var f, err = SubmitFunc(p, context.Background(), func(_ context.Context) (int, error) {
+		return 42, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := f.Result()
+	if err != nil || r != 42 {
+		t.Fatalf("unexpected result: %d, %v", r, err)
+	}
+
+	var e = errors.New("failed")
+	fe, _ := SubmitFunc(p, context.Background(), func(_ context.Context) (string, error) {
+		return "", e
+	})
+	if _, err = fe.Wait(context.Background()); !errors.Is(err, e) {
+		t.Errorf("expected %v, got %v", e, err)
+	}
+
+	fp, _ := SubmitFunc(p, context.Background(), func(_ context.Context) (string, error) {
+		panic("boom")
+	})
+	var pe *errorx.PanicError
+	if _, err = fp.Result(); !errorx.As(err, &pe) {
+		t.Errorf("expected panic error, got %v", err)
+	}
+
+	var s = p.Stats()
+	if s.Submitted != 3 || s.Completed != 1 || s.Failed != 2 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+func TestElasticTaskProcessor_Timeout(t *testing.T) {
+	var p = NewElasticTaskProcessor(WithMaxWorkers(1), WithTaskTimeout(20*time.Millisecond))
+	defer p.Shutdown()
+
+	var f, _ = SubmitFunc(p, context.Background(), func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	if _, err := f.Result(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	// canceled before running, fn must not be called
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	var called atomic.Bool
+	f, _ = SubmitFunc(p, ctx, func(_ context.Context) (int, error) {
+		called.Store(true)
+		return 1, nil
+	})
+	if _, err := f.Result(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled, got %v", err)
+	}
+	if called.Load() {
+		t.Error("canceled task should not run")
+	}
+	if p.Stats().Canceled != 1 {
+		t.Errorf("expected 1 canceled, got %d", p.Stats().Canceled)
+	}
+}
+
+func TestElasticTaskProcessor_Scale(t *testing.T) {
+	var p = NewElasticTaskProcessor(WithMinWorkers(1), WithMaxWorkers(4),
+		WithIdleTimeout(40*time.Millisecond))
+
+	var release = make(chan struct{})
+	var futures []*Future[int]
+	for i := 0; i < 8; i++ {
+		var f, err = SubmitFunc(p, context.Background(), func(_ context.Context) (int, error) {
+			<-release
+			return 1, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		futures = append(futures, f)
+	}
+	if w := p.Stats().Workers; w != 4 {
+		t.Errorf("expected 4 workers, got %d", w)
+	}
+	close(release)
+	for _, f := range futures {
+		if _, err := f.Result(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var deadline = time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) && p.Stats().Workers > 1 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if w := p.Stats().Workers; w != 1 {
+		t.Errorf("expected scale down to 1 worker, got %d", w)
+	}
+
+	p.Shutdown()
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := p.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Submit(&TestTask{executed: new(int32)}); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestElasticTaskProcessor_ScaleFromIdle(t *testing.T) {
+	var p = NewElasticTaskProcessor(WithMinWorkers(1), WithMaxWorkers(4), WithIdleTimeout(time.Minute))
+	defer p.Shutdown()
+
+	// min worker is idle before the burst
+	var deadline = time.Now().Add(time.Second)
+	for p.Stats().IdleWorkers != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("worker not idle")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	var release = make(chan struct{})
+	var futures []*Future[int]
+	for i := 0; i < 8; i++ {
+		var f, err = SubmitFunc(p, context.Background(), func(_ context.Context) (int, error) {
+			<-release
+			return 1, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		futures = append(futures, f)
+	}
+	if s := p.Stats(); s.Workers != 4 {
+		t.Errorf("expected 4 workers, got %+v", s)
+	}
+	close(release)
+	for _, f := range futures {
+		if _, err := f.Result(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestElasticTaskProcessor_ShutdownDrain(t *testing.T) {
+	var p = NewElasticTaskProcessor(WithMaxWorkers(2))
+	var executed int32
+	for i := 0; i < 100; i++ {
+		if err := p.Submit(&SlowTask{duration: time.Millisecond, executed: &executed}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Shutdown()
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&executed); n != 100 {
+		t.Errorf("expected 100 executed, got %d", n)
+	}
+	if s := p.Stats(); s.Workers != 0 || s.AvgWait() <= 0 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
@@ -0,0 +1,397 @@
+package q
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// PriorityQOption configures a PriorityQ
+type PriorityQOption[T any] func(q *PriorityQ[T])
+
+// WithPriorityOf sets a function to get priority of items added by Push/PushBlocking,
+// so PriorityQ can be used by code only knows Queue interface, e.g. SimpleTaskProcessor.
+func WithPriorityOf[T any](fn func(item T) int) PriorityQOption[T] {
+	return func(q *PriorityQ[T]) {
+		q.priorityOf = fn
+	}
+}
+
+// WithRunAtOf sets a function to get "run at" time of items added by Push/PushBlocking.
+// Zero time means the item is due immediately.
+func WithRunAtOf[T any](fn func(item T) time.Time) PriorityQOption[T] {
+	return func(q *PriorityQ[T]) {
+		q.runAtOf = fn
+	}
+}
+
+// prioItem is an item with its schedule
+type prioItem[T any] struct {
+	value    T
+	priority int
+	runAt    time.Time
+	seq      uint64
+}
+
+// readyHeap orders due items by priority desc, then by push order
+type readyHeap[T any] []*prioItem[T]
+
+func (h readyHeap[T]) Len() int { return len(h) }
+func (h readyHeap[T]) Less(i, j int) bool {
+	if h[i].priority == h[j].priority {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].priority > h[j].priority
+}
+func (h readyHeap[T]) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *readyHeap[T]) Push(x any) {
+	// nolint : forcetypeassert // I know the type is exactly here
+	*h = append(*h, x.(*prioItem[T]))
+}
+func (h *readyHeap[T]) Pop() any {
+	var old = *h
+	var n = len(old)
+	var item = old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// delayHeap orders delayed items by run at time, then by push order
+type delayHeap[T any] []*prioItem[T]
+
+func (h delayHeap[T]) Len() int { return len(h) }
+func (h delayHeap[T]) Less(i, j int) bool {
+	if h[i].runAt.Equal(h[j].runAt) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].runAt.Before(h[j].runAt)
+}
+func (h delayHeap[T]) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *delayHeap[T]) Push(x any) {
+	// nolint : forcetypeassert // I know the type is exactly here
+	*h = append(*h, x.(*prioItem[T]))
+}
+func (h *delayHeap[T]) Pop() any {
+	var old = *h
+	var n = len(old)
+	var item = old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// PriorityQ represents a thread-safe queue with priorities and delayed items.
+// Items become visible to Pop when their "run at" time is reached, among visible items the one with
+// higher priority is popped first, items with same priority are popped in push order.
+// Without priority and delay, PriorityQ behaves like a FIFO queue.
+// Len, IsFull and capacity count both due and delayed items.
+type PriorityQ[T any] struct {
+	// ready holds due items
+	ready readyHeap[T]
+	// delayed holds items not due yet
+	delayed delayHeap[T]
+	// seq is the push sequence
+	seq uint64
+	// capacity is the maximum number of items the queue can hold (0 means unlimited)
+	capacity int
+	// closed indicates if the queue is closed
+	closed bool
+
+	// priorityOf gets priority for Push/PushBlocking
+	priorityOf func(item T) int
+	// runAtOf gets run at time for Push/PushBlocking
+	runAtOf func(item T) time.Time
+
+	// timer wakes up Pop when the earliest delayed item is due
+	timer *time.Timer
+	// timerAt is the time timer fires, zero if timer is not armed
+	timerAt time.Time
+
+	// lock protects all queue operations
+	lock sync.Mutex
+	// condSub is used to signal waiting Pop() operations
+	condSub sync.Cond
+	// condPub is used to signal waiting PushBlocking() operations
+	condPub sync.Cond
+}
+
+// NewPriorityQ creates a new priority/delay queue with specified capacity
+// If capacity is 0, the queue has unlimited capacity
+func NewPriorityQ[T any](capacity int, opts ...PriorityQOption[T]) *PriorityQ[T] {
+	if capacity < 0 {
+		panic("queue capacity must be non-negative")
+	}
+
+	q := &PriorityQ[T]{
+		capacity: capacity,
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	q.condSub.L = &q.lock
+	q.condPub.L = &q.lock
+	return q
+}
+
+// Push adds an item, priority and run at time come from WithPriorityOf/WithRunAtOf if set
+// Returns ErrQueueFull if queue is at capacity (when capacity > 0)
+func (q *PriorityQ[T]) Push(item T) error {
+	var priority, runAt = q.scheduleOf(item)
+	return q.PushAt(item, priority, runAt)
+}
+
+// PushPriority adds an item which is due immediately with priority
+func (q *PriorityQ[T]) PushPriority(item T, priority int) error {
+	return q.PushAt(item, priority, time.Time{})
+}
+
+// PushDelay adds an item which is due after delay
+func (q *PriorityQ[T]) PushDelay(item T, priority int, delay time.Duration) error {
+	return q.PushAt(item, priority, time.Now().Add(delay))
+}
+
+// PushAt adds an item which is due at runAt with priority, zero runAt means due immediately
+// Returns ErrQueueFull if queue is at capacity (when capacity > 0)
+func (q *PriorityQ[T]) PushAt(item T, priority int, runAt time.Time) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+	if q.capacity > 0 && q.lenLocked() >= q.capacity {
+		return ErrQueueFull
+	}
+	q.addLocked(item, priority, runAt)
+	return nil
+}
+
+// PushBlocking adds an item like Push
+// Blocks if queue is at capacity until space is available or queue is closed
+func (q *PriorityQ[T]) PushBlocking(item T) error {
+	var priority, runAt = q.scheduleOf(item)
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	// Wait until there's space or queue is closed
+	for q.capacity > 0 && q.lenLocked() >= q.capacity && !q.closed {
+		q.condPub.Wait() // wait for Pop to consume items
+	}
+
+	if q.closed {
+		return ErrClosed
+	}
+	q.addLocked(item, priority, runAt)
+	return nil
+}
+
+// Pop removes and returns the due item with the highest priority
+// Blocks if no item is due until one becomes due or queue is closed
+// Same as Q, if the queue is closed, it immediately returns ErrClosed regardless of whether there are items left.
+func (q *PriorityQ[T]) Pop() (T, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var zero T
+	for {
+		if q.closed {
+			return zero, ErrClosed
+		}
+		var now = time.Now()
+		q.promoteLocked(now)
+		if q.ready.Len() > 0 {
+			break
+		}
+		if q.delayed.Len() > 0 {
+			q.armTimerLocked(q.delayed[0].runAt, now)
+		}
+		q.condSub.Wait() // wait for push, due time or close
+	}
+
+	// nolint : forcetypeassert // I know the type is exactly here
+	var item = heap.Pop(&q.ready).(*prioItem[T])
+	q.condPub.Signal() // Signal waiting PushBlocking operations
+	if q.ready.Len() > 0 {
+		// more due items, let another waiting Pop go on
+		q.condSub.Signal()
+	}
+	return item.value, nil
+}
+
+// Peek returns the item Pop would return now without removing it
+// If no item is due, returns the earliest delayed item; returns zero value if the queue is empty
+func (q *PriorityQ[T]) Peek() T {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.promoteLocked(time.Now())
+	if q.ready.Len() > 0 {
+		return q.ready[0].value
+	}
+	if q.delayed.Len() > 0 {
+		return q.delayed[0].value
+	}
+	var zero T
+	return zero
+}
+
+// NextRunAt returns the run at time of the earliest delayed item, false if no item is delayed
+func (q *PriorityQ[T]) NextRunAt() (time.Time, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.promoteLocked(time.Now())
+	if q.delayed.Len() == 0 {
+		return time.Time{}, false
+	}
+	return q.delayed[0].runAt, true
+}
+
+// Close closes the queue, all subsequent operations will return ErrClosed
+func (q *PriorityQ[T]) Close() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.stopTimerLocked()
+	q.condSub.Broadcast() // Wake up all waiting Pop() operations
+	q.condPub.Broadcast() // Wake up all waiting PushBlocking() operations
+}
+
+// Len returns the current number of items in the queue, including delayed items
+func (q *PriorityQ[T]) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.lenLocked()
+}
+
+// Cap returns the maximum capacity of the queue (0 means unlimited)
+func (q *PriorityQ[T]) Cap() int {
+	return q.capacity
+}
+
+// IsUnlimited returns true if the queue has unlimited capacity
+func (q *PriorityQ[T]) IsUnlimited() bool {
+	return q.capacity == 0
+}
+
+// IsClosed returns true if the queue is closed
+func (q *PriorityQ[T]) IsClosed() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.closed
+}
+
+// IsFull returns true if the queue is at capacity (always false for unlimited capacity)
+func (q *PriorityQ[T]) IsFull() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.capacity == 0 {
+		return false // unlimited capacity
+	}
+	return q.lenLocked() >= q.capacity
+}
+
+// IsEmpty returns true if the queue has no items, including delayed items
+func (q *PriorityQ[T]) IsEmpty() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.lenLocked() == 0
+}
+
+// Reset clears all items from the queue (useful for reusing the queue)
+func (q *PriorityQ[T]) Reset() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closed {
+		return
+	}
+	clear(q.ready)
+	clear(q.delayed)
+	q.ready = q.ready[:0]
+	q.delayed = q.delayed[:0]
+	q.stopTimerLocked()
+	q.condPub.Broadcast()
+}
+
+// scheduleOf returns priority and run at time for Push/PushBlocking
+func (q *PriorityQ[T]) scheduleOf(item T) (int, time.Time) {
+	var (
+		priority int
+		runAt    time.Time
+	)
+	if q.priorityOf != nil {
+		priority = q.priorityOf(item)
+	}
+	if q.runAtOf != nil {
+		runAt = q.runAtOf(item)
+	}
+	return priority, runAt
+}
+
+// lenLocked returns total count, lock must be held
+func (q *PriorityQ[T]) lenLocked() int {
+	return q.ready.Len() + q.delayed.Len()
+}
+
+// addLocked adds an item then wakes up Pop, lock must be held
+func (q *PriorityQ[T]) addLocked(item T, priority int, runAt time.Time) {
+	q.seq++
+	var pi = &prioItem[T]{
+		value:    item,
+		priority: priority,
+		runAt:    runAt,
+		seq:      q.seq,
+	}
+	if runAt.IsZero() || !runAt.After(time.Now()) {
+		heap.Push(&q.ready, pi)
+		q.condSub.Signal() // Signal waiting Pop() operations
+		return
+	}
+	heap.Push(&q.delayed, pi)
+	if q.delayed[0] == pi {
+		// new earliest item, waiting Pop must re-arm its timer
+		q.condSub.Signal()
+	}
+}
+
+// promoteLocked moves due items from delayed to ready, lock must be held
+func (q *PriorityQ[T]) promoteLocked(now time.Time) {
+	for q.delayed.Len() > 0 && !q.delayed[0].runAt.After(now) {
+		heap.Push(&q.ready, heap.Pop(&q.delayed))
+	}
+}
+
+// armTimerLocked makes sure waiting Pop is woken up at runAt, lock must be held
+func (q *PriorityQ[T]) armTimerLocked(runAt time.Time, now time.Time) {
+	if !q.timerAt.IsZero() && !q.timerAt.After(runAt) {
+		return
+	}
+	q.stopTimerLocked()
+	q.timerAt = runAt
+	q.timer = time.AfterFunc(runAt.Sub(now), q.onTimer)
+}
+
+// stopTimerLocked stops armed timer, lock must be held
+func (q *PriorityQ[T]) stopTimerLocked() {
+	if q.timer != nil {
+		q.timer.Stop()
+		q.timer = nil
+	}
+	q.timerAt = time.Time{}
+}
+
+// onTimer wakes up waiting Pop when delayed item is due
+func (q *PriorityQ[T]) onTimer() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.timer = nil
+	q.timerAt = time.Time{}
+	q.condSub.Broadcast()
+}
@@ -0,0 +1,128 @@
+package q
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPriorityQ_Priority(t *testing.T) {
+	var pq = NewPriorityQ[string](0)
+	_ = pq.PushPriority("low-1", 1)
+	_ = pq.PushPriority("high", 9)
+	_ = pq.PushPriority("low-2", 1)
+	_ = pq.Push("zero")
+
+	if v := pq.Peek(); v != "high" {
+		t.Errorf("expected peek high, got %s", v)
+	}
+	for _, expected := range []string{"high", "low-1", "low-2", "zero"} {
+		var v, err = pq.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != expected {
+			t.Errorf("expected %s, got %s", expected, v)
+		}
+	}
+}
+
+func TestPriorityQ_Delay(t *testing.T) {
+	var pq = NewPriorityQ[int](0)
+	var start = time.Now()
+	_ = pq.PushDelay(3, 0, 60*time.Millisecond)
+	_ = pq.PushDelay(2, 0, 30*time.Millisecond)
+	_ = pq.PushDelay(1, 100, 30*time.Millisecond)
+
+	if _, ok := pq.NextRunAt(); !ok {
+		t.Error("expected delayed items")
+	}
+	for _, expected := range []int{1, 2, 3} {
+		var v, err = pq.Pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != expected {
+			t.Errorf("expected %d, got %d", expected, v)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("popped delayed item too early: %v", elapsed)
+	}
+
+	// an earlier item pushed while Pop is waiting
+	_ = pq.PushDelay(10, 0, time.Hour)
+	var got = make(chan int, 1)
+	go func() {
+		var v, _ = pq.Pop()
+		got <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+	_ = pq.PushDelay(11, 0, 20*time.Millisecond)
+	select {
+	case v := <-got:
+		if v != 11 {
+			t.Errorf("expected 11, got %d", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pop not woken by earlier delayed item")
+	}
+	if pq.Len() != 1 {
+		t.Errorf("expected 1 delayed item left, got %d", pq.Len())
+	}
+
+	pq.Close()
+	if _, err := pq.Pop(); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+type scheduledTask struct {
+	id    int
+	runAt time.Time
+	lock  *sync.Mutex
+	order *[]int
+	wg    *sync.WaitGroup
+}
+
+func (t *scheduledTask) Do() {
+	t.lock.Lock()
+	*t.order = append(*t.order, t.id)
+	t.lock.Unlock()
+	t.wg.Done()
+}
+
+func TestPriorityQ_TaskProcessor(t *testing.T) {
+	var queue = NewPriorityQ[ITaskItem](0, WithRunAtOf(func(item ITaskItem) time.Time {
+		// nolint : forcetypeassert // I know the type is exactly here
+		return item.(*scheduledTask).runAt
+	}))
+	var processor = NewSimpleTaskProcessorWithQueue(1, queue)
+	defer processor.Shutdown()
+
+	var (
+		lock  sync.Mutex
+		order []int
+		wg    sync.WaitGroup
+		now   = time.Now()
+	)
+	wg.Add(3)
+	for i, d := range []time.Duration{40, 0, 20} {
+		var err = processor.Submit(&scheduledTask{
+			id: i, runAt: now.Add(d * time.Millisecond),
+			lock: &lock, order: &order, wg: &wg,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	lock.Lock()
+	defer lock.Unlock()
+	for i, expected := range []int{1, 2, 0} {
+		if order[i] != expected {
+			t.Fatalf("unexpected order: %v", order)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package q
 import (
 	"container/list"
 	"sync"
+	"time"
 )
 
 // Q represents a thread-safe queue with dynamic capacity using linked list
@@ -105,6 +106,65 @@ func (q *Q[T]) Pop() (T, error) {
 	return front.Value.(T), nil
 }
 
+// PopBatch removes and returns at most max items from the front of the queue
+// Blocks if queue is empty until an item is available or queue is closed,
+// then if less than max items are available, waits at most linger for more items.
+// max is capped at capacity, so a full queue returns at once.
+// Like Pop, it returns ErrClosed once the queue is closed.
+func (q *Q[T]) PopBatch(max int, linger time.Duration) ([]T, error) {
+	if max <= 0 {
+		max = 1
+	}
+	if q.capacity > 0 && max > q.capacity {
+		max = q.capacity
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for q.items.Len() == 0 && !q.closed {
+		q.condSub.Wait()
+	}
+	if q.closed {
+		return nil, ErrClosed
+	}
+
+	if linger > 0 && q.items.Len() < max {
+		// timer wakes up the waiting loop when linger expires
+		var expired bool
+		var timer = time.AfterFunc(linger, func() {
+			q.lock.Lock()
+			expired = true
+			q.condSub.Broadcast()
+			q.lock.Unlock()
+		})
+		for q.items.Len() < max && !q.closed && !expired {
+			q.condSub.Wait()
+		}
+		timer.Stop()
+		if q.closed {
+			return nil, ErrClosed
+		}
+	}
+
+	var n = q.items.Len()
+	if n > max {
+		n = max
+	}
+	var items = make([]T, 0, n)
+	for i := 0; i < n; i++ {
+		front := q.items.Front()
+		q.items.Remove(front)
+		// nolint : forcetypeassert // I know the type is exactly here
+		items = append(items, front.Value.(T))
+	}
+	if q.items.Len() > 0 {
+		// the signal of Push may be consumed by this batch, pass it to other waiting Pop
+		q.condSub.Signal()
+	}
+	q.condPub.Broadcast() // Signal waiting PushBlocking operations
+	return items, nil
+}
+
 // Peek returns the item at the front of the queue without removing it
 // Returns zero value if the queue is empty
 func (q *Q[T]) Peek() T {
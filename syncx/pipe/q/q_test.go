@@ -552,6 +552,12 @@ func TestQueueImplementations(t *testing.T) {
 				return NewQ[int](capacity)
 			},
 		},
+		{
+			name: "PriorityQ",
+			createQueue: func(capacity int) Queue[int] {
+				return NewPriorityQ[int](capacity)
+			},
+		},
 	}
 
 	testItems := []int{1, 2, 3, 4, 5}
@@ -574,8 +580,8 @@ func TestQueueImplementations(t *testing.T) {
 			})
 
 			t.Run("UnlimitedCapacity", func(t *testing.T) {
-				q := tc.createQueue(0) // Only Q supports unlimited capacity
-				if tc.name == "Q" {
+				q := tc.createQueue(0) // RingQ does not support unlimited capacity
+				if tc.name != "RingQ" {
 					testCapacityBehavior(t, q, 0, 42)
 				}
 			})
@@ -830,6 +836,64 @@ func TestQSpecific(t *testing.T) {
 			t.Errorf("Should be able to push after pop: %v", err)
 		}
 	})
+
+	t.Run("PopBatch", func(t *testing.T) {
+		q := NewQ[int](0)
+		for i := 0; i < 5; i++ {
+			_ = q.Push(i)
+		}
+
+		// no linger, take what is available
+		items, err := q.PopBatch(3, 0)
+		if err != nil || len(items) != 3 || items[0] != 0 || items[2] != 2 {
+			t.Fatalf("unexpected batch %v %v", items, err)
+		}
+		items, err = q.PopBatch(3, 0)
+		if err != nil || len(items) != 2 {
+			t.Fatalf("unexpected batch %v %v", items, err)
+		}
+
+		// linger waits for more items until max
+		go func() {
+			for i := 0; i < 3; i++ {
+				time.Sleep(5 * time.Millisecond)
+				_ = q.Push(i)
+			}
+		}()
+		items, err = q.PopBatch(3, time.Second)
+		if err != nil || len(items) != 3 {
+			t.Fatalf("unexpected batch %v %v", items, err)
+		}
+
+		// linger expires
+		_ = q.Push(1)
+		start := time.Now()
+		items, err = q.PopBatch(3, 20*time.Millisecond)
+		if err != nil || len(items) != 1 {
+			t.Fatalf("unexpected batch %v %v", items, err)
+		}
+		if time.Since(start) < 20*time.Millisecond {
+			t.Error("should wait linger")
+		}
+
+		q.Close()
+		if _, err = q.PopBatch(3, 0); err != ErrClosed {
+			t.Errorf("expected ErrClosed, got %v", err)
+		}
+
+		// max is capped at capacity, a full queue does not wait linger
+		q = NewQ[int](2)
+		_ = q.Push(1)
+		_ = q.Push(2)
+		start = time.Now()
+		items, err = q.PopBatch(10, time.Second)
+		if err != nil || len(items) != 2 {
+			t.Fatalf("unexpected batch %v %v", items, err)
+		}
+		if time.Since(start) >= time.Second {
+			t.Error("full queue should not wait linger")
+		}
+	})
 }
 
 // =============================================================================
@@ -1,8 +1,8 @@
 package q
 
 import (
+	"github.com/pinealctx/neptune/errorx"
 	"github.com/pinealctx/neptune/ulog"
-	"go.uber.org/zap"
 )
 
 // ITaskItem represents a task that can be executed by the SimpleTaskProcessor.
@@ -61,8 +61,21 @@ type SimpleTaskProcessor struct {
 // The processor starts immediately and begins listening for tasks. Worker goroutines
 // will block waiting for tasks until the processor is shut down.
 func NewSimpleTaskProcessor(workerCount int) *SimpleTaskProcessor {
+	return NewSimpleTaskProcessorWithQueue(workerCount, NewQ[ITaskItem](0))
+}
+
+// NewSimpleTaskProcessorWithQueue creates and starts a new task processor backed by
+// the given queue, e.g. a PriorityQ to run tasks by priority or at scheduled time:
+//
+//	queue := NewPriorityQ[ITaskItem](0, WithRunAtOf(func(t ITaskItem) time.Time {
+//		return t.(*MyTask).runAt
+//	}))
+//	processor := NewSimpleTaskProcessorWithQueue(4, queue)
+//
+// The processor owns the queue, Shutdown closes it.
+func NewSimpleTaskProcessorWithQueue(workerCount int, queue Queue[ITaskItem]) *SimpleTaskProcessor {
 	x := &SimpleTaskProcessor{
-		queue:       NewQ[ITaskItem](0),
+		queue:       queue,
 		workerCount: workerCount,
 	}
 	x.start()
@@ -121,14 +134,9 @@ func (x *SimpleTaskProcessor) runWorker() {
 		}
 
 		// Execute task with panic recovery
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					// Log panic or handle it as needed
-					ulog.Error("SimpleTaskProcessor worker panic", zap.Any("panic", r), zap.Stack("stack"))
-				}
-			}()
-			task.Do()
-		}()
+		err = errorx.SafeRun(task.Do)
+		if err != nil {
+			ulog.Error("SimpleTaskProcessor worker panic", ulog.ErrorObj(err))
+		}
 	}
 }
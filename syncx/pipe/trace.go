@@ -0,0 +1,113 @@
+package pipe
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span : a tracing span, the subset of OpenTelemetry trace.Span used by TraceObserver
+type Span interface {
+	//End : end the span
+	End()
+	//RecordError : record an error in span
+	RecordError(err error)
+}
+
+// Tracer : a tracer starts span, the subset of OpenTelemetry trace.Tracer used by TraceObserver.
+// Use OTelTracer to adapt an OpenTelemetry tracer.
+type Tracer interface {
+	//Start : start a span as child of the span in ctx, return the context with the new span
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// OTelTracer : adapt OpenTelemetry tracer to Tracer, the error recorded in span also sets span status to error.
+func OTelTracer(tracer trace.Tracer) Tracer {
+	return otelTracer{tracer: tracer}
+}
+
+// otelTracer : OpenTelemetry tracer adapter
+type otelTracer struct {
+	tracer trace.Tracer
+}
+
+// Start : implement Tracer
+func (o otelTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	var span trace.Span
+	ctx, span = o.tracer.Start(ctx, name)
+	return ctx, otelSpan{span: span}
+}
+
+// otelSpan : OpenTelemetry span adapter
+type otelSpan struct {
+	span trace.Span
+}
+
+// End : implement Span
+func (o otelSpan) End() {
+	o.span.End()
+}
+
+// RecordError : implement Span
+func (o otelSpan) RecordError(err error) {
+	o.span.RecordError(err)
+	o.span.SetStatus(codes.Error, err.Error())
+}
+
+// traceKey : context key of trace state
+type traceKey struct{}
+
+// traceState : spans of an item
+type traceState struct {
+	//parent context, the caller context
+	parent context.Context
+	//queue span
+	queue Span
+	//run span
+	run Span
+}
+
+// TraceObserver : observer to propagate tracing spans across the async hop of pipes.
+// For each item, two sibling spans are recorded under the caller span:
+// "<name>.queue" -- from enqueue to dequeue.
+// "<name>.run" -- from dequeue to done, spans started in handler are children of it.
+type TraceObserver struct {
+	tracer Tracer
+}
+
+// NewTraceObserver : new trace observer
+func NewTraceObserver(tracer Tracer) *TraceObserver {
+	return &TraceObserver{tracer: tracer}
+}
+
+// OnEnqueue : implement Observer
+func (t *TraceObserver) OnEnqueue(ctx context.Context, at Point) context.Context {
+	var st = &traceState{parent: ctx}
+	ctx, st.queue = t.tracer.Start(ctx, at.Name+".queue")
+	return context.WithValue(ctx, traceKey{}, st)
+}
+
+// OnDequeue : implement Observer
+func (t *TraceObserver) OnDequeue(ctx context.Context, at Point, _ time.Duration) context.Context {
+	var st, ok = ctx.Value(traceKey{}).(*traceState)
+	if !ok {
+		return ctx
+	}
+	st.queue.End()
+	ctx, st.run = t.tracer.Start(st.parent, at.Name+".run")
+	return context.WithValue(ctx, traceKey{}, st)
+}
+
+// OnDone : implement Observer
+func (t *TraceObserver) OnDone(ctx context.Context, _ Point, _ time.Duration, err error) {
+	var st, ok = ctx.Value(traceKey{}).(*traceState)
+	if !ok || st.run == nil {
+		return
+	}
+	if err != nil {
+		st.run.RecordError(err)
+	}
+	st.run.End()
+}
@@ -0,0 +1,49 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceObserver_OTel(t *testing.T) {
+	var recorder = tracetest.NewSpanRecorder()
+	var provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	var tracer = provider.Tracer("pipe")
+	var observer = NewTraceObserver(OTelTracer(tracer))
+	var at = Point{Name: "test"}
+
+	var ctx, caller = tracer.Start(context.Background(), "caller")
+	ctx = observer.OnEnqueue(ctx, at)
+	ctx = observer.OnDequeue(ctx, at, 0)
+	var run = trace.SpanFromContext(ctx).SpanContext()
+	observer.OnDone(ctx, at, 0, errors.New("failed"))
+	caller.End()
+
+	var spans = recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	var queue, runSpan = spans[0], spans[1]
+	if queue.Name() != "test.queue" || runSpan.Name() != "test.run" {
+		t.Fatalf("unexpected span names %s %s", queue.Name(), runSpan.Name())
+	}
+	var callerID = caller.SpanContext().SpanID()
+	if queue.Parent().SpanID() != callerID || runSpan.Parent().SpanID() != callerID {
+		t.Error("queue and run spans should be children of caller")
+	}
+	if runSpan.SpanContext().SpanID() != run.SpanID() {
+		t.Error("run span should be the current span of handler context")
+	}
+	if runSpan.Status().Code != codes.Error || len(runSpan.Events()) != 1 {
+		t.Errorf("error should be recorded, status %+v", runSpan.Status())
+	}
+	if queue.Status().Code != codes.Unset {
+		t.Errorf("unexpected queue status %+v", queue.Status())
+	}
+}
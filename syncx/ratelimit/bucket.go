@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript : token bucket in redis, time unit is microsecond, tokens are scaled to ticks.
+// KEYS[1] -- key
+// ARGV -- ticks refilled per microsecond, ticks per token, burst, n
+// return -- allowed, remaining, retry after, reset after
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local cap = tonumber(ARGV[3]) * period
+local need = tonumber(ARGV[4]) * period
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local v = redis.call('HMGET', KEYS[1], 'ticks', 'last')
+local ticks = tonumber(v[1])
+local last = tonumber(v[2])
+if ticks == nil or last == nil then
+	ticks = cap
+	last = now
+end
+if now > last then
+	ticks = math.min(cap, ticks + (now - last) * rate)
+	last = now
+end
+local allowed = 0
+local retry = 0
+if ticks >= need then
+	ticks = ticks - need
+	allowed = 1
+else
+	retry = math.ceil((need - ticks) / rate)
+end
+local reset = math.ceil((cap - ticks) / rate)
+if reset > 0 then
+	redis.call('HSET', KEYS[1], 'ticks', string.format('%.0f', ticks), 'last', string.format('%.0f', last))
+	redis.call('PEXPIRE', KEYS[1], math.ceil(reset / 1000) + 1)
+else
+	redis.call('DEL', KEYS[1])
+end
+return {allowed, math.floor(ticks / period), retry, reset}
+`)
+
+// tokenBucket : token bucket algorithm, tokens are refilled at Rate per Period up to Burst.
+// To avoid float precision issue, tokens are scaled to integer ticks, see Limit.ticks.
+type tokenBucket struct {
+	limit Limit
+	//ticks per token
+	perToken int64
+	//ticks refilled per nanosecond
+	perUnit int64
+}
+
+// bucketState : state of token bucket
+type bucketState struct {
+	//tokens * perToken
+	ticks int64
+	//last refill time
+	last time.Time
+}
+
+// NewTokenBucket : new keyed token bucket limiter.
+// Each key has a bucket of Burst tokens which is refilled at Rate per Period, bursts up to Burst are allowed.
+func NewTokenBucket(limit Limit, opts ...Option) Limiter {
+	var o = rangeOption(opts...)
+	limit = limit.normalize(o.unit())
+	var perToken, perUnit = limit.ticks(o.unit(), o.maxTicks())
+	if o.rds != nil {
+		return newRedisLimiter(o, tokenBucketScript, limit.Burst,
+			perUnit, perToken, limit.Burst)
+	}
+	return newLocalLimiter[bucketState](&tokenBucket{limit: limit, perToken: perToken, perUnit: perUnit}, o)
+}
+
+// init : full bucket
+func (b *tokenBucket) init(s *bucketState, now time.Time) {
+	s.ticks = b.capacity()
+	s.last = now
+}
+
+// allow : refill then take n tokens
+func (b *tokenBucket) allow(s *bucketState, now time.Time, n int) Result {
+	var rate, period, capacity = b.perUnit, b.perToken, b.capacity()
+	var elapsed = int64(now.Sub(s.last))
+	if elapsed > 0 {
+		if elapsed >= ceilDiv(capacity-s.ticks, rate) {
+			s.ticks = capacity
+		} else {
+			s.ticks += elapsed * rate
+		}
+		s.last = now
+	}
+	var r Result
+	var need = int64(n) * period
+	if s.ticks >= need {
+		s.ticks -= need
+		r.Allowed = true
+	} else {
+		r.RetryAfter = time.Duration(ceilDiv(need-s.ticks, rate))
+	}
+	r.Remaining = int(s.ticks / period)
+	r.ResetAfter = time.Duration(ceilDiv(capacity-s.ticks, rate))
+	return r
+}
+
+// burst : max tokens at once
+func (b *tokenBucket) burst() int {
+	return b.limit.Burst
+}
+
+// capacity : ticks of full bucket
+func (b *tokenBucket) capacity() int64 {
+	return int64(b.limit.Burst) * b.perToken
+}
+
+// ceilDiv : ceil of a/b, a >= 0 and b > 0
+func ceilDiv(a, b int64) int64 {
+	return (a + b - 1) / b
+}
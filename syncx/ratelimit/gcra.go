@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript : GCRA in redis, time unit is microsecond.
+// KEYS[1] -- key
+// ARGV -- emission interval, burst, n
+// return -- allowed, remaining, retry after, reset after
+var gcraScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local tolerance = interval * tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if tat == nil or tat < now then
+	tat = now
+end
+local newTat = tat + n * interval
+local allowAt = newTat - tolerance
+if now < allowAt then
+	return {0, math.floor((tolerance - (tat - now)) / interval), allowAt - now, tat - now}
+end
+if newTat > now then
+	redis.call('SET', KEYS[1], string.format('%.0f', newTat), 'PX', math.ceil((newTat - now) / 1000) + 1)
+end
+return {1, math.floor((tolerance - (newTat - now)) / interval), 0, newTat - now}
+`)
+
+// gcra : generic cell rate algorithm, it's equivalent to token bucket but only stores
+// the theoretical arrival time(TAT) of next event.
+// Events are emitted at one per Period/Rate, bursts up to Burst are allowed.
+type gcra struct {
+	limit Limit
+	//emission interval
+	interval time.Duration
+	//burst tolerance
+	tolerance time.Duration
+}
+
+// gcraState : state of GCRA
+type gcraState struct {
+	//theoretical arrival time
+	tat time.Time
+}
+
+// NewGCRA : new keyed GCRA limiter, it's like token bucket but smoother and uses less memory.
+func NewGCRA(limit Limit, opts ...Option) Limiter {
+	var o = rangeOption(opts...)
+	limit = limit.normalize(o.unit())
+	var interval = limit.interval()
+	//tolerance must not overflow
+	if int64(limit.Burst) > o.maxTicks()/int64(interval/o.unit()) {
+		panic("ratelimit: limit overflows")
+	}
+	if o.rds != nil {
+		return newRedisLimiter(o, gcraScript, limit.Burst,
+			interval.Microseconds(), limit.Burst)
+	}
+	return newLocalLimiter[gcraState](&gcra{
+		limit:     limit,
+		interval:  interval,
+		tolerance: interval * time.Duration(limit.Burst),
+	}, o)
+}
+
+// init : no event emitted
+func (g *gcra) init(s *gcraState, now time.Time) {
+	s.tat = now
+}
+
+// allow : take n cells if TAT after taking is in tolerance
+func (g *gcra) allow(s *gcraState, now time.Time, n int) Result {
+	var tat = s.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	var newTat = tat.Add(time.Duration(n) * g.interval)
+	var allowAt = newTat.Add(-g.tolerance)
+	if now.Before(allowAt) {
+		return Result{
+			Remaining:  int((g.tolerance - tat.Sub(now)) / g.interval),
+			RetryAfter: allowAt.Sub(now),
+			ResetAfter: tat.Sub(now),
+		}
+	}
+	s.tat = newTat
+	return Result{
+		Allowed:    true,
+		Remaining:  int((g.tolerance - newTat.Sub(now)) / g.interval),
+		ResetAfter: newTat.Sub(now),
+	}
+}
+
+// burst : max cells at once
+func (g *gcra) burst() int {
+	return g.limit.Burst
+}
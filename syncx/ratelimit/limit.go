@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+const (
+	//maxLocalTicks : max ticks in memory
+	maxLocalTicks = math.MaxInt64
+	//maxScriptTicks : max integer of lua number(double) without precision loss
+	maxScriptTicks = 1 << 53
+)
+
+var (
+	//ErrExceedLimit -- tokens requested at once exceed the max burst, it's never allowed
+	ErrExceedLimit = errors.New("ratelimit.exceed.limit")
+)
+
+// Limit : rate limit, Rate events per Period, with max burst
+type Limit struct {
+	//Rate : events per period
+	Rate int
+	//Period : period of rate
+	Period time.Duration
+	//Burst : max events at once for token bucket and GCRA, Rate is used if it's <= 0.
+	//Sliding window ignores it.
+	Burst int
+}
+
+// PerSecond : rate events per second
+func PerSecond(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Second, Burst: rate}
+}
+
+// PerMinute : rate events per minute
+func PerMinute(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Minute, Burst: rate}
+}
+
+// PerHour : rate events per hour
+func PerHour(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Hour, Burst: rate}
+}
+
+// interval : duration to produce one token
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Rate)
+}
+
+// normalize : check limit and setup default burst, interval must be at least one time unit
+func (l Limit) normalize(unit time.Duration) Limit {
+	if l.Rate <= 0 || l.Period <= 0 || l.Period/unit < time.Duration(l.Rate) {
+		panic("ratelimit: invalid limit")
+	}
+	if l.Burst <= 0 {
+		l.Burst = l.Rate
+	}
+	return l
+}
+
+// ticks : tokens are scaled to integer ticks to avoid float precision issue,
+// a token is perToken ticks and perUnit ticks are refilled in each time unit.
+// Ticks are reduced by gcd of period and rate, it panics if ticks of burst still exceed maxTicks.
+func (l Limit) ticks(unit time.Duration, maxTicks int64) (perToken int64, perUnit int64) {
+	var period, rate = int64(l.Period / unit), int64(l.Rate)
+	var g = gcd(period, rate)
+	perToken, perUnit = period/g, rate/g
+	if int64(l.Burst) > maxTicks/perToken {
+		panic("ratelimit: limit overflows")
+	}
+	return perToken, perUnit
+}
+
+// gcd : greatest common divisor of a and b, a > 0 and b > 0
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// Result : result of taking tokens
+type Result struct {
+	//Allowed : tokens are taken
+	Allowed bool
+	//Remaining : tokens can be taken now after this call
+	Remaining int
+	//RetryAfter : duration to wait until the tokens can be taken if not allowed, 0 if allowed
+	RetryAfter time.Duration
+	//ResetAfter : duration until the limiter of key goes back to initial state(full)
+	ResetAfter time.Duration
+}
+
+// Limiter : keyed rate limiter
+type Limiter interface {
+	//Allow : take 1 token of key
+	Allow(ctx context.Context, key any) (Result, error)
+	//AllowN : take n tokens of key at once, nothing is taken if not allowed.
+	//n == 0 peeks the state without taking, return ErrExceedLimit if n is larger than the max burst.
+	AllowN(ctx context.Context, key any, n int) (Result, error)
+	//Reset : reset key to initial state
+	Reset(ctx context.Context, key any) error
+}
+
+// Wait : wait until n tokens of key are taken or ctx is done
+func Wait(ctx context.Context, l Limiter, key any, n int) error {
+	for {
+		var r, err = l.AllowN(ctx, key, n)
+		if err != nil {
+			return err
+		}
+		if r.Allowed {
+			return nil
+		}
+		var timer = time.NewTimer(r.RetryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
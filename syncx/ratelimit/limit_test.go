@@ -0,0 +1,193 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock : manual clock
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) add(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+// withClock : replace clock of local limiter
+func withClock[S any](t *testing.T, l Limiter) *fakeClock {
+	t.Helper()
+	var c = &fakeClock{t: time.Unix(1000, 0)}
+	var x, ok = l.(*localLimiter[S])
+	if !ok {
+		t.Fatal("not a local limiter")
+	}
+	x.now = c.now
+	return c
+}
+
+func allowN(t *testing.T, l Limiter, key any, n int, allowed bool, remaining int) Result {
+	t.Helper()
+	var r, err = l.AllowN(context.Background(), key, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Allowed != allowed || r.Remaining != remaining {
+		t.Fatalf("expected %v %d, got %+v", allowed, remaining, r)
+	}
+	return r
+}
+
+func TestTokenBucket(t *testing.T) {
+	var l = NewTokenBucket(Limit{Rate: 10, Period: time.Second, Burst: 5})
+	var c = withClock[bucketState](t, l)
+	allowN(t, l, "a", 3, true, 2)
+	allowN(t, l, "a", 2, true, 0)
+	var r = allowN(t, l, "a", 2, false, 0)
+	if r.RetryAfter != 200*time.Millisecond || r.ResetAfter != 500*time.Millisecond {
+		t.Fatalf("unexpected %+v", r)
+	}
+	// other key is not affected
+	allowN(t, l, "b", 5, true, 0)
+
+	c.add(150 * time.Millisecond)
+	r = allowN(t, l, "a", 2, false, 1)
+	if r.RetryAfter != 50*time.Millisecond {
+		t.Fatalf("unexpected %+v", r)
+	}
+	c.add(50 * time.Millisecond)
+	allowN(t, l, "a", 2, true, 0)
+	c.add(time.Hour)
+	allowN(t, l, "a", 0, true, 5)
+
+	if _, err := l.AllowN(context.Background(), "a", 6); err != ErrExceedLimit {
+		t.Fatalf("expected exceed limit, got %v", err)
+	}
+	allowN(t, l, "b", 5, true, 0)
+	_ = l.Reset(context.Background(), "b")
+	allowN(t, l, "b", 1, true, 4)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	var l = NewSlidingWindow(PerSecond(10))
+	var c = withClock[windowState](t, l)
+	allowN(t, l, 1, 6, true, 4)
+	c.add(500 * time.Millisecond)
+	allowN(t, l, 1, 4, true, 0)
+	var r = allowN(t, l, 1, 1, false, 0)
+	// all 10 events are in current window, wait for next window and 1/10 of it
+	if r.RetryAfter != 600*time.Millisecond || r.ResetAfter != 1500*time.Millisecond {
+		t.Fatalf("unexpected %+v", r)
+	}
+	// previous window has 10 events, 80% of it is in sliding window
+	c.add(700 * time.Millisecond)
+	r = allowN(t, l, 1, 3, false, 2)
+	if r.RetryAfter != 100*time.Millisecond {
+		t.Fatalf("unexpected %+v", r)
+	}
+	c.add(100 * time.Millisecond)
+	allowN(t, l, 1, 3, true, 0)
+	// windows slide out
+	c.add(2 * time.Second)
+	allowN(t, l, 1, 0, true, 10)
+
+	if _, err := l.AllowN(context.Background(), 1, 11); err != ErrExceedLimit {
+		t.Fatalf("expected exceed limit, got %v", err)
+	}
+}
+
+func TestGCRA(t *testing.T) {
+	var l = NewGCRA(Limit{Rate: 10, Period: time.Second, Burst: 3})
+	var c = withClock[gcraState](t, l)
+	allowN(t, l, "a", 2, true, 1)
+	allowN(t, l, "a", 1, true, 0)
+	var r = allowN(t, l, "a", 1, false, 0)
+	if r.RetryAfter != 100*time.Millisecond || r.ResetAfter != 300*time.Millisecond {
+		t.Fatalf("unexpected %+v", r)
+	}
+	c.add(100 * time.Millisecond)
+	r = allowN(t, l, "a", 1, true, 0)
+	if r.ResetAfter != 300*time.Millisecond {
+		t.Fatalf("unexpected %+v", r)
+	}
+	c.add(time.Second)
+	allowN(t, l, "a", 0, true, 3)
+}
+
+func TestLRUEviction(t *testing.T) {
+	// capacity of each group is capacity/groups+1
+	var l = NewGCRA(PerMinute(1), WithPrime(1), WithCapacity(2))
+	withClock[gcraState](t, l)
+	allowN(t, l, "a", 1, true, 0)
+	allowN(t, l, "b", 1, true, 0)
+	allowN(t, l, "c", 1, true, 0)
+	allowN(t, l, "a", 1, false, 0)
+	// b is evicted by d, then goes back to initial state
+	allowN(t, l, "d", 1, true, 0)
+	allowN(t, l, "a", 1, false, 0)
+	allowN(t, l, "b", 1, true, 0)
+}
+
+func TestWait(t *testing.T) {
+	var l = NewTokenBucket(Limit{Rate: 100, Period: time.Second, Burst: 1}, WithXHash())
+	var t1 = time.Now()
+	for i := 0; i < 5; i++ {
+		if err := Wait(context.Background(), l, "k", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(t1); d < 35*time.Millisecond {
+		t.Fatalf("wait too short %v", d)
+	}
+	var ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, _ = l.Allow(context.Background(), "x")
+	if err := Wait(ctx, NewTokenBucket(PerMinute(1)), "x", 1); err != nil {
+		t.Fatal(err)
+	}
+	// token of x is taken, next one is after 10ms
+	if err := Wait(ctx, l, "x", 1); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestTokenBucketTicks(t *testing.T) {
+	// burst * period in nanosecond overflows int64, ticks are reduced by gcd
+	for _, limit := range []Limit{{Rate: 1e6, Period: 24 * time.Hour}, PerHour(3_000_000)} {
+		var l = NewTokenBucket(limit)
+		var c = withClock[bucketState](t, l)
+		allowN(t, l, "a", 1, true, limit.Rate-1)
+		allowN(t, l, "a", limit.Rate-1, true, 0)
+		var r = allowN(t, l, "a", 1, false, 0)
+		if r.RetryAfter != limit.interval() || r.ResetAfter != limit.Period {
+			t.Fatalf("unexpected %+v", r)
+		}
+		c.add(limit.Period)
+		allowN(t, l, "a", 0, true, limit.Rate)
+	}
+}
+
+func TestInvalidLimit(t *testing.T) {
+	var cases = []func(){
+		func() { NewTokenBucket(Limit{Rate: 10, Period: time.Nanosecond}) },
+		func() { NewGCRA(Limit{Period: time.Second}) },
+		// period is not reducible, burst ticks overflow
+		func() { NewTokenBucket(Limit{Rate: 1e6, Period: 24*time.Hour + 1}) },
+		func() { NewGCRA(Limit{Rate: 1, Period: time.Hour, Burst: 1 << 40}) },
+	}
+	for i, fn := range cases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("case %d should panic", i)
+				}
+			}()
+			fn()
+		}()
+	}
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pinealctx/neptune/cache/tiny"
+	"github.com/pinealctx/neptune/remap"
+)
+
+// algorithm : rate limit algorithm on state S of a key
+type algorithm[S any] interface {
+	//init : initial state
+	init(s *S, now time.Time)
+	//allow : take n tokens, n is in range [1, max burst]
+	allow(s *S, now time.Time, n int) Result
+	//burst : max tokens at once
+	burst() int
+}
+
+// shard : a group of keys, the LRU is guarded by mu to update state atomically
+type shard struct {
+	mu  sync.Mutex
+	lru *tiny.LRUCache
+}
+
+// localLimiter : in memory limiter, keys are grouped by remap like semap.WideSemMap,
+// each group is bounded by LRU.
+type localLimiter[S any] struct {
+	algo     algorithm[S]
+	shards   []*shard
+	calKeyFn func(key any) int
+	rehash   *remap.ReMap
+	//clock, it's replaced in test
+	now func() time.Time
+}
+
+// newLocalLimiter : new in memory limiter
+func newLocalLimiter[S any](algo algorithm[S], o *_Option) *localLimiter[S] {
+	var l = &localLimiter[S]{algo: algo, now: time.Now}
+	if o.prime > 0 {
+		l.rehash = remap.NewReMap(remap.WithPrime(o.prime))
+	} else {
+		l.rehash = remap.NewReMap()
+	}
+	var numbs = l.rehash.Numbs()
+	var pSize = o.capacity/int64(numbs) + 1
+	l.shards = make([]*shard, numbs)
+	for i := uint64(0); i < numbs; i++ {
+		l.shards[i] = &shard{lru: tiny.NewLRUCache(pSize)}
+	}
+	if o.useXHash {
+		l.calKeyFn = l.rehash.XHashIndex
+	} else {
+		l.calKeyFn = l.rehash.SimpleIndex
+	}
+	return l
+}
+
+// Allow : take 1 token of key
+func (l *localLimiter[S]) Allow(ctx context.Context, key any) (Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN : take n tokens of key at once, nothing is taken if not allowed
+func (l *localLimiter[S]) AllowN(_ context.Context, key any, n int) (Result, error) {
+	if n > l.algo.burst() {
+		return Result{}, ErrExceedLimit
+	}
+	var now = l.now()
+	var sd = l.shards[l.calKeyFn(key)]
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	var s *S
+	var v, ok = sd.lru.Get(key)
+	if ok {
+		// nolint : forcetypeassert // I know the type is exactly here
+		s = v.(*S)
+	} else {
+		s = new(S)
+		l.algo.init(s, now)
+		sd.lru.Set(key, s)
+	}
+	return l.algo.allow(s, now, max(n, 0)), nil
+}
+
+// Reset : reset key to initial state
+func (l *localLimiter[S]) Reset(_ context.Context, key any) error {
+	var sd = l.shards[l.calKeyFn(key)]
+	sd.mu.Lock()
+	sd.lru.Delete(key)
+	sd.mu.Unlock()
+	return nil
+}
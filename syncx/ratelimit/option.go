@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	//DefaultCapacity default max keys in memory
+	DefaultCapacity = 1024 * 64
+)
+
+// limiter option
+type _Option struct {
+	//group prime number
+	prime uint64
+	//use xxhash to group keys
+	useXHash bool
+	//max keys in memory
+	capacity int64
+
+	//redis backend
+	rds    redis.Cmdable
+	prefix string
+}
+
+// Option : limiter option function
+type Option func(o *_Option)
+
+// WithPrime : setup prime number of groups, see remap.WithPrime
+func WithPrime(prime uint64) Option {
+	return func(o *_Option) {
+		o.prime = prime
+	}
+}
+
+// WithXHash : group keys by xxhash, default is remap.SimpleIndex
+func WithXHash() Option {
+	return func(o *_Option) {
+		o.useXHash = true
+	}
+}
+
+// WithCapacity : setup max keys in memory, the least recently used keys are evicted when it's reached.
+// An evicted key goes back to initial state(full). Default is DefaultCapacity.
+func WithCapacity(capacity int64) Option {
+	return func(o *_Option) {
+		o.capacity = capacity
+	}
+}
+
+// WithRedis : use redis as backend for cluster wide limit, keys are stored as prefix+fmt.Sprint(key).
+// Time of redis server is used, so the limit is consistent among all clients.
+func WithRedis(cmd redis.Cmdable, prefix string) Option {
+	return func(o *_Option) {
+		o.rds = cmd
+		o.prefix = prefix
+	}
+}
+
+// unit : time unit of backend, redis scripts use microsecond
+func (o *_Option) unit() time.Duration {
+	if o.rds != nil {
+		return time.Microsecond
+	}
+	return time.Nanosecond
+}
+
+// maxTicks : max ticks of backend
+func (o *_Option) maxTicks() int64 {
+	if o.rds != nil {
+		return maxScriptTicks
+	}
+	return maxLocalTicks
+}
+
+// rangeOption : range options
+func rangeOption(opts ...Option) *_Option {
+	var o = &_Option{
+		capacity: DefaultCapacity,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisLimiter : limiter in redis, each algorithm is a lua script run atomically in redis
+type redisLimiter struct {
+	cmd    redis.Cmdable
+	prefix string
+	script *redis.Script
+	//script arguments of limit, n is appended
+	args []any
+	//max tokens at once
+	max int
+}
+
+// newRedisLimiter : new redis limiter
+func newRedisLimiter(o *_Option, script *redis.Script, maxN int, args ...any) *redisLimiter {
+	return &redisLimiter{
+		cmd:    o.rds,
+		prefix: o.prefix,
+		script: script,
+		args:   args,
+		max:    maxN,
+	}
+}
+
+// Allow : take 1 token of key
+func (l *redisLimiter) Allow(ctx context.Context, key any) (Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN : take n tokens of key at once, nothing is taken if not allowed
+func (l *redisLimiter) AllowN(ctx context.Context, key any, n int) (Result, error) {
+	if n > l.max {
+		return Result{}, ErrExceedLimit
+	}
+	var args = make([]any, 0, len(l.args)+1)
+	args = append(args, l.args...)
+	args = append(args, max(n, 0))
+	var vs, err = l.script.Run(ctx, l.cmd, []string{l.key(key)}, args...).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(vs) != 4 {
+		return Result{}, fmt.Errorf("ratelimit: invalid script result %v", vs)
+	}
+	return Result{
+		Allowed:    vs[0] == 1,
+		Remaining:  int(vs[1]),
+		RetryAfter: time.Duration(vs[2]) * time.Microsecond,
+		ResetAfter: time.Duration(vs[3]) * time.Microsecond,
+	}, nil
+}
+
+// Reset : reset key to initial state
+func (l *redisLimiter) Reset(ctx context.Context, key any) error {
+	return l.cmd.Del(ctx, l.key(key)).Err()
+}
+
+// key : redis key
+func (l *redisLimiter) key(key any) string {
+	return l.prefix + fmt.Sprint(key)
+}
@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisClock : clock of miniredis, TIME command in scripts returns it
+type redisClock struct {
+	m *miniredis.Miniredis
+	t time.Time
+}
+
+func (c *redisClock) add(d time.Duration) {
+	c.t = c.t.Add(d)
+	c.m.SetTime(c.t)
+	c.m.FastForward(d)
+}
+
+// newRedis : miniredis with a manual clock
+func newRedis(t *testing.T) (Option, *redisClock) {
+	t.Helper()
+	var m = miniredis.RunT(t)
+	var c = &redisClock{m: m, t: time.Unix(1000, 0)}
+	m.SetTime(c.t)
+	var rds = redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() {
+		_ = rds.Close()
+	})
+	return WithRedis(rds, "rl:"), c
+}
+
+func TestRedisTokenBucket(t *testing.T) {
+	var opt, c = newRedis(t)
+	var l = NewTokenBucket(Limit{Rate: 10, Period: time.Second, Burst: 5}, opt)
+	allowN(t, l, "a", 3, true, 2)
+	allowN(t, l, "a", 2, true, 0)
+	var r = allowN(t, l, "a", 2, false, 0)
+	if r.RetryAfter != 200*time.Millisecond || r.ResetAfter != 500*time.Millisecond {
+		t.Fatalf("unexpected %+v", r)
+	}
+	allowN(t, l, "b", 5, true, 0)
+
+	c.add(150 * time.Millisecond)
+	r = allowN(t, l, "a", 2, false, 1)
+	if r.RetryAfter != 50*time.Millisecond {
+		t.Fatalf("unexpected %+v", r)
+	}
+	c.add(50 * time.Millisecond)
+	allowN(t, l, "a", 2, true, 0)
+	// key expires after refilled
+	c.add(time.Hour)
+	if c.m.Exists("rl:a") {
+		t.Fatal("key should expire")
+	}
+	allowN(t, l, "a", 0, true, 5)
+
+	if _, err := l.AllowN(context.Background(), "a", 6); err != ErrExceedLimit {
+		t.Fatalf("expected exceed limit, got %v", err)
+	}
+	if err := l.Reset(context.Background(), "b"); err != nil {
+		t.Fatal(err)
+	}
+	allowN(t, l, "b", 1, true, 4)
+
+	// ticks are reduced to fit lua number
+	l = NewTokenBucket(Limit{Rate: 1e6, Period: 24 * time.Hour}, opt)
+	allowN(t, l, "c", 1, true, 1e6-1)
+}
+
+func TestRedisSlidingWindow(t *testing.T) {
+	var opt, c = newRedis(t)
+	var l = NewSlidingWindow(PerSecond(10), opt)
+	allowN(t, l, 1, 6, true, 4)
+	c.add(500 * time.Millisecond)
+	allowN(t, l, 1, 4, true, 0)
+	var r = allowN(t, l, 1, 1, false, 0)
+	if r.RetryAfter != 600*time.Millisecond || r.ResetAfter != 1500*time.Millisecond {
+		t.Fatalf("unexpected %+v", r)
+	}
+	c.add(700 * time.Millisecond)
+	r = allowN(t, l, 1, 3, false, 2)
+	if r.RetryAfter != 100*time.Millisecond {
+		t.Fatalf("unexpected %+v", r)
+	}
+	c.add(100 * time.Millisecond)
+	allowN(t, l, 1, 3, true, 0)
+	c.add(2 * time.Second)
+	allowN(t, l, 1, 0, true, 10)
+}
+
+func TestRedisGCRA(t *testing.T) {
+	var opt, c = newRedis(t)
+	var l = NewGCRA(Limit{Rate: 10, Period: time.Second, Burst: 3}, opt)
+	allowN(t, l, "a", 2, true, 1)
+	allowN(t, l, "a", 1, true, 0)
+	var r = allowN(t, l, "a", 1, false, 0)
+	if r.RetryAfter != 100*time.Millisecond || r.ResetAfter != 300*time.Millisecond {
+		t.Fatalf("unexpected %+v", r)
+	}
+	c.add(100 * time.Millisecond)
+	r = allowN(t, l, "a", 1, true, 0)
+	if r.ResetAfter != 300*time.Millisecond {
+		t.Fatalf("unexpected %+v", r)
+	}
+	c.add(time.Second)
+	allowN(t, l, "a", 0, true, 3)
+
+	// interval under 1 microsecond is rejected
+	defer func() {
+		if recover() == nil {
+			t.Error("should panic")
+		}
+	}()
+	NewGCRA(PerSecond(2_000_000), opt)
+}
@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindowScript : sliding window in redis, time unit is microsecond.
+// KEYS[1] -- key
+// ARGV -- rate, period, n
+// return -- allowed, remaining, retry after, reset after
+var slidingWindowScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local v = redis.call('HMGET', KEYS[1], 'start', 'cur', 'prev')
+local start = tonumber(v[1])
+local cur = tonumber(v[2]) or 0
+local prev = tonumber(v[3]) or 0
+if start == nil then
+	start = now
+end
+local k = math.floor((now - start) / period)
+if k == 1 then
+	prev = cur
+	cur = 0
+elseif k > 1 then
+	prev = 0
+	cur = 0
+end
+start = start + k * period
+local elapsed = now - start
+local count = prev * (period - elapsed) / period + cur
+local allowed = 0
+local retry = 0
+if count + n <= rate then
+	cur = cur + n
+	count = count + n
+	allowed = 1
+elseif cur + n > rate then
+	retry = start + period - now + math.ceil(period * (cur + n - rate) / cur)
+else
+	retry = math.ceil(period * (prev + cur + n - rate) / prev) - elapsed
+end
+local reset = 0
+if cur > 0 then
+	reset = start + 2 * period - now
+elseif prev > 0 then
+	reset = start + period - now
+end
+if reset > 0 then
+	redis.call('HSET', KEYS[1], 'start', string.format('%.0f', start), 'cur', cur, 'prev', prev)
+	redis.call('PEXPIRE', KEYS[1], math.ceil(reset / 1000) + 1)
+else
+	redis.call('DEL', KEYS[1])
+end
+return {allowed, math.floor(rate - count), retry, reset}
+`)
+
+// slidingWindow : sliding window algorithm, it allows Rate events in any window of Period.
+// It counts events in current and previous fixed windows, and estimates count of the sliding window as
+// prev * (overlap of previous window) + cur, which is smooth and uses constant memory.
+type slidingWindow struct {
+	limit Limit
+}
+
+// windowState : state of sliding window
+type windowState struct {
+	//start of current fixed window
+	start time.Time
+	//events in current fixed window
+	cur int
+	//events in previous fixed window
+	prev int
+}
+
+// NewSlidingWindow : new keyed sliding window limiter, it allows Rate events in any window of Period.
+// Burst of limit is ignored.
+func NewSlidingWindow(limit Limit, opts ...Option) Limiter {
+	var o = rangeOption(opts...)
+	limit = limit.normalize(o.unit())
+	if o.rds != nil {
+		return newRedisLimiter(o, slidingWindowScript, limit.Rate,
+			limit.Rate, limit.Period.Microseconds())
+	}
+	return newLocalLimiter[windowState](&slidingWindow{limit: limit}, o)
+}
+
+// init : empty window starts at now
+func (w *slidingWindow) init(s *windowState, now time.Time) {
+	s.start = now
+}
+
+// allow : slide window then take n events
+func (w *slidingWindow) allow(s *windowState, now time.Time, n int) Result {
+	var period = w.limit.Period
+	var rate = float64(w.limit.Rate)
+	var k = now.Sub(s.start) / period
+	if k == 1 {
+		s.prev, s.cur = s.cur, 0
+	} else if k > 1 {
+		s.prev, s.cur = 0, 0
+	}
+	s.start = s.start.Add(k * period)
+
+	var elapsed = now.Sub(s.start)
+	var count = float64(s.prev)*float64(period-elapsed)/float64(period) + float64(s.cur)
+	var r Result
+	switch {
+	case count+float64(n) <= rate:
+		s.cur += n
+		count += float64(n)
+		r.Allowed = true
+	case s.cur+n > w.limit.Rate:
+		//wait for next window, then previous window(current now) slides out until enough
+		r.RetryAfter = period - elapsed + ceilDuration(float64(period)*float64(s.cur+n-w.limit.Rate)/float64(s.cur))
+	default:
+		//wait for previous window slides out until enough
+		r.RetryAfter = ceilDuration(float64(period)*float64(s.prev+s.cur+n-w.limit.Rate)/float64(s.prev)) - elapsed
+	}
+	r.Remaining = int(math.Floor(rate - count))
+	if s.cur > 0 {
+		r.ResetAfter = 2*period - elapsed
+	} else if s.prev > 0 {
+		r.ResetAfter = period - elapsed
+	}
+	return r
+}
+
+// burst : max events at once
+func (w *slidingWindow) burst() int {
+	return w.limit.Rate
+}
+
+// ceilDuration : ceil of float duration
+func ceilDuration(d float64) time.Duration {
+	return time.Duration(math.Ceil(d))
+}
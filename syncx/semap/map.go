@@ -2,9 +2,15 @@ package semap
 
 import (
 	"context"
+	"errors"
 	"sync"
 )
 
+var (
+	//ErrInvalidWeight -- weight is not in range [1, read/write ratio]
+	ErrInvalidWeight = errors.New("semap.invalid.weight")
+)
+
 // SemMapper define interface to Acquire/Release semaphore map container
 type SemMapper interface {
 	//AcquireRead acquire for read
@@ -17,27 +23,47 @@ type SemMapper interface {
 	ReleaseWrite(key any, w *Weighted)
 }
 
+// WeightedSemMapper semaphore map container with weighted and try acquisition, all containers in package implement it
+type WeightedSemMapper interface {
+	SemMapper
+
+	//Acquire acquire with weight, weight must be in range [1, read/write ratio].
+	//Read is weight 1 and write is weight of read/write ratio.
+	Acquire(ctx context.Context, key any, weight int) (*Weighted, error)
+	//TryAcquire acquire with weight without waiting, return false if resources are not available
+	TryAcquire(key any, weight int) (*Weighted, bool)
+	//Release release with weight, weight must be same as acquired
+	Release(key any, w *Weighted, weight int)
+	//Len count of per-key semaphores in map
+	Len() int
+}
+
 // SemMap semaphore map
+// Per-key semaphore is evicted from map once it's idle(no holder and no waiter), so the map only holds
+// keys in use.
 type SemMap struct {
-	m       map[any]*Weighted
-	mux     *sync.Mutex
-	rwRatio int
+	m        map[any]*Weighted
+	mux      *sync.Mutex
+	rwRatio  int
+	fairness Fairness
 }
 
 // NewSemMap new semaphore map
-func NewSemMap(opts ...OptionFn) SemMapper {
+func NewSemMap(opts ...OptionFn) WeightedSemMapper {
 	var o = RangeOption(opts...)
-	return newSemMap(o.rwRatio)
+	return newSemMap(o.rwRatio, o.fairness)
 }
 
 // newSemMap new semaphore map
 // rwRatio : read/write ratio, for example, if it's 10, means that 10 read go routine can enter at same time.
 // if one write go routine enters, no read go routine can enter.
-func newSemMap(rwRatio int) *SemMap {
+// fairness : fairness mode of per-key semaphore.
+func newSemMap(rwRatio int, fairness Fairness) *SemMap {
 	var m = &SemMap{}
 	m.mux = &sync.Mutex{}
 	m.m = make(map[any]*Weighted)
 	m.rwRatio = rwRatio
+	m.fairness = fairness
 	return m
 }
 
@@ -61,22 +87,57 @@ func (s *SemMap) ReleaseWrite(key any, w *Weighted) {
 	s.release(key, w, s.rwRatio)
 }
 
+// Acquire acquire with weight, weight must be in range [1, read/write ratio].
+func (s *SemMap) Acquire(ctx context.Context, key any, weight int) (*Weighted, error) {
+	if weight < 1 || weight > s.rwRatio {
+		return nil, ErrInvalidWeight
+	}
+	return s.acquire(ctx, key, weight)
+}
+
+// TryAcquire acquire with weight without waiting, return false if resources are not available
+func (s *SemMap) TryAcquire(key any, weight int) (*Weighted, bool) {
+	if weight < 1 || weight > s.rwRatio {
+		return nil, false
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	var w, ok = s.m[key]
+	if !ok {
+		w = newWeighted(s.rwRatio, s.fairness)
+		s.m[key] = w
+	}
+	if !w.tryAcquire(weight) {
+		return nil, false
+	}
+	return w, true
+}
+
+// Release release with weight, weight must be same as acquired
+func (s *SemMap) Release(key any, w *Weighted, weight int) {
+	s.release(key, w, weight)
+}
+
+// Len count of per-key semaphores in map
+func (s *SemMap) Len() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.m)
+}
+
 // acquire : acquire lock
 func (s *SemMap) acquire(ctx context.Context, key any, n int) (*Weighted, error) {
-	var err error
 	s.mux.Lock()
 	var w, ok = s.m[key]
-	if ok {
-		err = w.acquire(ctx, s.mux, n)
-		if err != nil {
-			return nil, err
-		}
-		return w, nil
+	if !ok {
+		w = newWeighted(s.rwRatio, s.fairness)
+		s.m[key] = w
 	}
-	w = newWeighted(s.rwRatio)
-	s.m[key] = w
-	err = w.acquire(ctx, s.mux, n)
+	var err = w.acquire(ctx, s.mux, n)
 	if err != nil {
+		s.mux.Lock()
+		s.evict(key, w)
+		s.mux.Unlock()
 		return nil, err
 	}
 	return w, nil
@@ -86,9 +147,13 @@ func (s *SemMap) acquire(ctx context.Context, key any, n int) (*Weighted, error)
 func (s *SemMap) release(key any, w *Weighted, n int) {
 	s.mux.Lock()
 	defer s.mux.Unlock()
-	var empty = w.release(n)
-	if empty {
+	w.release(n)
+	s.evict(key, w)
+}
+
+// evict : remove per-key semaphore from map if it's idle, must be called with mux held
+func (s *SemMap) evict(key any, w *Weighted) {
+	if w.idle() && s.m[key] == w {
 		delete(s.m, key)
-		return
 	}
 }
@@ -107,3 +107,104 @@ func hammerWeighted(sem SemMapper, n int64, loops int) {
 		sem.ReleaseWrite(n, w)
 	}
 }
+
+func TestAcquireWeight(t *testing.T) {
+	var s = newSemMap(3, FIFO)
+	if _, err := s.Acquire(context.Background(), 1, 4); err != ErrInvalidWeight {
+		t.Fatalf("expected invalid weight, got %v", err)
+	}
+	var w, err = s.Acquire(context.Background(), 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.TryAcquire(1, 2); ok {
+		t.Fatal("weight 2 should not be acquired")
+	}
+	var w1, ok = s.TryAcquire(1, 1)
+	if !ok || w1 != w {
+		t.Fatal("weight 1 should be acquired")
+	}
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err = s.Acquire(ctx, 1, 1); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	s.Release(1, w, 2)
+	// semaphore is still held, it should not be evicted
+	if s.Len() != 1 {
+		t.Fatalf("expected 1 semaphore, got %d", s.Len())
+	}
+	var w2, _ = s.AcquireRead(context.Background(), 1)
+	if w2 != w {
+		t.Fatal("semaphore in use should be shared")
+	}
+	s.ReleaseRead(1, w2)
+	s.Release(1, w1, 1)
+	if s.Len() != 0 {
+		t.Fatalf("idle semaphore should be evicted, got %d", s.Len())
+	}
+
+	// waiter gives up, then holder releases
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	w, _ = s.AcquireWrite(context.Background(), 2)
+	if _, err = s.Acquire(ctx, 2, 1); err != context.DeadlineExceeded || s.Len() != 1 {
+		t.Fatalf("unexpected %v %d", err, s.Len())
+	}
+	s.ReleaseWrite(2, w)
+	if s.Len() != 0 {
+		t.Fatalf("idle semaphore should be evicted, got %d", s.Len())
+	}
+}
+
+func TestFairness(t *testing.T) {
+	// R1 holds, then W1, R2, W2 wait in order
+	var order = func(fairness Fairness) string {
+		var s = newSemMap(3, fairness)
+		var r1, _ = s.AcquireRead(context.Background(), 1)
+		var ch = make(chan string, 3)
+		var wait = func(name string, weight int) {
+			go func() {
+				var w, err = s.Acquire(context.Background(), 1, weight)
+				if err != nil {
+					panic(err)
+				}
+				ch <- name
+				time.Sleep(time.Millisecond)
+				s.Release(1, w, weight)
+			}()
+			time.Sleep(10 * time.Millisecond)
+		}
+		wait("W1", 3)
+		wait("R2", 1)
+		wait("W2", 3)
+		s.ReleaseRead(1, r1)
+		var r string
+		for i := 0; i < 3; i++ {
+			r += <-ch
+		}
+		return r
+	}
+	if r := order(FIFO); r != "W1R2W2" {
+		t.Errorf("unexpected FIFO order %s", r)
+	}
+	if r := order(WriterPreferred); r != "W1W2R2" {
+		t.Errorf("unexpected writer preferred order %s", r)
+	}
+	if r := order(ReaderPreferred); r != "R2W1W2" {
+		t.Errorf("unexpected reader preferred order %s", r)
+	}
+}
+
+func TestWeightedSemMapper_Len(t *testing.T) {
+	for _, s := range []WeightedSemMapper{NewSemMap(), NewWideSemMap(), NewWideXHashSemMap()} {
+		var w, ok = s.TryAcquire(1, 1)
+		if !ok || s.Len() != 1 {
+			t.Fatalf("expected 1 semaphore, got %d", s.Len())
+		}
+		s.Release(1, w, 1)
+		if s.Len() != 0 {
+			t.Fatalf("idle semaphore should be evicted, got %d", s.Len())
+		}
+	}
+}
@@ -5,10 +5,24 @@ const (
 	DefaultRWRatio = 10
 )
 
+// Fairness : fairness mode of per-key semaphore.
+// An acquirer with weight equal to read/write ratio is a writer, others are readers.
+type Fairness int
+
+const (
+	//FIFO waiters are woken up in order, a new acquirer waits if anyone is waiting. It's default mode.
+	FIFO Fairness = iota
+	//WriterPreferred a waiting writer is put before all waiting readers, readers may starve.
+	WriterPreferred
+	//ReaderPreferred readers are not blocked by waiting writers if resources are available, writers may starve.
+	ReaderPreferred
+)
+
 // remap option: use a prime number as group number
 type Option struct {
-	prime   uint64
-	rwRatio int
+	prime    uint64
+	rwRatio  int
+	fairness Fairness
 }
 
 // OptionFn : option function
@@ -28,6 +42,13 @@ func WithRwRatio(rwRatio int) OptionFn {
 	}
 }
 
+// WithFairness : setup fairness mode, default is FIFO
+func WithFairness(fairness Fairness) OptionFn {
+	return func(o *Option) {
+		o.fairness = fairness
+	}
+}
+
 // RangeOption : range option
 func RangeOption(opts ...OptionFn) *Option {
 	var o = &Option{
@@ -16,38 +16,56 @@ type waiter struct {
 type Weighted struct {
 	size    int
 	cur     int
+	mode    Fairness
 	waiters list.List
 }
 
 // newWeighted creates a new weighted semaphore with the given
 // maximum combined weight for concurrent access.
-func newWeighted(n int) *Weighted {
-	w := &Weighted{size: n}
+func newWeighted(n int, mode Fairness) *Weighted {
+	w := &Weighted{size: n, mode: mode}
 	return w
 }
 
+// idle : no holder and no waiter
+func (s *Weighted) idle() bool {
+	return s.cur == 0 && s.waiters.Len() == 0
+}
+
+// tryAcquire : acquires the semaphore with a weight of n without blocking.
+// It must be called with mu held, and mu is still held after return.
+func (s *Weighted) tryAcquire(n int) bool {
+	if s.size-s.cur >= n && s.canBarge(n) {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// canBarge : acquirer can get the semaphore before waiters if resources are available.
+// In ReaderPreferred mode, a reader can barge in even if writers are waiting.
+func (s *Weighted) canBarge(n int) bool {
+	if s.waiters.Len() == 0 {
+		return true
+	}
+	return s.mode == ReaderPreferred && n < s.size
+}
+
 // acquire : acquires the semaphore with a weight of n, blocking until resources
 // are available or ctx is done. On success, returns nil. On failure, returns
 // ctx.Err() and leaves the semaphore unchanged.
+// It must be called with mu held, and mu is released after return.
 //
 // If ctx is already done, Acquire may still succeed without blocking.
 func (s *Weighted) acquire(ctx context.Context, mu *sync.Mutex, n int) error {
-	if s.size-s.cur >= n && s.waiters.Len() == 0 {
-		s.cur += n
+	if s.tryAcquire(n) {
 		mu.Unlock()
 		return nil
 	}
 
-	if n > s.size {
-		// Don't make other Acquire calls block on one that's doomed to fail.
-		mu.Unlock()
-		<-ctx.Done()
-		return ctx.Err()
-	}
-
 	ready := make(chan struct{})
 	w := waiter{n: n, ready: ready}
-	elem := s.waiters.PushBack(w)
+	elem := s.enqueue(w)
 	mu.Unlock()
 
 	select {
@@ -63,7 +81,8 @@ func (s *Weighted) acquire(ctx context.Context, mu *sync.Mutex, n int) error {
 			isFront := s.waiters.Front() == elem
 			s.waiters.Remove(elem)
 			// If we're at the front and there're extra tokens left, notify other waiters.
-			if isFront && s.size > s.cur {
+			// In ReaderPreferred mode, readers may wait behind this one, notify them anyway.
+			if (isFront || s.mode == ReaderPreferred) && s.size > s.cur {
 				s.notifyWaiters()
 			}
 		}
@@ -85,8 +104,26 @@ func (s *Weighted) release(n int) bool {
 	return s.notifyWaiters()
 }
 
+// enqueue : put waiter into waiting list.
+// In WriterPreferred mode, a writer is put before all waiting readers.
+func (s *Weighted) enqueue(w waiter) *list.Element {
+	if s.mode != WriterPreferred || w.n < s.size {
+		return s.waiters.PushBack(w)
+	}
+	for e := s.waiters.Front(); e != nil; e = e.Next() {
+		// nolint : forcetypeassert // I know the type is exactly here
+		if e.Value.(waiter).n < s.size {
+			return s.waiters.InsertBefore(w, e)
+		}
+	}
+	return s.waiters.PushBack(w)
+}
+
 // notify other waiters, if waiter list is empty, return true
 func (s *Weighted) notifyWaiters() bool {
+	if s.mode == ReaderPreferred {
+		return s.notifyReaders()
+	}
 	for {
 		next := s.waiters.Front()
 		if next == nil {
@@ -116,3 +153,20 @@ func (s *Weighted) notifyWaiters() bool {
 	}
 	return false
 }
+
+// notifyReaders : notify all waiters which can acquire, waiters are not blocked by a writer ahead,
+// so writers may starve if readers keep coming. If waiter list is empty, return true
+func (s *Weighted) notifyReaders() bool {
+	for e := s.waiters.Front(); e != nil && s.cur < s.size; {
+		var next = e.Next()
+		// nolint : forcetypeassert // I know the type is exactly here
+		w := e.Value.(waiter)
+		if s.size-s.cur >= w.n {
+			s.cur += w.n
+			s.waiters.Remove(e)
+			close(w.ready)
+		}
+		e = next
+	}
+	return s.waiters.Len() == 0
+}
@@ -14,19 +14,19 @@ type WideSemMap struct {
 }
 
 // NewWideSemMap new wide semaphore map
-func NewWideSemMap(opts ...OptionFn) SemMapper {
+func NewWideSemMap(opts ...OptionFn) WeightedSemMapper {
 	var o = RangeOption(opts...)
-	return newWideSemMap(o.rwRatio, o.prime, false)
+	return newWideSemMap(o.rwRatio, o.fairness, o.prime, false)
 }
 
 // NewWideXHashSemMap new wide semaphore map
-func NewWideXHashSemMap(opts ...OptionFn) SemMapper {
+func NewWideXHashSemMap(opts ...OptionFn) WeightedSemMapper {
 	var o = RangeOption(opts...)
-	return newWideSemMap(o.rwRatio, o.prime, true)
+	return newWideSemMap(o.rwRatio, o.fairness, o.prime, true)
 }
 
 // newWideSemMap new wide semaphore map
-func newWideSemMap(rwRatio int, prime uint64, useXHash bool) SemMapper {
+func newWideSemMap(rwRatio int, fairness Fairness, prime uint64, useXHash bool) WeightedSemMapper {
 	var w = &WideSemMap{}
 	if prime > 0 {
 		w.rehash = remap.NewReMap(remap.WithPrime(prime))
@@ -36,7 +36,7 @@ func newWideSemMap(rwRatio int, prime uint64, useXHash bool) SemMapper {
 	var numbs = w.rehash.Numbs()
 	w.ms = make([]*SemMap, numbs)
 	for i := uint64(0); i < numbs; i++ {
-		w.ms[i] = newSemMap(rwRatio)
+		w.ms[i] = newSemMap(rwRatio, fairness)
 	}
 	if useXHash {
 		w.calKeyFn = w.rehash.XHashIndex
@@ -66,6 +66,30 @@ func (s *WideSemMap) ReleaseWrite(key any, w *Weighted) {
 	s.calculateKey(key).ReleaseWrite(key, w)
 }
 
+// Acquire acquire with weight, weight must be in range [1, read/write ratio].
+func (s *WideSemMap) Acquire(ctx context.Context, key any, weight int) (*Weighted, error) {
+	return s.calculateKey(key).Acquire(ctx, key, weight)
+}
+
+// TryAcquire acquire with weight without waiting, return false if resources are not available
+func (s *WideSemMap) TryAcquire(key any, weight int) (*Weighted, bool) {
+	return s.calculateKey(key).TryAcquire(key, weight)
+}
+
+// Release release with weight, weight must be same as acquired
+func (s *WideSemMap) Release(key any, w *Weighted, weight int) {
+	s.calculateKey(key).Release(key, w, weight)
+}
+
+// Len count of per-key semaphores in all maps
+func (s *WideSemMap) Len() int {
+	var n int
+	for _, m := range s.ms {
+		n += m.Len()
+	}
+	return n
+}
+
 // calculate key
 func (s *WideSemMap) calculateKey(key any) *SemMap {
 	var i = s.calKeyFn(key)
@@ -0,0 +1,18 @@
+package ulog
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/pinealctx/neptune/errorx"
+)
+
+// ErrorObj : structured error field, it contains message, cause chain, code and stack frames.
+// If no filter is given, the default filters setup by errorx.SetLogFrameFilters are used.
+func ErrorObj(err error, filters ...errorx.FrameFilter) zap.Field {
+	return NamedErrorObj("error", err, filters...)
+}
+
+// NamedErrorObj : structured error field with key
+func NamedErrorObj(key string, err error, filters ...errorx.FrameFilter) zap.Field {
+	return errorx.ZapNamedField(key, err, filters...)
+}